@@ -0,0 +1,17 @@
+package configs
+
+import (
+	"os"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+// DeletedMessagePolicy reads ACCOUNT_DELETION_MESSAGES, which decides whether the messages
+// of a deleted account are anonymised (the default) or deleted
+func DeletedMessagePolicy() string {
+	if strings.TrimSpace(os.Getenv("ACCOUNT_DELETION_MESSAGES")) == models.DeletedMessagesDelete {
+		return models.DeletedMessagesDelete
+	}
+	return models.DeletedMessagesAnonymize
+}
@@ -0,0 +1,19 @@
+package configs
+
+import (
+	"os"
+	"strings"
+)
+
+// IsSystemAdmin reports whether username is listed in the comma separated SYSTEM_ADMINS variable
+func IsSystemAdmin(username string) bool {
+	if username == "" {
+		return false
+	}
+	for _, admin := range strings.Split(os.Getenv("SYSTEM_ADMINS"), ",") {
+		if strings.TrimSpace(admin) == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package configs
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailConfig selects how e-mail is sent. Transport is "smtp", "file" or empty to
+// disable e-mail entirely.
+type MailConfig struct {
+	Transport    string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// Dir receives one .eml file per message with the file transport
+	Dir string
+}
+
+// LoadMailConfig reads the MAIL_* and SMTP_* variables
+func LoadMailConfig() MailConfig {
+	cfg := MailConfig{
+		Transport:    strings.TrimSpace(os.Getenv("MAIL_TRANSPORT")),
+		From:         strings.TrimSpace(os.Getenv("MAIL_FROM")),
+		SMTPHost:     strings.TrimSpace(os.Getenv("SMTP_HOST")),
+		SMTPPort:     strings.TrimSpace(os.Getenv("SMTP_PORT")),
+		SMTPUsername: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		Dir:          strings.TrimSpace(os.Getenv("MAIL_DIR")),
+	}
+	if cfg.SMTPPort == "" {
+		cfg.SMTPPort = "587"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "./mail"
+	}
+	return cfg
+}
+
+// DigestConfig controls the missed message digest job
+type DigestConfig struct {
+	// OfflineThreshold is how long a user must have been offline to get a digest
+	OfflineThreshold time.Duration
+	Interval         time.Duration
+}
+
+// LoadDigestConfig reads DIGEST_OFFLINE_MINUTES and DIGEST_INTERVAL_MINUTES
+func LoadDigestConfig() DigestConfig {
+	return DigestConfig{
+		OfflineThreshold: minutesFromEnv("DIGEST_OFFLINE_MINUTES", 60),
+		Interval:         minutesFromEnv("DIGEST_INTERVAL_MINUTES", 10),
+	}
+}
+
+func minutesFromEnv(name string, fallback int) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name)))
+	if err != nil || minutes <= 0 {
+		minutes = fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
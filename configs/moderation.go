@@ -0,0 +1,41 @@
+package configs
+
+import (
+	"os"
+	"strings"
+)
+
+// ModerationConfig holds the server wide message filters
+type ModerationConfig struct {
+	// Keywords are matched as whole words, ignoring case
+	Keywords      []string
+	KeywordAction string
+	BlockLinks    bool
+	LinkAction    string
+	// AllowedDomains are exempt from link blocking, subdomains included
+	AllowedDomains []string
+	// ClassifierURL receives every message for an external verdict when set
+	ClassifierURL string
+}
+
+// LoadModerationConfig reads the MODERATION_* variables
+func LoadModerationConfig() ModerationConfig {
+	return ModerationConfig{
+		Keywords:       splitList(os.Getenv("MODERATION_KEYWORDS")),
+		KeywordAction:  strings.TrimSpace(os.Getenv("MODERATION_KEYWORD_ACTION")),
+		BlockLinks:     strings.TrimSpace(os.Getenv("MODERATION_BLOCK_LINKS")) == "true",
+		LinkAction:     strings.TrimSpace(os.Getenv("MODERATION_LINK_ACTION")),
+		AllowedDomains: splitList(os.Getenv("MODERATION_ALLOWED_DOMAINS")),
+		ClassifierURL:  strings.TrimSpace(os.Getenv("MODERATION_CLASSIFIER_URL")),
+	}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
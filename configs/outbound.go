@@ -0,0 +1,21 @@
+package configs
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// OutboundAllowedNetworks returns the networks in the comma separated OUTBOUND_ALLOWED_NETWORKS
+// variable, such as 127.0.0.1/32. Webhooks, command hooks and push endpoints may reach them
+// even though they are loopback or private, so a local stand-in can be used in development.
+// Invalid entries are ignored.
+func OutboundAllowedNetworks() []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, entry := range strings.Split(os.Getenv("OUTBOUND_ALLOWED_NETWORKS"), ",") {
+		if _, network, err := net.ParseCIDR(strings.TrimSpace(entry)); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
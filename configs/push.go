@@ -0,0 +1,43 @@
+package configs
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPushCoalesceWindow is how long notifications for one conversation are held and
+// merged before they are sent
+const defaultPushCoalesceWindow = 5 * time.Second
+
+// PushConfig holds the push notification providers. A provider without its settings
+// is disabled.
+type PushConfig struct {
+	// VAPID keys are base64url encoded: the raw 32 byte private scalar and the
+	// uncompressed public point, as printed by common web push tooling
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	// VAPIDSubject is a mailto: or https: contact for push services
+	VAPIDSubject string
+	// HTTPURL receives every notification for devices registered with the http provider
+	HTTPURL        string
+	HTTPToken      string
+	CoalesceWindow time.Duration
+}
+
+// LoadPushConfig reads the PUSH_* variables
+func LoadPushConfig() PushConfig {
+	window := defaultPushCoalesceWindow
+	if seconds, err := strconv.Atoi(strings.TrimSpace(os.Getenv("PUSH_COALESCE_SECONDS"))); err == nil && seconds >= 0 {
+		window = time.Duration(seconds) * time.Second
+	}
+	return PushConfig{
+		VAPIDPublicKey:  strings.TrimSpace(os.Getenv("PUSH_VAPID_PUBLIC_KEY")),
+		VAPIDPrivateKey: strings.TrimSpace(os.Getenv("PUSH_VAPID_PRIVATE_KEY")),
+		VAPIDSubject:    strings.TrimSpace(os.Getenv("PUSH_VAPID_SUBJECT")),
+		HTTPURL:         strings.TrimSpace(os.Getenv("PUSH_HTTP_URL")),
+		HTTPToken:       strings.TrimSpace(os.Getenv("PUSH_HTTP_TOKEN")),
+		CoalesceWindow:  window,
+	}
+}
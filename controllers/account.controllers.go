@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type accountController struct {
+	accountService services.AccountService
+}
+
+type AccountController interface {
+	ExportAccount(c *gin.Context)
+	DeleteAccount(c *gin.Context)
+}
+
+func NewAccountController(accountService services.AccountService) AccountController {
+	return &accountController{
+		accountService: accountService,
+	}
+}
+
+func (c *accountController) ExportAccount(ctx *gin.Context) {
+	username := ctx.Param("username")
+	requester := ctx.Query("requester")
+	if err := c.accountService.AuthorizeExport(username, requester); err != nil {
+		writeAccountError(ctx, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.zip", username, time.Now().UTC().Format("20060102T150405Z"))
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+	if err := c.accountService.Export(ctx.Writer, username, requester); err != nil {
+		log.Printf("Account export of %s by %s failed: %v", username, requester, err)
+	}
+}
+
+func (c *accountController) DeleteAccount(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	deletion, err := c.accountService.DeleteAccount(ctx.Param("username"), req.Requester)
+	if err != nil {
+		writeAccountError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, deletion)
+}
+
+func writeAccountError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err.Error() == "user not found":
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "account is on legal hold":
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type auditController struct {
+	auditService services.AuditService
+}
+
+type AuditController interface {
+	ListGroupEntries(c *gin.Context)
+	ListEntries(c *gin.Context)
+}
+
+func NewAuditController(auditService services.AuditService) AuditController {
+	return &auditController{
+		auditService: auditService,
+	}
+}
+
+func (c *auditController) ListGroupEntries(ctx *gin.Context) {
+	filter, err := auditFilterFromQuery(ctx)
+	if err != nil {
+		writeAuditError(ctx, err)
+		return
+	}
+	entries, err := c.auditService.ListGroupEntries(ctx.Param("id"), ctx.Query("requester"), filter)
+	if err != nil {
+		writeAuditError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (c *auditController) ListEntries(ctx *gin.Context) {
+	filter, err := auditFilterFromQuery(ctx)
+	if err != nil {
+		writeAuditError(ctx, err)
+		return
+	}
+	filter.GroupID = ctx.Query("group_id")
+	entries, err := c.auditService.ListEntries(ctx.Query("requester"), filter)
+	if err != nil {
+		writeAuditError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func auditFilterFromQuery(ctx *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Actor:  ctx.Query("actor"),
+		Action: ctx.Query("action"),
+	}
+	if before := ctx.Query("before"); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return filter, errors.New("invalid audit query: before must be an RFC 3339 timestamp")
+		}
+		filter.Before = &parsed
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || parsed <= 0 {
+			return filter, errors.New("invalid audit query: limit must be between 1 and 500")
+		}
+		filter.Limit = parsed
+	}
+	return filter, nil
+}
+
+func writeAuditError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err.Error() == "group not found":
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid audit"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type botController struct {
+	botService services.BotService
+}
+
+type BotController interface {
+	CreateBot(c *gin.Context)
+	ListBots(c *gin.Context)
+	DeleteBot(c *gin.Context)
+	RotateKey(c *gin.Context)
+	Me(c *gin.Context)
+	ListGroups(c *gin.Context)
+	PostMessage(c *gin.Context)
+}
+
+func NewBotController(botService services.BotService) BotController {
+	return &botController{
+		botService: botService,
+	}
+}
+
+func (c *botController) CreateBot(ctx *gin.Context) {
+	var req struct {
+		Requester   string `json:"requester" binding:"required"`
+		Username    string `json:"username" binding:"required"`
+		DisplayName string `json:"display_name"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	credentials, err := c.botService.CreateBot(req.Username, req.DisplayName, req.Description, req.Requester)
+	if err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, credentials)
+}
+
+func (c *botController) ListBots(ctx *gin.Context) {
+	bots, err := c.botService.ListBots(ctx.Query("requester"))
+	if err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, bots)
+}
+
+func (c *botController) DeleteBot(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.botService.DeleteBot(ctx.Param("username"), req.Requester); err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Bot deleted"})
+}
+
+func (c *botController) RotateKey(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	credentials, err := c.botService.RotateKey(ctx.Param("username"), req.Requester)
+	if err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, credentials)
+}
+
+func (c *botController) Me(ctx *gin.Context) {
+	bot, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, bot)
+}
+
+func (c *botController) ListGroups(ctx *gin.Context) {
+	bot, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+	groups, err := c.botService.ListGroups(bot)
+	if err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, groups)
+}
+
+func (c *botController) PostMessage(ctx *gin.Context) {
+	bot, ok := c.authenticate(ctx)
+	if !ok {
+		return
+	}
+	var req models.BotMessage
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	message, err := c.botService.PostMessage(bot, &req)
+	if err != nil {
+		writeBotError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, message)
+}
+
+// authenticate resolves the bot behind the request's API key and answers 401 when there is none
+func (c *botController) authenticate(ctx *gin.Context) (*models.Bot, bool) {
+	key := botAPIKey(ctx)
+	if key == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+		return nil, false
+	}
+	bot, err := c.botService.Authenticate(key)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "unauthorized") {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return bot, true
+}
+
+// botAPIKey reads a bot API key from "Authorization: Bearer <key>", falling back to the
+// api_key query parameter for websocket clients that cannot set headers
+func botAPIKey(ctx *gin.Context) string {
+	if header := ctx.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return ctx.Query("api_key")
+}
+
+func writeBotError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "already taken"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
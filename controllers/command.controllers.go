@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type commandController struct {
+	commandService services.CommandService
+}
+
+type CommandController interface {
+	ListGlobalCommands(c *gin.Context)
+	ListGroupCommands(c *gin.Context)
+	CreateGlobalHook(c *gin.Context)
+	CreateGroupHook(c *gin.Context)
+	DeleteHook(c *gin.Context)
+}
+
+func NewCommandController(commandService services.CommandService) CommandController {
+	return &commandController{
+		commandService: commandService,
+	}
+}
+
+func (c *commandController) ListGlobalCommands(ctx *gin.Context) {
+	c.listCommands(ctx, "")
+}
+
+func (c *commandController) ListGroupCommands(ctx *gin.Context) {
+	c.listCommands(ctx, ctx.Param("id"))
+}
+
+func (c *commandController) listCommands(ctx *gin.Context, groupID string) {
+	commands, err := c.commandService.ListCommands(groupID, ctx.Query("requester"))
+	if err != nil {
+		writeCommandError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, commands)
+}
+
+func (c *commandController) CreateGlobalHook(ctx *gin.Context) {
+	c.createHook(ctx, "")
+}
+
+func (c *commandController) CreateGroupHook(ctx *gin.Context) {
+	c.createHook(ctx, ctx.Param("id"))
+}
+
+func (c *commandController) createHook(ctx *gin.Context, groupID string) {
+	var req struct {
+		Requester   string `json:"requester" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		URL         string `json:"url" binding:"required"`
+		Usage       string `json:"usage"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	hook, err := c.commandService.CreateHook(groupID, req.Name, req.URL, req.Usage, req.Description, req.Requester)
+	if err != nil {
+		writeCommandError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+func (c *commandController) DeleteHook(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.commandService.DeleteHook(ctx.Param("command_id"), req.Requester); err != nil {
+		writeCommandError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Command deleted"})
+}
+
+func writeCommandError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid command"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "already exists"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
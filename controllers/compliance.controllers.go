@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type complianceController struct {
+	complianceService services.ComplianceService
+}
+
+type ComplianceController interface {
+	Export(c *gin.Context)
+	Import(c *gin.Context)
+}
+
+func NewComplianceController(complianceService services.ComplianceService) ComplianceController {
+	return &complianceController{
+		complianceService: complianceService,
+	}
+}
+
+func (c *complianceController) Export(ctx *gin.Context) {
+	filter := models.ExportFilter{
+		Username: ctx.Query("username"),
+		GroupID:  ctx.Query("group_id"),
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := ctx.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid export: %s must be an RFC 3339 timestamp", param)})
+			return
+		}
+		*target = &parsed
+	}
+
+	requester := ctx.Query("requester")
+	if err := c.complianceService.AuthorizeExport(filter, requester); err != nil {
+		writeComplianceError(ctx, err)
+		return
+	}
+
+	// Once the archive starts streaming the status is committed, so later failures
+	// can only be logged and surface to the client as a truncated zip
+	filename := fmt.Sprintf("export-%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+	if err := c.complianceService.Export(ctx.Writer, filter, requester); err != nil {
+		log.Printf("Export by %s failed: %v", requester, err)
+	}
+}
+
+func (c *complianceController) Import(ctx *gin.Context) {
+	requester := ctx.PostForm("requester")
+	header, err := ctx.FormFile("archive")
+	if err != nil || requester == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	defer file.Close()
+
+	result, err := c.complianceService.Import(file, header.Size, requester)
+	if err != nil {
+		writeComplianceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func writeComplianceError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type contactController struct {
+	contactService services.ContactService
+}
+
+type ContactController interface {
+	ListContacts(c *gin.Context)
+	RemoveContact(c *gin.Context)
+	ListFriendRequests(c *gin.Context)
+	SendFriendRequest(c *gin.Context)
+	AcceptFriendRequest(c *gin.Context)
+	RejectFriendRequest(c *gin.Context)
+	ListBlockedUsers(c *gin.Context)
+	BlockUser(c *gin.Context)
+	UnblockUser(c *gin.Context)
+}
+
+func NewContactController(contactService services.ContactService) ContactController {
+	return &contactController{
+		contactService: contactService,
+	}
+}
+
+func (c *contactController) ListContacts(ctx *gin.Context) {
+	contacts, err := c.contactService.ListContacts(ctx.Param("username"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, contacts)
+}
+
+func (c *contactController) RemoveContact(ctx *gin.Context) {
+	err := c.contactService.RemoveContact(ctx.Param("username"), ctx.Param("contact"))
+	if err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Contact removed"})
+}
+
+func (c *contactController) ListFriendRequests(ctx *gin.Context) {
+	requests, err := c.contactService.ListFriendRequests(ctx.Param("username"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, requests)
+}
+
+func (c *contactController) SendFriendRequest(ctx *gin.Context) {
+	var req struct {
+		To string `json:"to" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	request, err := c.contactService.SendFriendRequest(ctx.Param("username"), req.To)
+	if err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, request)
+}
+
+func (c *contactController) AcceptFriendRequest(ctx *gin.Context) {
+	request, err := c.contactService.AcceptFriendRequest(ctx.Param("id"), ctx.Param("username"))
+	if err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+func (c *contactController) RejectFriendRequest(ctx *gin.Context) {
+	request, err := c.contactService.RejectFriendRequest(ctx.Param("id"), ctx.Param("username"))
+	if err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, request)
+}
+
+func (c *contactController) ListBlockedUsers(ctx *gin.Context) {
+	blocks, err := c.contactService.ListBlockedUsers(ctx.Param("username"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, blocks)
+}
+
+func (c *contactController) BlockUser(ctx *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.contactService.BlockUser(ctx.Param("username"), req.Username); err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+func (c *contactController) UnblockUser(ctx *gin.Context) {
+	if err := c.contactService.UnblockUser(ctx.Param("username"), ctx.Param("blocked")); err != nil {
+		writeContactError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
+
+func writeContactError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "cannot"),
+		strings.HasPrefix(err.Error(), "already"),
+		strings.HasSuffix(err.Error(), "already answered"),
+		strings.HasSuffix(err.Error(), "is not blocked"),
+		strings.HasSuffix(err.Error(), "are required"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
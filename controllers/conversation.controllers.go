@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type conversationController struct {
+	conversationService services.ConversationService
+}
+
+type ConversationController interface {
+	ListConversations(c *gin.Context)
+	MarkRead(c *gin.Context)
+	SetDirectMessageTTL(c *gin.Context)
+	ListMentions(c *gin.Context)
+}
+
+func NewConversationController(conversationService services.ConversationService) ConversationController {
+	return &conversationController{
+		conversationService: conversationService,
+	}
+}
+
+func (c *conversationController) ListConversations(ctx *gin.Context) {
+	conversations, err := c.conversationService.ListConversations(ctx.Param("username"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, conversations)
+}
+
+func (c *conversationController) MarkRead(ctx *gin.Context) {
+	var req struct {
+		GroupID  string `json:"group_id"`
+		Receiver string `json:"receiver"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.GroupID == "") == (req.Receiver == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	conversationType, id := models.ConversationDirect, req.Receiver
+	if req.GroupID != "" {
+		conversationType, id = models.ConversationGroup, req.GroupID
+	}
+	cursor, err := c.conversationService.MarkRead(ctx.Param("username"), conversationType, id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, cursor)
+}
+
+func (c *conversationController) SetDirectMessageTTL(ctx *gin.Context) {
+	var req struct {
+		MessageTTL *int64 `json:"message_ttl" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	settings, err := c.conversationService.SetDirectMessageTTL(ctx.Param("username"), ctx.Param("receiver"), *req.MessageTTL)
+	if err != nil {
+		if err.Error() == "user not found" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid message ttl") || strings.HasSuffix(err.Error(), "are required") {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *conversationController) ListMentions(ctx *gin.Context) {
+	var before *time.Time
+	if raw := ctx.Query("before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid mentions query: before must be an RFC 3339 time"})
+			return
+		}
+		before = &parsed
+	}
+	var limit int64
+	if raw := ctx.Query("limit"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid mentions query: limit must be between 1 and 200"})
+			return
+		}
+		limit = parsed
+	}
+	mentions, err := c.conversationService.ListMentions(ctx.Param("username"), before, limit)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") || strings.HasSuffix(err.Error(), "is required") {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, mentions)
+}
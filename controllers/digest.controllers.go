@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type digestController struct {
+	digestService services.DigestService
+}
+
+type DigestController interface {
+	GetSettings(c *gin.Context)
+	UpdateSettings(c *gin.Context)
+	Preview(c *gin.Context)
+}
+
+func NewDigestController(digestService services.DigestService) DigestController {
+	return &digestController{
+		digestService: digestService,
+	}
+}
+
+func (c *digestController) GetSettings(ctx *gin.Context) {
+	settings, err := c.digestService.GetSettings(ctx.Param("username"))
+	if err != nil {
+		writeDigestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *digestController) UpdateSettings(ctx *gin.Context) {
+	var req models.DigestSettingsUpdate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	settings, err := c.digestService.UpdateSettings(ctx.Param("username"), req)
+	if err != nil {
+		writeDigestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *digestController) Preview(ctx *gin.Context) {
+	digest, err := c.digestService.Preview(ctx.Param("username"))
+	if err != nil {
+		writeDigestError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, digest)
+}
+
+func writeDigestError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"), strings.HasSuffix(err.Error(), "required"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
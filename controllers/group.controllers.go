@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/JomnoiZ/network-backend-group-13.git/services"
 	"github.com/gin-gonic/gin"
@@ -20,6 +22,14 @@ type GroupController interface {
 	AddAdmin(c *gin.Context)
 	RemoveAdmin(c *gin.Context)
 	GetGroupMessages(c *gin.Context)
+	SetMessageTTL(c *gin.Context)
+	SetTopic(c *gin.Context)
+	MuteMember(c *gin.Context)
+	UnmuteMember(c *gin.Context)
+	ListMutes(c *gin.Context)
+	BanMember(c *gin.Context)
+	UnbanMember(c *gin.Context)
+	ListBans(c *gin.Context)
 }
 
 func NewGroupController(groupService services.GroupService) GroupController {
@@ -71,18 +81,23 @@ func (c *groupController) CreateGroup(ctx *gin.Context) {
 func (c *groupController) AddMember(ctx *gin.Context) {
 	groupID := ctx.Param("id")
 	var req struct {
-		Username string `json:"username" binding:"required"`
+		Username  string `json:"username" binding:"required"`
+		Requester string `json:"requester"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
-	err := c.groupService.AddMember(groupID, req.Username)
+	err := c.groupService.AddMember(groupID, req.Username, req.Requester)
 	if err != nil {
 		if err.Error() == "group not found" {
 			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if err.Error() == "user is banned from this group" {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if err.Error() == "unauthorized" {
 			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
@@ -188,3 +203,153 @@ func (c *groupController) GetGroupMessages(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, messages)
 }
+
+func (c *groupController) SetMessageTTL(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	var req struct {
+		Requester  string `json:"requester" binding:"required"`
+		MessageTTL *int64 `json:"message_ttl" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	err := c.groupService.SetMessageTTL(groupID, *req.MessageTTL, req.Requester)
+	if err != nil {
+		if err.Error() == "group not found" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "unauthorized") {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid message ttl") {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Message retention updated"})
+}
+
+func (c *groupController) SetTopic(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Topic     string `json:"topic"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.groupService.SetTopic(ctx.Param("id"), req.Topic, req.Requester); err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Topic updated"})
+}
+
+func (c *groupController) MuteMember(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Username  string `json:"username" binding:"required"`
+		// Duration is in seconds, zero mutes until an admin unmutes
+		Duration int64 `json:"duration"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	err := c.groupService.MuteMember(ctx.Param("id"), req.Username, time.Duration(req.Duration)*time.Second, req.Requester)
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member muted"})
+}
+
+func (c *groupController) UnmuteMember(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	err := c.groupService.UnmuteMember(ctx.Param("id"), ctx.Param("username"), req.Requester)
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member unmuted"})
+}
+
+func (c *groupController) BanMember(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Username  string `json:"username" binding:"required"`
+		Reason    string `json:"reason"`
+		// Duration is in seconds, zero bans until an admin unbans
+		Duration int64 `json:"duration"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	err := c.groupService.BanMember(ctx.Param("id"), req.Username, req.Reason, time.Duration(req.Duration)*time.Second, req.Requester)
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member banned"})
+}
+
+func (c *groupController) UnbanMember(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	err := c.groupService.UnbanMember(ctx.Param("id"), ctx.Param("username"), req.Requester)
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member unbanned"})
+}
+
+func (c *groupController) ListMutes(ctx *gin.Context) {
+	mutes, err := c.groupService.ListMutes(ctx.Param("id"), ctx.Query("requester"))
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, mutes)
+}
+
+func (c *groupController) ListBans(ctx *gin.Context) {
+	bans, err := c.groupService.ListBans(ctx.Param("id"), ctx.Query("requester"))
+	if err != nil {
+		writeGroupModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, bans)
+}
+
+func writeGroupModerationError(ctx *gin.Context, err error) {
+	switch {
+	case err.Error() == "group not found":
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "cannot"), strings.HasPrefix(err.Error(), "user is"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
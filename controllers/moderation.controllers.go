@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type moderationController struct {
+	moderationService services.ModerationService
+}
+
+type ModerationController interface {
+	ListRules(c *gin.Context)
+	AddRule(c *gin.Context)
+	DeleteRule(c *gin.Context)
+	ListQueue(c *gin.Context)
+	ResolveItem(c *gin.Context)
+	CreateReport(c *gin.Context)
+}
+
+func NewModerationController(moderationService services.ModerationService) ModerationController {
+	return &moderationController{
+		moderationService: moderationService,
+	}
+}
+
+func (c *moderationController) ListRules(ctx *gin.Context) {
+	rules, err := c.moderationService.ListRules(ctx.Param("id"), ctx.Query("requester"))
+	if err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, rules)
+}
+
+func (c *moderationController) AddRule(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Pattern   string `json:"pattern" binding:"required"`
+		Action    string `json:"action" binding:"required"`
+		Reason    string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	rule, err := c.moderationService.AddRule(ctx.Param("id"), req.Pattern, req.Action, req.Reason, req.Requester)
+	if err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, rule)
+}
+
+func (c *moderationController) DeleteRule(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.moderationService.DeleteRule(ctx.Param("id"), ctx.Param("rule_id"), req.Requester); err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Moderation rule removed"})
+}
+
+func (c *moderationController) ListQueue(ctx *gin.Context) {
+	filter := models.ModerationQueueFilter{
+		GroupID: ctx.Query("group_id"),
+		Source:  ctx.Query("source"),
+		Status:  ctx.DefaultQuery("status", models.ModerationItemPending),
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid moderation query: limit must be between 1 and 500"})
+			return
+		}
+		filter.Limit = parsed
+	}
+	items, err := c.moderationService.ListQueue(ctx.Query("requester"), filter)
+	if err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, items)
+}
+
+func (c *moderationController) ResolveItem(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		models.ModerationDecision
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Action == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	item, err := c.moderationService.ResolveItem(ctx.Param("id"), req.Requester, req.ModerationDecision)
+	if err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+func (c *moderationController) CreateReport(ctx *gin.Context) {
+	var report models.Report
+	if err := ctx.ShouldBindJSON(&report); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	item, err := c.moderationService.CreateReport(&report)
+	if err != nil {
+		writeModerationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, item)
+}
+
+func writeModerationError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "already resolved"), strings.HasSuffix(err.Error(), "already submitted"),
+		strings.HasPrefix(err.Error(), "cannot"), strings.HasPrefix(err.Error(), "user is"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
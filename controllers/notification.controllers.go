@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type notificationController struct {
+	notificationService services.NotificationService
+}
+
+type NotificationController interface {
+	GetSettings(c *gin.Context)
+	SetConversationLevel(c *gin.Context)
+	ClearConversationLevel(c *gin.Context)
+	SetDoNotDisturb(c *gin.Context)
+	ClearDoNotDisturb(c *gin.Context)
+}
+
+func NewNotificationController(notificationService services.NotificationService) NotificationController {
+	return &notificationController{
+		notificationService: notificationService,
+	}
+}
+
+func (c *notificationController) GetSettings(ctx *gin.Context) {
+	settings, err := c.notificationService.GetSettings(ctx.Param("username"))
+	if err != nil {
+		writeNotificationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *notificationController) SetConversationLevel(ctx *gin.Context) {
+	var req struct {
+		GroupID  string     `json:"group_id"`
+		Receiver string     `json:"receiver"`
+		Level    string     `json:"level" binding:"required"`
+		Until    *time.Time `json:"until"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.GroupID == "") == (req.Receiver == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	conversationType, id := conversationOf(req.GroupID, req.Receiver)
+	settings, err := c.notificationService.SetConversationLevel(ctx.Param("username"), conversationType, id, req.Level, req.Until)
+	if err != nil {
+		writeNotificationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *notificationController) ClearConversationLevel(ctx *gin.Context) {
+	var req struct {
+		GroupID  string `json:"group_id"`
+		Receiver string `json:"receiver"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.GroupID == "") == (req.Receiver == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	conversationType, id := conversationOf(req.GroupID, req.Receiver)
+	settings, err := c.notificationService.ClearConversationLevel(ctx.Param("username"), conversationType, id)
+	if err != nil {
+		writeNotificationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *notificationController) SetDoNotDisturb(ctx *gin.Context) {
+	var req models.DoNotDisturb
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	settings, err := c.notificationService.SetDoNotDisturb(ctx.Param("username"), &req)
+	if err != nil {
+		writeNotificationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func (c *notificationController) ClearDoNotDisturb(ctx *gin.Context) {
+	settings, err := c.notificationService.ClearDoNotDisturb(ctx.Param("username"))
+	if err != nil {
+		writeNotificationError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, settings)
+}
+
+// conversationOf turns the group_id/receiver pair of a request into a conversation
+func conversationOf(groupID, receiver string) (string, string) {
+	if groupID != "" {
+		return models.ConversationGroup, groupID
+	}
+	return models.ConversationDirect, receiver
+}
+
+func writeNotificationError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"), strings.HasSuffix(err.Error(), "required"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type pushController struct {
+	pushService services.PushService
+}
+
+type PushController interface {
+	RegisterDevice(c *gin.Context)
+	ListDevices(c *gin.Context)
+	DeleteDevice(c *gin.Context)
+	GetVAPIDPublicKey(c *gin.Context)
+}
+
+func NewPushController(pushService services.PushService) PushController {
+	return &pushController{
+		pushService: pushService,
+	}
+}
+
+func (c *pushController) RegisterDevice(ctx *gin.Context) {
+	var req models.PushDevice
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Provider == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	device, err := c.pushService.RegisterDevice(ctx.Param("username"), &req)
+	if err != nil {
+		writePushError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, device)
+}
+
+func (c *pushController) ListDevices(ctx *gin.Context) {
+	devices, err := c.pushService.ListDevices(ctx.Param("username"))
+	if err != nil {
+		writePushError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, devices)
+}
+
+func (c *pushController) DeleteDevice(ctx *gin.Context) {
+	if err := c.pushService.DeleteDevice(ctx.Param("username"), ctx.Param("id")); err != nil {
+		writePushError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Device removed"})
+}
+
+func (c *pushController) GetVAPIDPublicKey(ctx *gin.Context) {
+	key, err := c.pushService.VAPIDPublicKey()
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+func writePushError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid device"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type retentionController struct {
+	retentionService services.RetentionService
+}
+
+type RetentionController interface {
+	ListPolicies(c *gin.Context)
+	SetGlobalPolicy(c *gin.Context)
+	SetGroupPolicy(c *gin.Context)
+	DeleteGlobalPolicy(c *gin.Context)
+	DeleteGroupPolicy(c *gin.Context)
+	ListPurgeRuns(c *gin.Context)
+	Purge(c *gin.Context)
+	ListLegalHolds(c *gin.Context)
+	SetGroupLegalHold(c *gin.Context)
+	SetUserLegalHold(c *gin.Context)
+}
+
+func NewRetentionController(retentionService services.RetentionService) RetentionController {
+	return &retentionController{
+		retentionService: retentionService,
+	}
+}
+
+func (c *retentionController) ListPolicies(ctx *gin.Context) {
+	policies, err := c.retentionService.ListPolicies(ctx.Query("requester"))
+	if err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, policies)
+}
+
+func (c *retentionController) SetGlobalPolicy(ctx *gin.Context) {
+	c.setPolicy(ctx, "")
+}
+
+func (c *retentionController) SetGroupPolicy(ctx *gin.Context) {
+	c.setPolicy(ctx, ctx.Param("id"))
+}
+
+func (c *retentionController) setPolicy(ctx *gin.Context, groupID string) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		KeepDays  *int   `json:"keep_days" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	policy, err := c.retentionService.SetPolicy(groupID, *req.KeepDays, req.Requester)
+	if err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, policy)
+}
+
+func (c *retentionController) DeleteGlobalPolicy(ctx *gin.Context) {
+	c.deletePolicy(ctx, "")
+}
+
+func (c *retentionController) DeleteGroupPolicy(ctx *gin.Context) {
+	c.deletePolicy(ctx, ctx.Param("id"))
+}
+
+func (c *retentionController) deletePolicy(ctx *gin.Context, groupID string) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.retentionService.DeletePolicy(groupID, req.Requester); err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Retention policy removed"})
+}
+
+func (c *retentionController) ListPurgeRuns(ctx *gin.Context) {
+	runs, err := c.retentionService.ListPurgeRuns(ctx.Query("requester"))
+	if err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, runs)
+}
+
+func (c *retentionController) Purge(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	run, err := c.retentionService.Purge(req.Requester)
+	if err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, run)
+}
+
+func (c *retentionController) ListLegalHolds(ctx *gin.Context) {
+	holds, err := c.retentionService.ListLegalHolds(ctx.Query("requester"))
+	if err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, holds)
+}
+
+func (c *retentionController) SetGroupLegalHold(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Hold      *bool  `json:"hold" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.retentionService.SetGroupLegalHold(ctx.Param("id"), *req.Hold, req.Requester); err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Legal hold updated"})
+}
+
+func (c *retentionController) SetUserLegalHold(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+		Hold      *bool  `json:"hold" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.retentionService.SetUserLegalHold(ctx.Param("username"), *req.Hold, req.Requester); err != nil {
+		writeRetentionError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Legal hold updated"})
+}
+
+func writeRetentionError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid retention"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
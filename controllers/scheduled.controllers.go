@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type scheduledMessageController struct {
+	scheduledMessageService services.ScheduledMessageService
+}
+
+type ScheduledMessageController interface {
+	ListScheduledMessages(c *gin.Context)
+	UpdateScheduledMessage(c *gin.Context)
+	CancelScheduledMessage(c *gin.Context)
+}
+
+func NewScheduledMessageController(scheduledMessageService services.ScheduledMessageService) ScheduledMessageController {
+	return &scheduledMessageController{
+		scheduledMessageService: scheduledMessageService,
+	}
+}
+
+func (c *scheduledMessageController) ListScheduledMessages(ctx *gin.Context) {
+	messages, err := c.scheduledMessageService.ListScheduledMessages(ctx.Param("username"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, messages)
+}
+
+func (c *scheduledMessageController) UpdateScheduledMessage(ctx *gin.Context) {
+	var req struct {
+		Content *string    `json:"content"`
+		SendAt  *time.Time `json:"send_at"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	message, err := c.scheduledMessageService.UpdateScheduledMessage(ctx.Param("id"), ctx.Param("username"), req.Content, req.SendAt)
+	if err != nil {
+		writeScheduledMessageError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, message)
+}
+
+func (c *scheduledMessageController) CancelScheduledMessage(ctx *gin.Context) {
+	err := c.scheduledMessageService.CancelScheduledMessage(ctx.Param("id"), ctx.Param("username"))
+	if err != nil {
+		writeScheduledMessageError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Scheduled message cancelled"})
+}
+
+func writeScheduledMessageError(ctx *gin.Context, err error) {
+	switch {
+	case err.Error() == "scheduled message not found":
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "scheduled message already"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err.Error() == "content is required" || strings.HasPrefix(err.Error(), "send_at"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sseRetry is the reconnect delay suggested to EventSource clients, in milliseconds
+	sseRetry           = 3000
+	defaultPollTimeout = 25
+	maxFrameBytes      = 64 << 10
+)
+
+// streamController serves the fallback transports for clients that cannot keep a
+// WebSocket open: Server-Sent Events or long polling from server to client, and POST
+// from client to server. Both carry the same frames as the WebSocket.
+type streamController struct {
+	websocketService services.WebsocketService
+	botService       services.BotService
+}
+
+type StreamController interface {
+	HandleSSE(c *gin.Context)
+	HandlePoll(c *gin.Context)
+	PostFrame(c *gin.Context)
+	CloseStream(c *gin.Context)
+}
+
+func NewStreamController(websocketService services.WebsocketService, botService services.BotService) StreamController {
+	return &streamController{
+		websocketService: websocketService,
+		botService:       botService,
+	}
+}
+
+// HandleSSE opens a session and streams its frames as events until the client goes away.
+// The first event is the session frame carrying the session ID to post frames to.
+func (c *streamController) HandleSSE(ctx *gin.Context) {
+	username, bot, ok := connectionIdentity(ctx, c.botService)
+	if !ok {
+		return
+	}
+	if c.websocketService.IsShuttingDown() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+		return
+	}
+	conn, err := services.NewSSEConnection(ctx.Writer)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	if _, err := fmt.Fprintf(ctx.Writer, "retry: %d\n\n", sseRetry); err != nil {
+		return
+	}
+	ctx.Writer.Flush()
+
+	if c.open(ctx, username, bot, conn) == nil {
+		return
+	}
+	select {
+	case <-ctx.Request.Context().Done():
+		conn.Close(models.CloseGoingAway, "")
+	case <-conn.Done():
+	}
+}
+
+// HandlePoll returns the frames queued for a long-poll session, waiting up to timeout
+// seconds for the first one. Without a session_id a new session is opened.
+func (c *streamController) HandlePoll(ctx *gin.Context) {
+	username, bot, ok := connectionIdentity(ctx, c.botService)
+	if !ok {
+		return
+	}
+	timeout := defaultPollTimeout
+	if raw := ctx.Query("timeout"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll timeout"})
+			return
+		}
+		timeout = parsed
+	}
+
+	var client *models.Client
+	if sessionID := ctx.Query("session_id"); sessionID != "" {
+		client = c.websocketService.GetSession(username, sessionID)
+		if client == nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "stream session not found"})
+			return
+		}
+		if client.Transport != models.TransportLongPoll {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid stream session: not a long-poll session"})
+			return
+		}
+	} else {
+		client = c.open(ctx, username, bot, services.NewLongPollConnection())
+		if client == nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+			return
+		}
+	}
+
+	frames, open := c.websocketService.PollStream(ctx.Request.Context(), client, time.Duration(timeout)*time.Second)
+	ctx.JSON(http.StatusOK, gin.H{
+		"session_id": client.SessionID,
+		"messages":   frames,
+		"closed":     !open,
+	})
+}
+
+// open registers a new session on conn. Each device keeps its own session unless the
+// client opts into a single session.
+func (c *streamController) open(ctx *gin.Context, username string, bot *models.Bot, conn models.Connection) *models.Client {
+	deviceID := ctx.Query("device_id")
+	if bot != nil {
+		return c.websocketService.HandleBotConnection(bot, deviceID, conn)
+	}
+	return c.websocketService.HandleConnection(username, deviceID, ctx.Query("single_session") == "true", conn)
+}
+
+// PostFrame takes a frame from an SSE or long-poll client, as it would arrive on a WebSocket
+func (c *streamController) PostFrame(ctx *gin.Context) {
+	client, ok := c.stream(ctx)
+	if !ok {
+		return
+	}
+	frame, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxFrameBytes))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.websocketService.HandleStreamFrame(client, frame); err != nil {
+		writeStreamError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Frame accepted"})
+}
+
+func (c *streamController) CloseStream(ctx *gin.Context) {
+	client, ok := c.stream(ctx)
+	if !ok {
+		return
+	}
+	c.websocketService.CloseStream(client)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Stream closed"})
+}
+
+// stream looks up the session named in the path for the requesting user
+func (c *streamController) stream(ctx *gin.Context) (*models.Client, bool) {
+	username, _, ok := connectionIdentity(ctx, c.botService)
+	if !ok {
+		return nil, false
+	}
+	client := c.websocketService.GetSession(username, ctx.Param("session_id"))
+	if client == nil || client.Transport == models.TransportWebSocket {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "stream session not found"})
+		return nil, false
+	}
+	return client, true
+}
+
+func writeStreamError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"github.com/JomnoiZ/network-backend-group-13.git/services"
@@ -17,6 +18,7 @@ type UserController interface {
 	GetUser(c *gin.Context)
 	GetAllUsers(c *gin.Context)
 	CreateUser(c *gin.Context)
+	UpdateProfile(c *gin.Context)
 	ListOnlineUsers(c *gin.Context)
 	ListUserGroups(c *gin.Context)
 	GetDirectMessages(c *gin.Context)
@@ -63,6 +65,10 @@ func (c *userController) CreateUser(ctx *gin.Context) {
 		return
 	}
 	user, err := c.userService.CreateUser(&userDTO)
+	if err != nil && strings.HasSuffix(err.Error(), "already taken") {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -72,6 +78,29 @@ func (c *userController) CreateUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, user)
 }
 
+func (c *userController) UpdateProfile(ctx *gin.Context) {
+	username := ctx.Param("username")
+	var update models.UserProfileUpdate
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	user, err := c.userService.UpdateProfile(username, &update)
+	if err != nil {
+		if err.Error() == "user not found" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid profile") || err.Error() == "username is required" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
 func (c *userController) ListOnlineUsers(ctx *gin.Context) {
 	users, err := c.userService.ListOnlineUsers()
 	if err != nil {
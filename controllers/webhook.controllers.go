@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+type webhookController struct {
+	webhookService services.WebhookService
+}
+
+type WebhookController interface {
+	CreateWebhook(c *gin.Context)
+	ListWebhooks(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	Ping(c *gin.Context)
+	ListDeliveries(c *gin.Context)
+	Redeliver(c *gin.Context)
+}
+
+func NewWebhookController(webhookService services.WebhookService) WebhookController {
+	return &webhookController{
+		webhookService: webhookService,
+	}
+}
+
+func (c *webhookController) CreateWebhook(ctx *gin.Context) {
+	var req struct {
+		Requester string   `json:"requester" binding:"required"`
+		GroupID   string   `json:"group_id"`
+		URL       string   `json:"url" binding:"required"`
+		Events    []string `json:"events" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	webhook, err := c.webhookService.CreateWebhook(req.GroupID, req.URL, req.Events, req.Requester)
+	if err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, webhook)
+}
+
+func (c *webhookController) ListWebhooks(ctx *gin.Context) {
+	webhooks, err := c.webhookService.ListWebhooks(ctx.Query("group_id"), ctx.Query("requester"))
+	if err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, webhooks)
+}
+
+func (c *webhookController) DeleteWebhook(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.webhookService.DeleteWebhook(ctx.Param("id"), req.Requester); err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+func (c *webhookController) Ping(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	delivery, err := c.webhookService.Ping(ctx.Param("id"), req.Requester)
+	if err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, delivery)
+}
+
+func (c *webhookController) ListDeliveries(ctx *gin.Context) {
+	var limit int64
+	if raw := ctx.Query("limit"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook query: limit must be between 1 and 500"})
+			return
+		}
+		limit = parsed
+	}
+	deliveries, err := c.webhookService.ListDeliveries(ctx.Param("id"), ctx.Query("status"), limit, ctx.Query("requester"))
+	if err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, deliveries)
+}
+
+func (c *webhookController) Redeliver(ctx *gin.Context) {
+	var req struct {
+		Requester string `json:"requester" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := c.webhookService.Redeliver(ctx.Param("id"), ctx.Param("delivery_id"), req.Requester); err != nil {
+		writeWebhookError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued"})
+}
+
+func writeWebhookError(ctx *gin.Context, err error) {
+	switch {
+	case strings.HasPrefix(err.Error(), "unauthorized"):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "invalid webhook"):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasPrefix(err.Error(), "only dead"):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
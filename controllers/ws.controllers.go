@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"github.com/JomnoiZ/network-backend-group-13.git/services"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -11,22 +12,27 @@ import (
 
 type websocketController struct {
     websocketService services.WebsocketService
+    botService       services.BotService
 }
 
 type WebsocketController interface {
     HandleWebSocket(c *gin.Context)
 }
 
-func NewWebsocketController(websocketService services.WebsocketService) WebsocketController {
+func NewWebsocketController(websocketService services.WebsocketService, botService services.BotService) WebsocketController {
     return &websocketController{
         websocketService: websocketService,
+        botService:       botService,
     }
 }
 
 func (c *websocketController) HandleWebSocket(ctx *gin.Context) {
-    username := ctx.Query("username")
-    if username == "" {
-        ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing username"})
+    username, bot, ok := connectionIdentity(ctx, c.botService)
+    if !ok {
+        return
+    }
+    if c.websocketService.IsShuttingDown() {
+        ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
         return
     }
 
@@ -36,12 +42,45 @@ func (c *websocketController) HandleWebSocket(ctx *gin.Context) {
         CheckOrigin: func(r *http.Request) bool { return true },
     }
 
-    conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+    wsConn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
     if err != nil {
         log.Printf("WebSocket upgrade error for user %s: %v", username, err)
         ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to establish WebSocket connection"})
         return
     }
 
-    c.websocketService.HandleConnection(username, conn)
-}
\ No newline at end of file
+    conn := services.NewWebSocketConnection(wsConn)
+
+    // Each device keeps its own session unless the client opts into a single session
+    deviceID := ctx.Query("device_id")
+    if bot != nil {
+        c.websocketService.HandleBotConnection(bot, deviceID, conn)
+        return
+    }
+    singleSession := ctx.Query("single_session") == "true"
+    c.websocketService.HandleConnection(username, deviceID, singleSession, conn)
+}
+
+// connectionIdentity works out who is connecting, whatever the transport. Bots
+// authenticate with their API key, people with a username. It writes the error
+// response itself when the request is rejected.
+func connectionIdentity(ctx *gin.Context, botService services.BotService) (string, *models.Bot, bool) {
+    username := ctx.Query("username")
+    if key := botAPIKey(ctx); key != "" {
+        bot, err := botService.Authenticate(key)
+        if err != nil {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return "", nil, false
+        }
+        return bot.Username, bot, true
+    }
+    if username == "" {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": "Missing username"})
+        return "", nil, false
+    }
+    if botService.IsBot(username) {
+        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Bots must connect with their API key"})
+        return "", nil, false
+    }
+    return username, nil, true
+}
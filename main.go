@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/JomnoiZ/network-backend-group-13.git/configs"
 	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
@@ -12,17 +18,77 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long a shutdown may take to drain connections and workers
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize MongoDB
 	mongoClient := configs.NewMongoDBClient()
 	userRepo := database.NewMongoUserRepository(mongoClient)
 	groupRepo := database.NewMongoGroupRepository(mongoClient)
 	messageRepo := database.NewMongoMessageRepository(mongoClient)
+	contactRepo := database.NewMongoContactRepository(mongoClient)
+	readCursorRepo := database.NewMongoReadCursorRepository(mongoClient)
+	scheduledRepo := database.NewMongoScheduledMessageRepository(mongoClient)
+	settingsRepo := database.NewMongoConversationSettingsRepository(mongoClient)
+	retentionRepo := database.NewMongoRetentionRepository(mongoClient)
+	auditRepo := database.NewMongoAuditRepository(mongoClient)
+	moderationRepo := database.NewMongoModerationRepository(mongoClient)
+	webhookRepo := database.NewMongoWebhookRepository(mongoClient)
+	botRepo := database.NewMongoBotRepository(mongoClient)
+	commandRepo := database.NewMongoCommandRepository(mongoClient)
+	notificationRepo := database.NewMongoNotificationSettingsRepository(mongoClient)
+	pushDeviceRepo := database.NewMongoPushDeviceRepository(mongoClient)
+	digestRepo := database.NewMongoDigestSettingsRepository(mongoClient)
 
 	// Initialize services
-	websocketService := services.NewWebsocketService(messageRepo)
-	userService := services.NewUserService(userRepo, messageRepo, websocketService)
-	groupService := services.NewGroupService(groupRepo, userRepo, messageRepo, websocketService)
+	moderator := services.NewMessageModerator(moderationRepo, services.NewConfiguredMessageFilters(configs.LoadModerationConfig(), moderationRepo)...)
+	webhookService := services.NewWebhookService(webhookRepo, groupRepo)
+	notificationService := services.NewNotificationService(notificationRepo, userRepo, groupRepo)
+	pushConfig := configs.LoadPushConfig()
+	pushProviders, err := services.NewConfiguredPushProviders(pushConfig)
+	if err != nil {
+		log.Fatalf("Failed to configure push notifications: %v", err)
+	}
+	pushService := services.NewPushService(pushDeviceRepo, userRepo, groupRepo, notificationService, pushConfig, pushProviders...)
+	websocketService := services.NewWebsocketService(messageRepo, userRepo, contactRepo, scheduledRepo, groupRepo, settingsRepo, moderator, webhookService, notificationService, pushService)
+	userService := services.NewUserService(userRepo, messageRepo, botRepo, websocketService)
+	auditService := services.NewAuditService(auditRepo, groupRepo)
+	groupService := services.NewGroupService(groupRepo, userRepo, messageRepo, websocketService, auditService, webhookService)
+	contactService := services.NewContactService(contactRepo, userRepo, websocketService)
+	conversationService := services.NewConversationService(userRepo, messageRepo, readCursorRepo, settingsRepo, websocketService)
+	scheduledMessageService := services.NewScheduledMessageService(scheduledRepo, websocketService)
+	messageExpiryService := services.NewMessageExpiryService(messageRepo, websocketService)
+	retentionService := services.NewRetentionService(retentionRepo, groupRepo, userRepo, messageRepo, auditService)
+	complianceService := services.NewComplianceService(userRepo, groupRepo, messageRepo)
+	moderationService := services.NewModerationService(moderationRepo, groupRepo, messageRepo, groupService, websocketService)
+	botService := services.NewBotService(botRepo, userRepo, groupRepo, auditService, websocketService)
+	commandService := services.NewCommandService(commandRepo, groupRepo, userRepo, botRepo, groupService, moderator)
+	websocketService.SetCommandExecutor(commandService)
+	mailConfig := configs.LoadMailConfig()
+	mailTransport, err := services.NewConfiguredMailTransport(mailConfig)
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+	digestService := services.NewDigestService(digestRepo, userRepo, messageRepo, readCursorRepo, notificationRepo, contactRepo, websocketService, mailTransport, mailConfig, configs.LoadDigestConfig())
+	accountService := services.NewAccountService(userRepo, groupRepo, messageRepo, contactRepo, readCursorRepo, scheduledRepo, settingsRepo, notificationRepo, pushDeviceRepo, digestRepo, complianceService, botService, auditService, websocketService)
+
+	// Start background workers; they stop when workerCtx is cancelled on shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range []func(context.Context){
+		scheduledMessageService.Run,
+		messageExpiryService.Run,
+		retentionService.Run,
+		webhookService.Run,
+		digestService.Run,
+	} {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(workerCtx)
+		}(run)
+	}
 
 	// Set up Gin router
 	r := gin.Default()
@@ -30,7 +96,7 @@ func main() {
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -40,9 +106,24 @@ func main() {
 	})
 
 	// Set up routes
-	routes.WebsocketRoute(websocketService, r)
+	routes.WebsocketRoute(websocketService, botService, r)
+	routes.StreamRoute(r, websocketService, botService)
 	routes.UserRoute(r, userService, websocketService)
 	routes.GroupRoute(r, groupService)
+	routes.ContactRoute(r, contactService)
+	routes.ConversationRoute(r, conversationService)
+	routes.ScheduledMessageRoute(r, scheduledMessageService)
+	routes.RetentionRoute(r, retentionService)
+	routes.ComplianceRoute(r, complianceService)
+	routes.AccountRoute(r, accountService)
+	routes.AuditRoute(r, auditService)
+	routes.ModerationRoute(r, moderationService)
+	routes.WebhookRoute(r, webhookService)
+	routes.BotRoute(r, botService)
+	routes.CommandRoute(r, commandService)
+	routes.NotificationRoute(r, notificationService)
+	routes.PushRoute(r, pushService)
+	routes.DigestRoute(r, digestService)
 
 	// Serve static files under /static/
 	r.Static("/static", "./public")
@@ -52,6 +133,46 @@ func main() {
 		c.File("./public/index.html")
 	})
 
-	fmt.Println("Server running on PORT 8080")
-	log.Fatal(r.Run(":8080"))
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		fmt.Println("Server running on PORT 8080")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Close the sockets first: event streams and long polls are HTTP requests that the
+	// server would otherwise wait for
+	if err := websocketService.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Connections did not drain: %v", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not shut down cleanly: %v", err)
+	}
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		log.Printf("Background workers did not stop in time")
+	}
+	pushService.Flush(shutdownCtx)
+
+	if err := mongoClient.Disconnect(shutdownCtx); err != nil {
+		log.Printf("Failed to disconnect from MongoDB: %v", err)
+	}
+	log.Println("Server stopped")
 }
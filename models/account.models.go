@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// What happens to the messages of a deleted account
+const (
+	DeletedMessagesAnonymize = "anonymize"
+	DeletedMessagesDelete    = "delete"
+)
+
+// DeletedUserPrefix starts the pseudonym that replaces a deleted username on kept messages
+const DeletedUserPrefix = "deleted-"
+
+type AccountDeletion struct {
+	Username           string    `json:"username"`
+	Pseudonym          string    `json:"pseudonym"`
+	MessagePolicy      string    `json:"message_policy"`
+	MessagesDeleted    int64     `json:"messages_deleted"`
+	MessagesAnonymized int64     `json:"messages_anonymized"`
+	GroupsLeft         []string  `json:"groups_left"`
+	GroupsTransferred  []string  `json:"groups_transferred"`
+	GroupsDeleted      []string  `json:"groups_deleted"`
+	BotsDeleted        []string  `json:"bots_deleted"`
+	DeletedAt          time.Time `json:"deleted_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	AuditGroupCreated      = "group.created"
+	AuditMemberAdded       = "group.member_added"
+	AuditMemberKicked      = "group.member_kicked"
+	AuditAdminAdded        = "group.admin_added"
+	AuditAdminRemoved      = "group.admin_removed"
+	AuditMessageTTLChanged = "group.message_ttl_changed"
+	AuditTopicChanged      = "group.topic_changed"
+	AuditMemberMuted       = "group.member_muted"
+	AuditMemberUnmuted     = "group.member_unmuted"
+	AuditMemberBanned      = "group.member_banned"
+	AuditMemberUnbanned    = "group.member_unbanned"
+	AuditMemberLeft        = "group.member_left"
+	AuditMemberRemoved     = "group.member_removed"
+	AuditOwnerChanged      = "group.owner_changed"
+	AuditGroupDeleted      = "group.deleted"
+	AuditLegalHoldSet      = "legal_hold.set"
+	AuditLegalHoldReleased = "legal_hold.released"
+)
+
+// AuditEntry records one administrative action. Before and After hold the group as it
+// was on either side of the change; Before is empty for a creation. Legal hold entries
+// also carry LegalHold, since a user's hold has no group to show it.
+type AuditEntry struct {
+	ID        string          `bson:"id" json:"id"`
+	Actor     string          `bson:"actor" json:"actor"`
+	Action    string          `bson:"action" json:"action"`
+	Target    string          `bson:"target,omitempty" json:"target,omitempty"`
+	GroupID   string          `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Before    *AuditGroup     `bson:"before,omitempty" json:"before,omitempty"`
+	After     *AuditGroup     `bson:"after,omitempty" json:"after,omitempty"`
+	LegalHold *AuditLegalHold `bson:"legal_hold,omitempty" json:"legal_hold,omitempty"`
+	CreatedAt time.Time       `bson:"created_at" json:"created_at"`
+}
+
+// AuditGroup is a group as recorded in the audit log. It is stored like a Group but,
+// unlike API responses, shows the fields audited actions change.
+type AuditGroup struct {
+	ID         string      `bson:"id" json:"id"`
+	Name       string      `bson:"name" json:"name"`
+	Topic      string      `bson:"topic,omitempty" json:"topic,omitempty"`
+	Owner      string      `bson:"owner" json:"owner"`
+	Admins     []string    `bson:"admins" json:"admins"`
+	Members    []string    `bson:"members" json:"members"`
+	MessageTTL int64       `bson:"message_ttl,omitempty" json:"message_ttl,omitempty"`
+	LegalHold  bool        `bson:"legal_hold,omitempty" json:"legal_hold"`
+	Mutes      []GroupMute `bson:"mutes,omitempty" json:"mutes,omitempty"`
+	Bans       []GroupBan  `bson:"bans,omitempty" json:"bans,omitempty"`
+	CreatedAt  time.Time   `bson:"created_at" json:"created_at"`
+}
+
+// AuditLegalHold is the legal hold of the group or user an entry targets, on either side
+// of the change
+type AuditLegalHold struct {
+	Before bool `bson:"before" json:"before"`
+	After  bool `bson:"after" json:"after"`
+}
+
+// AuditFilter selects audit entries, newest first. Entries created at or after Before
+// are skipped so pages can be walked backwards.
+type AuditFilter struct {
+	GroupID string
+	Actor   string
+	Action  string
+	Before  *time.Time
+	Limit   int64
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// BotKeyPrefix starts every bot API key so leaked keys are easy to recognise
+const BotKeyPrefix = "bot_"
+
+// Bot is an automated account. Bots live apart from users, authenticate with an API
+// key instead of a username and may only post into groups they were added to.
+type Bot struct {
+	Username    string `bson:"username" json:"username"`
+	DisplayName string `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Owner       string `bson:"owner" json:"owner"`
+	// KeyHash is the SHA-256 of the API key, the key itself is never stored
+	KeyHash    string     `bson:"key_hash" json:"-"`
+	KeyHint    string     `bson:"key_hint" json:"key_hint"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+}
+
+// BotCredentials is returned when a bot is created or its key is rotated, the only
+// times the API key is shown
+type BotCredentials struct {
+	Bot    *Bot   `json:"bot"`
+	APIKey string `json:"api_key"`
+}
+
+// BotMessage is a message posted through the bot API
+type BotMessage struct {
+	GroupID string `json:"group_id" binding:"required"`
+	Content string `json:"content" binding:"required"`
+}
@@ -1,10 +1,82 @@
 package models
 
-import "github.com/gorilla/websocket"
+import (
+    "sync"
+    "time"
+)
 
+// Client transports
+const (
+    TransportWebSocket = "websocket"
+    TransportSSE       = "sse"
+    TransportLongPoll  = "long_poll"
+)
+
+// Close codes, as defined for WebSocket close frames
+const (
+    CloseNormal    = 1000
+    CloseGoingAway = 1001
+)
+
+// Connection is the transport under a client session. The hub reads from it in one
+// goroutine and writes to it in another; Close may be called from anywhere.
+type Connection interface {
+    Transport() string
+    // ReadFrame blocks until the client sends a frame. An error ends the session;
+    // io.EOF means the connection was closed normally.
+    ReadFrame() ([]byte, error)
+    WriteFrame(frame []byte) error
+    // Ping checks that the peer is still there, where the transport can tell
+    Ping() error
+    // Close ends the connection, telling the peer why when the transport allows it
+    Close(code int, reason string) error
+}
+
+// Client is one session of a user
 type Client struct {
-    Username string           `json:"username"`
-    Conn     *websocket.Conn  `json:"-"`
-    Send     chan []byte      `json:"-"`
-    Groups   map[string]bool  `json:"groups"`
-}
\ No newline at end of file
+    Username     string      `json:"username"`
+    SessionID    string      `json:"session_id"`
+    DeviceID     string      `json:"device_id"`
+    Bot          bool        `json:"bot,omitempty"`
+    Transport    string      `json:"transport"`
+    Conn         Connection  `json:"-"`
+    Send         chan []byte `json:"-"`
+    LastActivity time.Time   `json:"-"`
+    // Closing asks the write pump to send the frames already queued and the Farewell
+    // frame, then close the connection with CloseCode and CloseReason
+    Closing      chan struct{} `json:"-"`
+    CloseCode    int           `json:"-"`
+    CloseReason  string        `json:"-"`
+    Farewell     []byte        `json:"-"`
+
+    closeSend sync.Once
+    closing   sync.Once
+}
+
+// RequestClose asks the write pump to close the connection once the queued frames and
+// farewell, which may be nil, are written. Only the first request counts.
+func (c *Client) RequestClose(code int, reason string, farewell []byte) {
+    c.closing.Do(func() {
+        if c.Closing != nil {
+            c.CloseCode, c.CloseReason, c.Farewell = code, reason, farewell
+            close(c.Closing)
+        }
+    })
+}
+
+// CloseRequested reports whether RequestClose was called
+func (c *Client) CloseRequested() bool {
+    select {
+    case <-c.Closing:
+        return true
+    default:
+        return false
+    }
+}
+
+// CloseSend closes the Send channel; it is safe to call more than once
+func (c *Client) CloseSend() {
+    c.closeSend.Do(func() {
+        close(c.Send)
+    })
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// CommandInvocation is one use of a slash command typed into a conversation
+type CommandInvocation struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Text is everything after the command name, as typed
+	Text     string `json:"text"`
+	Invoker  string `json:"invoker"`
+	GroupID  string `json:"group_id,omitempty"`
+	Receiver string `json:"receiver,omitempty"`
+}
+
+// CommandInfo describes a command for /help and the command listing
+type CommandInfo struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description,omitempty"`
+	Builtin     bool   `json:"builtin"`
+	HookID      string `json:"hook_id,omitempty"`
+}
+
+// CommandHook is a slash command answered by an external URL. A hook without GroupID is
+// global and available everywhere, a group hook wins over a global one of the same name.
+type CommandHook struct {
+	ID          string `bson:"id" json:"id"`
+	Name        string `bson:"name" json:"name"`
+	GroupID     string `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	URL         string `bson:"url" json:"url"`
+	Usage       string `bson:"usage,omitempty" json:"usage,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	// Secret signs every invocation; it is only returned when the hook is created
+	Secret    string    `bson:"secret" json:"secret,omitempty"`
+	CreatedBy string    `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// CommandHookReply is what a command hook answers with
+type CommandHookReply struct {
+	Content string `json:"content"`
+}
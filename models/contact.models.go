@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	FriendRequestPending  = "pending"
+	FriendRequestAccepted = "accepted"
+	FriendRequestRejected = "rejected"
+)
+
+type FriendRequest struct {
+	ID          string     `bson:"id" json:"id"`
+	From        string     `bson:"from" json:"from"`
+	To          string     `bson:"to" json:"to"`
+	Status      string     `bson:"status" json:"status"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	RespondedAt *time.Time `bson:"responded_at,omitempty" json:"responded_at,omitempty"`
+}
+
+// Contact is one direction of a friendship; accepting a request stores both directions
+type Contact struct {
+	Username  string    `bson:"username" json:"username"`
+	Contact   string    `bson:"contact" json:"contact"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type Block struct {
+	Blocker   string    `bson:"blocker" json:"blocker"`
+	Blocked   string    `bson:"blocked" json:"blocked"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ConversationGroup  = "group"
+	ConversationDirect = "direct"
+)
+
+// Conversation is a sidebar entry: a group or a direct message thread seen from one user
+type Conversation struct {
+	Type         string     `json:"type"`
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	LastMessage  *MessageDB `json:"last_message,omitempty"`
+	UnreadCount  int64      `json:"unread_count"`
+	LastActivity time.Time  `json:"last_activity"`
+}
+
+// ReadCursor marks how far a user has read a conversation
+type ReadCursor struct {
+	Username       string    `bson:"username" json:"username"`
+	ConversationID string    `bson:"conversation_id" json:"conversation_id"`
+	LastReadAt     time.Time `bson:"last_read_at" json:"last_read_at"`
+}
+
+// ConversationKey identifies a conversation from the point of view of one user:
+// "group:<group id>" or "direct:<other username>"
+func ConversationKey(conversationType, id string) string {
+	return conversationType + ":" + id
+}
+
+// ParseConversationKey splits a key built by ConversationKey
+func ParseConversationKey(key string) (string, string, bool) {
+	conversationType, id, found := strings.Cut(key, ":")
+	if !found || id == "" || (conversationType != ConversationGroup && conversationType != ConversationDirect) {
+		return "", "", false
+	}
+	return conversationType, id, true
+}
+
+// DirectConversationSettings are shared by both participants of a direct thread
+type DirectConversationSettings struct {
+	Key          string    `bson:"key" json:"-"`
+	Participants []string  `bson:"participants" json:"participants"`
+	MessageTTL   int64     `bson:"message_ttl" json:"message_ttl"`
+	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DirectPairKey identifies a direct thread independently of who is asking
+func DirectPairKey(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
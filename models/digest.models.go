@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Digest frequencies
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSettings opt a user into e-mail digests of what they missed while offline
+type DigestSettings struct {
+	Username   string     `bson:"username" json:"username"`
+	Email      string     `bson:"email" json:"email"`
+	Enabled    bool       `bson:"enabled" json:"enabled"`
+	Frequency  string     `bson:"frequency" json:"frequency"`
+	LastSentAt *time.Time `bson:"last_sent_at,omitempty" json:"last_sent_at,omitempty"`
+	UpdatedAt  time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// DigestPeriod returns the minimum time between two digests of a frequency
+func DigestPeriod(frequency string) (time.Duration, bool) {
+	switch frequency {
+	case DigestHourly:
+		return time.Hour, true
+	case DigestDaily:
+		return 24 * time.Hour, true
+	case DigestWeekly:
+		return 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// Digest is the unread messages of one user, by conversation
+type Digest struct {
+	Username      string                `json:"username"`
+	Since         time.Time             `json:"since"`
+	Conversations []*DigestConversation `json:"conversations"`
+}
+
+// DigestConversation lists the first unread messages of a conversation; Total counts all of them
+type DigestConversation struct {
+	Type     string       `json:"type"`
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Messages []*MessageDB `json:"messages"`
+	Total    int64        `json:"total"`
+}
+
+// MessageCount returns the number of unread messages in the digest
+func (d *Digest) MessageCount() int64 {
+	var total int64
+	for _, conversation := range d.Conversations {
+		total += conversation.Total
+	}
+	return total
+}
+
+// MailMessage is a plain text e-mail
+type MailMessage struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// DigestSettingsUpdate is a partial digest settings update; nil fields are left untouched
+type DigestSettingsUpdate struct {
+	Email     *string `json:"email"`
+	Enabled   *bool   `json:"enabled"`
+	Frequency *string `json:"frequency"`
+}
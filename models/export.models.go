@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+const ExportFormatVersion = 1
+
+// ExportFilter scopes a compliance export; empty fields are not filtered on
+type ExportFilter struct {
+	Username string     `json:"username,omitempty"`
+	GroupID  string     `json:"group_id,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+}
+
+// ExportManifest is written last in an export archive and describes its content
+type ExportManifest struct {
+	Version     int            `json:"version"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	GeneratedBy string         `json:"generated_by"`
+	Filter      ExportFilter   `json:"filter"`
+	Counts      map[string]int `json:"counts"`
+}
+
+// ExportedUser is a user as written to an export archive, including the fields kept
+// from API responses
+type ExportedUser struct {
+	*User
+	LegalHold bool `json:"legal_hold,omitempty"`
+}
+
+// ExportedGroup is a group as written to an export archive, including the fields kept
+// from API responses
+type ExportedGroup struct {
+	*Group
+	LegalHold bool        `json:"legal_hold,omitempty"`
+	Mutes     []GroupMute `json:"mutes,omitempty"`
+	Bans      []GroupBan  `json:"bans,omitempty"`
+}
+
+// AttachmentEntry describes a file referenced by an exported message
+type AttachmentEntry struct {
+	MessageID string `json:"message_id"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+}
+
+type ImportResult struct {
+	Users    int `json:"users"`
+	Groups   int `json:"groups"`
+	Messages int `json:"messages"`
+}
@@ -3,10 +3,76 @@ package models
 import "time"
 
 type Group struct {
-    ID        string    `bson:"id" json:"id"`
-    Name      string    `bson:"name" json:"name"`
-    Owner     string    `bson:"owner" json:"owner"`
-    Admins    []string  `bson:"admins" json:"admins"`
-    Members   []string  `bson:"members" json:"members"`
-    CreatedAt time.Time `bson:"created_at" json:"created_at"`
+    ID         string      `bson:"id" json:"id"`
+    Name       string      `bson:"name" json:"name"`
+    Topic      string      `bson:"topic,omitempty" json:"topic,omitempty"`
+    Owner      string      `bson:"owner" json:"owner"`
+    Admins     []string    `bson:"admins" json:"admins"`
+    Members    []string    `bson:"members" json:"members"`
+    // MessageTTL is the number of seconds messages are kept, zero keeps them forever
+    MessageTTL int64       `bson:"message_ttl,omitempty" json:"message_ttl,omitempty"`
+    // LegalHold suspends every deletion of the group's messages. Only system admins
+    // see it, through the legal hold endpoints.
+    LegalHold  bool        `bson:"legal_hold,omitempty" json:"-"`
+    // Mutes and Bans are only served to moderators, through the mute and ban endpoints
+    Mutes      []GroupMute `bson:"mutes,omitempty" json:"-"`
+    Bans       []GroupBan  `bson:"bans,omitempty" json:"-"`
+    CreatedAt  time.Time   `bson:"created_at" json:"created_at"`
+}
+
+// GroupMute stops a member from posting until Until, or for good when Until is nil
+type GroupMute struct {
+    Username string     `bson:"username" json:"username"`
+    Until    *time.Time `bson:"until,omitempty" json:"until,omitempty"`
+    MutedBy  string     `bson:"muted_by" json:"muted_by"`
+    MutedAt  time.Time  `bson:"muted_at" json:"muted_at"`
+}
+
+// GroupBan keeps a user out of the group until ExpiresAt, or for good when it is nil
+type GroupBan struct {
+    Username  string     `bson:"username" json:"username"`
+    Reason    string     `bson:"reason,omitempty" json:"reason,omitempty"`
+    BannedBy  string     `bson:"banned_by" json:"banned_by"`
+    BannedAt  time.Time  `bson:"banned_at" json:"banned_at"`
+    ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// Active reports whether the ban is still in effect at now
+func (b *GroupBan) Active(now time.Time) bool {
+    return b.ExpiresAt == nil || b.ExpiresAt.After(now)
+}
+
+// IsMember reports whether username belongs to the group
+func (g *Group) IsMember(username string) bool {
+    for _, member := range g.Members {
+        if member == username {
+            return true
+        }
+    }
+    return false
+}
+
+// IsMuted reports whether username has a mute in effect at now
+func (g *Group) IsMuted(username string, now time.Time) bool {
+    for _, mute := range g.Mutes {
+        if mute.Username == username && (mute.Until == nil || mute.Until.After(now)) {
+            return true
+        }
+    }
+    return false
+}
+
+// ActiveBan returns the ban keeping username out of the group at now, if any
+func (g *Group) ActiveBan(username string, now time.Time) *GroupBan {
+    for i := range g.Bans {
+        if g.Bans[i].Username == username && g.Bans[i].Active(now) {
+            return &g.Bans[i]
+        }
+    }
+    return nil
+}
+
+// IsBanned reports whether username is banned from the group at now
+func (g *Group) IsBanned(username string, now time.Time) bool {
+    return g.ActiveBan(username, now) != nil
 }
\ No newline at end of file
@@ -10,14 +10,32 @@ type Message struct {
     GroupID   string      `json:"group_id,omitempty"`
     Content   string      `json:"content,omitempty"`
     Status    string      `json:"status,omitempty"`
+    Bot       bool        `json:"bot,omitempty"`
+    Mentions  *Mentions   `json:"mentions,omitempty"`
     Data      interface{} `json:"data,omitempty"`
+    SendAt    *time.Time  `json:"send_at,omitempty"`
+    ExpiresAt *time.Time  `json:"expires_at,omitempty"`
 }
 
 type MessageDB struct {
-    ID        string    `bson:"id" json:"id"`
-    Sender    string    `bson:"sender" json:"sender"` 
-    Receiver  string    `bson:"receiver" json:"receiver,omitempty"`
-    GroupID   string    `bson:"group_id" json:"group_id,omitempty"`
-    Content   string    `bson:"content" json:"content"`
-    Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+    ID        string     `bson:"id" json:"id"`
+    Sender    string     `bson:"sender" json:"sender"`
+    Receiver  string     `bson:"receiver" json:"receiver,omitempty"`
+    GroupID   string     `bson:"group_id" json:"group_id,omitempty"`
+    Content   string     `bson:"content" json:"content"`
+    Bot       bool       `bson:"bot,omitempty" json:"bot,omitempty"`
+    Mentions  *Mentions  `bson:"mentions,omitempty" json:"mentions,omitempty"`
+    Timestamp time.Time  `bson:"timestamp" json:"timestamp"`
+    ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// Mentions records who a group message mentions. Users holds the members named with
+// @username; Admins and All are set by @admins and @all. Notified is every member the
+// mentions reach once @admins and @all are expanded, it backs the mentions inbox and is
+// kept in compliance exports.
+type Mentions struct {
+    Users    []string `bson:"users,omitempty" json:"users,omitempty"`
+    Admins   bool     `bson:"admins,omitempty" json:"admins,omitempty"`
+    All      bool     `bson:"all,omitempty" json:"all,omitempty"`
+    Notified []string `bson:"notified" json:"notified,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package models
+
+import "time"
+
+// Moderation actions a filter can take on a message, from least to most severe
+const (
+	ModerationAllow  = "allow"
+	ModerationMask   = "mask"
+	ModerationFlag   = "flag"
+	ModerationReject = "reject"
+)
+
+// ModerationSeverity orders actions so the strictest verdict of a filter chain wins
+func ModerationSeverity(action string) int {
+	switch action {
+	case ModerationMask:
+		return 1
+	case ModerationFlag:
+		return 2
+	case ModerationReject:
+		return 3
+	}
+	return 0
+}
+
+// ModerationVerdict is the outcome of running a message through one or more filters.
+// Content holds the message text after masking.
+type ModerationVerdict struct {
+	Action  string `json:"action"`
+	Content string `json:"content,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+}
+
+// ModerationRule is a regular expression checked against every message of a group
+type ModerationRule struct {
+	ID        string    `bson:"id" json:"id"`
+	GroupID   string    `bson:"group_id" json:"group_id"`
+	Pattern   string    `bson:"pattern" json:"pattern"`
+	Action    string    `bson:"action" json:"action"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedBy string    `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Where a review queue item came from
+const (
+	ModerationSourceFilter = "filter"
+	ModerationSourceReport = "report"
+)
+
+const (
+	ModerationItemPending  = "pending"
+	ModerationItemResolved = "resolved"
+)
+
+// Resolutions a moderator can apply to a queue item
+const (
+	ModerationDismiss       = "dismiss"
+	ModerationDeleteMessage = "delete_message"
+	ModerationMute          = "mute"
+	ModerationKick          = "kick"
+	ModerationBan           = "ban"
+)
+
+// ModerationDecision is what a moderator does with a queue item. Duration, in seconds,
+// bounds a mute or a ban and Reason is kept on a ban.
+type ModerationDecision struct {
+	Action   string `json:"action"`
+	Duration int64  `json:"duration,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ModerationItem is an entry of the moderator review queue. Items raised by a filter
+// concern a message; reports may concern a message or only a user.
+type ModerationItem struct {
+	ID           string     `bson:"id" json:"id"`
+	Source       string     `bson:"source" json:"source"`
+	Status       string     `bson:"status" json:"status"`
+	MessageID    string     `bson:"message_id,omitempty" json:"message_id,omitempty"`
+	Sender       string     `bson:"sender,omitempty" json:"sender,omitempty"`
+	Receiver     string     `bson:"receiver,omitempty" json:"receiver,omitempty"`
+	GroupID      string     `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Content      string     `bson:"content,omitempty" json:"content,omitempty"`
+	Action       string     `bson:"action,omitempty" json:"action,omitempty"`
+	Reason       string     `bson:"reason,omitempty" json:"reason,omitempty"`
+	Filter       string     `bson:"filter,omitempty" json:"filter,omitempty"`
+	Reporter     string     `bson:"reporter,omitempty" json:"reporter,omitempty"`
+	ReportedUser string     `bson:"reported_user,omitempty" json:"reported_user,omitempty"`
+	CreatedAt    time.Time  `bson:"created_at" json:"created_at"`
+	ReviewedBy   string     `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	Resolution   string     `bson:"resolution,omitempty" json:"resolution,omitempty"`
+}
+
+// Subject returns the user a moderation action on the item applies to
+func (item *ModerationItem) Subject() string {
+	if item.ReportedUser != "" {
+		return item.ReportedUser
+	}
+	return item.Sender
+}
+
+// Report is what a member submits about a message or another user
+type Report struct {
+	Reporter  string `json:"requester"`
+	MessageID string `json:"message_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	GroupID   string `json:"group_id,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// ModerationQueueFilter selects queue items, oldest first; an empty GroupID covers every item
+type ModerationQueueFilter struct {
+	GroupID string
+	Source  string
+	Status  string
+	Limit   int64
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Notification levels of a conversation
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+// NotificationSettings are one user's notification preferences. They only decide what
+// is pushed to the user (mention frames, push notifications); chat traffic itself is
+// always delivered.
+type NotificationSettings struct {
+	Username      string                     `bson:"username" json:"username"`
+	DoNotDisturb  *DoNotDisturb              `bson:"do_not_disturb,omitempty" json:"do_not_disturb,omitempty"`
+	Conversations []ConversationNotification `bson:"conversations" json:"conversations"`
+	UpdatedAt     time.Time                  `bson:"updated_at" json:"updated_at"`
+}
+
+// ConversationNotification overrides the level of one conversation, identified by its
+// ConversationKey. With Until set the override lapses back to NotifyAll at that time.
+type ConversationNotification struct {
+	Conversation string     `bson:"conversation" json:"conversation"`
+	Level        string     `bson:"level" json:"level"`
+	Until        *time.Time `bson:"until,omitempty" json:"until,omitempty"`
+}
+
+// DoNotDisturb silences every notification daily between Start and End, given as
+// "HH:MM" in TimeZone. A window that ends before it starts runs over midnight.
+type DoNotDisturb struct {
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+	TimeZone string `bson:"time_zone" json:"time_zone"`
+}
+
+// Active reports whether now falls inside the quiet hours
+func (d *DoNotDisturb) Active(now time.Time) bool {
+	start, errStart := ParseClock(d.Start)
+	end, errEnd := ParseClock(d.End)
+	location, errLocation := time.LoadLocation(d.TimeZone)
+	if errStart != nil || errEnd != nil || errLocation != nil || start == end {
+		return false
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// ParseClock turns "HH:MM" into minutes after midnight
+func ParseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// Level returns the notification level in effect for a conversation at now
+func (s *NotificationSettings) Level(conversation string, now time.Time) string {
+	for _, setting := range s.Conversations {
+		if setting.Conversation == conversation && (setting.Until == nil || setting.Until.After(now)) {
+			return setting.Level
+		}
+	}
+	return NotifyAll
+}
+
+// Allows reports whether something happening in a conversation may be pushed at now;
+// mentioned tells whether it mentions the user
+func (s *NotificationSettings) Allows(conversation string, mentioned bool, now time.Time) bool {
+	if s.DoNotDisturb != nil && s.DoNotDisturb.Active(now) {
+		return false
+	}
+	switch s.Level(conversation, now) {
+	case NotifyNone:
+		return false
+	case NotifyMentions:
+		return mentioned
+	default:
+		return true
+	}
+}
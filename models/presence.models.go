@@ -0,0 +1,18 @@
+package models
+
+const (
+	StatusOnline    = "online"
+	StatusAway      = "away"
+	StatusBusy      = "busy"
+	StatusInvisible = "invisible"
+	StatusOffline   = "offline"
+)
+
+// IsSelectableStatus reports whether a client may set the status itself
+func IsSelectableStatus(status string) bool {
+	switch status {
+	case StatusOnline, StatusAway, StatusBusy, StatusInvisible:
+		return true
+	}
+	return false
+}
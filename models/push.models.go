@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Push providers a device can register with
+const (
+	PushProviderWebPush = "webpush"
+	PushProviderHTTP    = "http"
+)
+
+// PushDevice is somewhere a user receives push notifications while offline
+type PushDevice struct {
+	ID       string `bson:"id" json:"id"`
+	Username string `bson:"username" json:"username"`
+	Provider string `bson:"provider" json:"provider"`
+	// Token identifies the device to the http provider
+	Token string `bson:"token,omitempty" json:"token,omitempty"`
+	// Subscription is the browser's PushSubscription for web push
+	Subscription *WebPushSubscription `bson:"subscription,omitempty" json:"subscription,omitempty"`
+	Label        string               `bson:"label,omitempty" json:"label,omitempty"`
+	// Address is the token or endpoint; registering an address again replaces the
+	// device, even when it belonged to another user
+	Address   string    `bson:"address" json:"-"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// WebPushSubscription mirrors PushSubscription.toJSON() in the browser
+type WebPushSubscription struct {
+	Endpoint string `bson:"endpoint" json:"endpoint"`
+	Keys     struct {
+		P256dh string `bson:"p256dh" json:"p256dh"`
+		Auth   string `bson:"auth" json:"auth"`
+	} `bson:"keys" json:"keys"`
+}
+
+// PushNotification is what is sent to a device. Notifications for the same
+// conversation that arrive close together are merged: Count and MessageIDs grow and
+// Body describes the latest message.
+type PushNotification struct {
+	Username     string    `json:"username"`
+	Conversation string    `json:"conversation"`
+	GroupID      string    `json:"group_id,omitempty"`
+	Sender       string    `json:"sender"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body"`
+	Mentioned    bool      `json:"mentioned,omitempty"`
+	Count        int       `json:"count"`
+	MessageIDs   []string  `json:"message_ids"`
+	CreatedAt    time.Time `json:"created_at"`
+}
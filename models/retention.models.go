@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+const (
+	RetentionScopeGlobal = "global"
+	RetentionScopeGroup  = "group"
+)
+
+// RetentionPolicy keeps messages for KeepDays days; zero keeps them forever.
+// A group policy takes precedence over the global one for that group.
+type RetentionPolicy struct {
+	ID        string    `bson:"id" json:"id"`
+	Scope     string    `bson:"scope" json:"scope"`
+	GroupID   string    `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	KeepDays  int       `bson:"keep_days" json:"keep_days"`
+	UpdatedBy string    `bson:"updated_by" json:"updated_by"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// LegalHolds lists the groups and users whose messages are on legal hold
+type LegalHolds struct {
+	Groups []string `json:"groups"`
+	Users  []string `json:"users"`
+}
+
+// RetentionPolicyID returns the id of the global policy or of a group's policy
+func RetentionPolicyID(groupID string) string {
+	if groupID == "" {
+		return RetentionScopeGlobal
+	}
+	return RetentionScopeGroup + ":" + groupID
+}
+
+// MessagePurgeFilter selects messages older than Before. When GroupID is empty the
+// filter covers direct messages and every group that is not excluded.
+type MessagePurgeFilter struct {
+	Before          time.Time
+	GroupID         string
+	ExcludeGroupIDs []string
+	ExcludeUsers    []string
+}
+
+// PurgeRun is the audit record of one execution of the retention purge
+type PurgeRun struct {
+	ID          string        `bson:"id" json:"id"`
+	TriggeredBy string        `bson:"triggered_by" json:"triggered_by"`
+	StartedAt   time.Time     `bson:"started_at" json:"started_at"`
+	FinishedAt  time.Time     `bson:"finished_at" json:"finished_at"`
+	Policies    []PurgeResult `bson:"policies" json:"policies"`
+	Deleted     int64         `bson:"deleted" json:"deleted"`
+	HeldGroups  []string      `bson:"held_groups" json:"held_groups"`
+	HeldUsers   []string      `bson:"held_users" json:"held_users"`
+	Error       string        `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+type PurgeResult struct {
+	PolicyID string    `bson:"policy_id" json:"policy_id"`
+	KeepDays int       `bson:"keep_days" json:"keep_days"`
+	Cutoff   time.Time `bson:"cutoff" json:"cutoff"`
+	Deleted  int64     `bson:"deleted" json:"deleted"`
+	Error    string    `bson:"error,omitempty" json:"error,omitempty"`
+}
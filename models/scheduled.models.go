@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	ScheduledPending   = "pending"
+	ScheduledSending   = "sending"
+	ScheduledSent      = "sent"
+	ScheduledCancelled = "cancelled"
+)
+
+// ScheduledMessage is a chat message held back until SendAt
+type ScheduledMessage struct {
+	ID        string     `bson:"id" json:"id"`
+	Sender    string     `bson:"sender" json:"sender"`
+	Receiver  string     `bson:"receiver" json:"receiver,omitempty"`
+	GroupID   string     `bson:"group_id" json:"group_id,omitempty"`
+	Content   string     `bson:"content" json:"content"`
+	SendAt    time.Time  `bson:"send_at" json:"send_at"`
+	Status    string     `bson:"status" json:"status"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+	ClaimedAt *time.Time `bson:"claimed_at,omitempty" json:"-"`
+	SentAt    *time.Time `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+}
@@ -3,6 +3,46 @@ package models
 import "time"
 
 type User struct {
-    Username  string    `bson:"username" json:"username"`
-    CreatedAt time.Time `bson:"created_at" json:"created_at"`
+    Username      string     `bson:"username" json:"username"`
+    DisplayName   string     `bson:"display_name,omitempty" json:"display_name,omitempty"`
+    AvatarURL     string     `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+    Bio           string     `bson:"bio,omitempty" json:"bio,omitempty"`
+    TimeZone      string     `bson:"time_zone,omitempty" json:"time_zone,omitempty"`
+    StatusMessage string     `bson:"status_message,omitempty" json:"status_message,omitempty"`
+    Status        string     `bson:"-" json:"status,omitempty"`
+    LastSeen      *time.Time `bson:"last_seen,omitempty" json:"last_seen,omitempty"`
+    LegalHold     bool       `bson:"legal_hold,omitempty" json:"-"`
+    CreatedAt     time.Time  `bson:"created_at" json:"created_at"`
+    UpdatedAt     time.Time  `bson:"updated_at,omitempty" json:"updated_at"`
+}
+
+// PublicProfile is the part of a user's profile anyone who can see them may receive
+type PublicProfile struct {
+    Username      string `json:"username"`
+    DisplayName   string `json:"display_name,omitempty"`
+    AvatarURL     string `json:"avatar_url,omitempty"`
+    Bio           string `json:"bio,omitempty"`
+    TimeZone      string `json:"time_zone,omitempty"`
+    StatusMessage string `json:"status_message,omitempty"`
+}
+
+// PublicProfile returns the public fields of the user's profile
+func (u *User) PublicProfile() PublicProfile {
+    return PublicProfile{
+        Username:      u.Username,
+        DisplayName:   u.DisplayName,
+        AvatarURL:     u.AvatarURL,
+        Bio:           u.Bio,
+        TimeZone:      u.TimeZone,
+        StatusMessage: u.StatusMessage,
+    }
+}
+
+// UserProfileUpdate is a partial profile update; nil fields are left untouched
+type UserProfileUpdate struct {
+    DisplayName   *string `json:"display_name"`
+    AvatarURL     *string `json:"avatar_url"`
+    Bio           *string `json:"bio"`
+    TimeZone      *string `json:"time_zone"`
+    StatusMessage *string `json:"status_message"`
 }
\ No newline at end of file
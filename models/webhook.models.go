@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Events a webhook can subscribe to
+const (
+	WebhookMessagePosted = "message.posted"
+	WebhookGroupCreated  = "group.created"
+	WebhookMemberAdded   = "group.member_added"
+	WebhookMemberKicked  = "group.member_kicked"
+	WebhookAdminAdded    = "group.admin_added"
+	WebhookAdminRemoved  = "group.admin_removed"
+	// WebhookPing is only sent on request to check an endpoint
+	WebhookPing = "ping"
+)
+
+// WebhookEvents lists every event a webhook may subscribe to
+var WebhookEvents = []string{
+	WebhookMessagePosted,
+	WebhookGroupCreated,
+	WebhookMemberAdded,
+	WebhookMemberKicked,
+	WebhookAdminAdded,
+	WebhookAdminRemoved,
+}
+
+// Webhook posts events to an external URL. A webhook without GroupID is global and
+// receives the events of every group.
+type Webhook struct {
+	ID      string   `bson:"id" json:"id"`
+	GroupID string   `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	URL     string   `bson:"url" json:"url"`
+	Events  []string `bson:"events" json:"events"`
+	// Secret signs every payload; it is only returned when the webhook is created
+	Secret    string    `bson:"secret" json:"secret,omitempty"`
+	CreatedBy string    `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Subscribes reports whether the webhook wants an event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent is the JSON body posted to a webhook
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	GroupID    string      `json:"group_id,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySending   = "sending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead marks a delivery that ran out of attempts
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookDelivery is one event queued for one webhook, with the log of every attempt.
+// Payload keeps the exact bytes that are signed and sent.
+type WebhookDelivery struct {
+	ID            string           `bson:"id" json:"id"`
+	WebhookID     string           `bson:"webhook_id" json:"webhook_id"`
+	Event         string           `bson:"event" json:"event"`
+	Payload       string           `bson:"payload" json:"payload"`
+	Status        string           `bson:"status" json:"status"`
+	AttemptCount  int              `bson:"attempt_count" json:"attempt_count"`
+	NextAttemptAt time.Time        `bson:"next_attempt_at" json:"next_attempt_at"`
+	ClaimedAt     *time.Time       `bson:"claimed_at,omitempty" json:"-"`
+	Attempts      []WebhookAttempt `bson:"attempts" json:"attempts"`
+	CreatedAt     time.Time        `bson:"created_at" json:"created_at"`
+	DeliveredAt   *time.Time       `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt records the outcome of one HTTP request
+type WebhookAttempt struct {
+	AttemptedAt time.Time `bson:"attempted_at" json:"attempted_at"`
+	StatusCode  int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs  int64     `bson:"duration_ms" json:"duration_ms"`
+}
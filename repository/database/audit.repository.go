@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoAuditRepository only ever inserts, entries are never updated or deleted
+type mongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditRepository(client *mongo.Client) AuditRepository {
+	collection := client.Database("chat").Collection("audit_log")
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoAuditRepository{collection: collection}
+}
+
+func (r *mongoAuditRepository) AppendAuditEntry(entry *models.AuditEntry) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *mongoAuditRepository) GetAuditEntries(audit models.AuditFilter) ([]*models.AuditEntry, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if audit.GroupID != "" {
+		filter["group_id"] = audit.GroupID
+	}
+	if audit.Actor != "" {
+		filter["actor"] = audit.Actor
+	}
+	if audit.Action != "" {
+		filter["action"] = audit.Action
+	}
+	if audit.Before != nil {
+		filter["created_at"] = bson.M{"$lt": *audit.Before}
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(audit.Limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*models.AuditEntry{}
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, cursor.Err()
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoBotRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoBotRepository(client *mongo.Client) BotRepository {
+	collection := client.Database("chat").Collection("bots")
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "owner", Value: 1}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoBotRepository{collection: collection}
+}
+
+func (r *mongoBotRepository) CreateBot(bot *models.Bot) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, bot)
+	return err
+}
+
+func (r *mongoBotRepository) GetBot(username string) (*models.Bot, error) {
+	return r.findBot(bson.M{"username": username})
+}
+
+func (r *mongoBotRepository) GetBotByKeyHash(keyHash string) (*models.Bot, error) {
+	return r.findBot(bson.M{"key_hash": keyHash})
+}
+
+func (r *mongoBotRepository) findBot(filter bson.M) (*models.Bot, error) {
+	ctx := context.Background()
+	var bot models.Bot
+	err := r.collection.FindOne(ctx, filter).Decode(&bot)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
+
+// GetBots returns the bots of an owner, or every bot when owner is empty
+func (r *mongoBotRepository) GetBots(owner string) ([]*models.Bot, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"username": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	bots := []*models.Bot{}
+	if err := cursor.All(ctx, &bots); err != nil {
+		return nil, err
+	}
+	return bots, nil
+}
+
+func (r *mongoBotRepository) UpdateBotKey(username, keyHash, keyHint string) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"username": username},
+		bson.M{"$set": bson.M{"key_hash": keyHash, "key_hint": keyHint}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *mongoBotRepository) TouchBot(username string, usedAt time.Time) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+func (r *mongoBotRepository) DeleteBot(username string) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"username": username})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
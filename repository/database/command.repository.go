@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCommandRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCommandRepository(client *mongo.Client) CommandRepository {
+	collection := client.Database("chat").Collection("command_hooks")
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoCommandRepository{collection: collection}
+}
+
+func (r *mongoCommandRepository) CreateCommandHook(hook *models.CommandHook) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, hook)
+	return err
+}
+
+func (r *mongoCommandRepository) GetCommandHook(id string) (*models.CommandHook, error) {
+	return r.findHook(bson.M{"id": id}, nil)
+}
+
+// FindCommandHook resolves a command name in a conversation: the group's own hook first,
+// then a global one
+func (r *mongoCommandRepository) FindCommandHook(groupID, name string) (*models.CommandHook, error) {
+	scopes := []bson.M{{"group_id": bson.M{"$exists": false}}}
+	if groupID != "" {
+		scopes = append(scopes, bson.M{"group_id": groupID})
+	}
+	// Descending group_id puts a group hook before a global hook, which has none
+	return r.findHook(bson.M{"name": name, "$or": scopes}, options.FindOne().SetSort(bson.M{"group_id": -1}))
+}
+
+func (r *mongoCommandRepository) findHook(filter bson.M, opts *options.FindOneOptions) (*models.CommandHook, error) {
+	ctx := context.Background()
+	if opts == nil {
+		opts = options.FindOne()
+	}
+	var hook models.CommandHook
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// GetCommandHooks returns a group's hooks, or the global hooks when groupID is empty.
+// includeGlobal adds the global hooks to a group's.
+func (r *mongoCommandRepository) GetCommandHooks(groupID string, includeGlobal bool) ([]*models.CommandHook, error) {
+	ctx := context.Background()
+	scopes := []bson.M{}
+	if groupID != "" {
+		scopes = append(scopes, bson.M{"group_id": groupID})
+	}
+	if groupID == "" || includeGlobal {
+		scopes = append(scopes, bson.M{"group_id": bson.M{"$exists": false}})
+	}
+	cursor, err := r.collection.Find(ctx, bson.M{"$or": scopes}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	hooks := []*models.CommandHook{}
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (r *mongoCommandRepository) DeleteCommandHook(id string) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoContactRepository struct {
+	contacts       *mongo.Collection
+	friendRequests *mongo.Collection
+	blocks         *mongo.Collection
+}
+
+func NewMongoContactRepository(client *mongo.Client) ContactRepository {
+	db := client.Database("chat")
+	contacts := db.Collection("contacts")
+	_, err := contacts.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}, {Key: "contact", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	blocks := db.Collection("blocks")
+	_, err = blocks.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "blocker", Value: 1}, {Key: "blocked", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoContactRepository{
+		contacts:       contacts,
+		friendRequests: db.Collection("friend_requests"),
+		blocks:         blocks,
+	}
+}
+
+func (r *mongoContactRepository) CreateFriendRequest(request *models.FriendRequest) (*models.FriendRequest, error) {
+	ctx := context.Background()
+	request.CreatedAt = time.Now()
+	_, err := r.friendRequests.InsertOne(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (r *mongoContactRepository) GetFriendRequest(id string) (*models.FriendRequest, error) {
+	ctx := context.Background()
+	var request models.FriendRequest
+	err := r.friendRequests.FindOne(ctx, bson.M{"id": id}).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *mongoContactRepository) GetPendingFriendRequest(from, to string) (*models.FriendRequest, error) {
+	ctx := context.Background()
+	var request models.FriendRequest
+	err := r.friendRequests.FindOne(ctx, bson.M{"from": from, "to": to, "status": models.FriendRequestPending}).Decode(&request)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *mongoContactRepository) GetFriendRequests(username string) ([]*models.FriendRequest, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"status": models.FriendRequestPending,
+		"$or": []bson.M{
+			{"from": username},
+			{"to": username},
+		},
+	}
+	cursor, err := r.friendRequests.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []*models.FriendRequest{}
+	for cursor.Next(ctx) {
+		var request models.FriendRequest
+		if err := cursor.Decode(&request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &request)
+	}
+	return requests, cursor.Err()
+}
+
+func (r *mongoContactRepository) UpdateFriendRequest(request *models.FriendRequest) error {
+	ctx := context.Background()
+	_, err := r.friendRequests.ReplaceOne(ctx, bson.M{"id": request.ID}, request)
+	return err
+}
+
+func (r *mongoContactRepository) AddContact(contact *models.Contact) error {
+	ctx := context.Background()
+	if contact.CreatedAt.IsZero() {
+		contact.CreatedAt = time.Now()
+	}
+	_, err := r.contacts.UpdateOne(ctx,
+		bson.M{"username": contact.Username, "contact": contact.Contact},
+		bson.M{"$setOnInsert": contact},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoContactRepository) RemoveContact(username, contact string) error {
+	ctx := context.Background()
+	_, err := r.contacts.DeleteOne(ctx, bson.M{"username": username, "contact": contact})
+	return err
+}
+
+func (r *mongoContactRepository) GetContacts(username string) ([]*models.Contact, error) {
+	ctx := context.Background()
+	cursor, err := r.contacts.Find(ctx, bson.M{"username": username}, options.Find().SetSort(bson.M{"contact": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	contacts := []*models.Contact{}
+	for cursor.Next(ctx) {
+		var contact models.Contact
+		if err := cursor.Decode(&contact); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, &contact)
+	}
+	return contacts, cursor.Err()
+}
+
+func (r *mongoContactRepository) IsContact(username, contact string) (bool, error) {
+	ctx := context.Background()
+	count, err := r.contacts.CountDocuments(ctx, bson.M{"username": username, "contact": contact})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *mongoContactRepository) BlockUser(block *models.Block) error {
+	ctx := context.Background()
+	if block.CreatedAt.IsZero() {
+		block.CreatedAt = time.Now()
+	}
+	_, err := r.blocks.UpdateOne(ctx,
+		bson.M{"blocker": block.Blocker, "blocked": block.Blocked},
+		bson.M{"$setOnInsert": block},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoContactRepository) UnblockUser(blocker, blocked string) error {
+	ctx := context.Background()
+	_, err := r.blocks.DeleteOne(ctx, bson.M{"blocker": blocker, "blocked": blocked})
+	return err
+}
+
+func (r *mongoContactRepository) GetBlockedUsers(blocker string) ([]*models.Block, error) {
+	ctx := context.Background()
+	cursor, err := r.blocks.Find(ctx, bson.M{"blocker": blocker}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	blocks := []*models.Block{}
+	for cursor.Next(ctx) {
+		var block models.Block
+		if err := cursor.Decode(&block); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, &block)
+	}
+	return blocks, cursor.Err()
+}
+
+func (r *mongoContactRepository) GetBlockers(blocked string) ([]string, error) {
+	ctx := context.Background()
+	values, err := r.blocks.Distinct(ctx, "blocker", bson.M{"blocked": blocked})
+	if err != nil {
+		return nil, err
+	}
+	blockers := []string{}
+	for _, value := range values {
+		if blocker, ok := value.(string); ok {
+			blockers = append(blockers, blocker)
+		}
+	}
+	return blockers, nil
+}
+
+func (r *mongoContactRepository) IsBlocked(blocker, blocked string) (bool, error) {
+	ctx := context.Background()
+	count, err := r.blocks.CountDocuments(ctx, bson.M{"blocker": blocker, "blocked": blocked})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteUserRelations removes every contact, friend request and block involving username
+func (r *mongoContactRepository) DeleteUserRelations(username string) error {
+	ctx := context.Background()
+	if _, err := r.contacts.DeleteMany(ctx, bson.M{"$or": []bson.M{{"username": username}, {"contact": username}}}); err != nil {
+		return err
+	}
+	if _, err := r.friendRequests.DeleteMany(ctx, bson.M{"$or": []bson.M{{"from": username}, {"to": username}}}); err != nil {
+		return err
+	}
+	_, err := r.blocks.DeleteMany(ctx, bson.M{"$or": []bson.M{{"blocker": username}, {"blocked": username}}})
+	return err
+}
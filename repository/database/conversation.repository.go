@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoConversationSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoConversationSettingsRepository(client *mongo.Client) ConversationSettingsRepository {
+	collection := client.Database("chat").Collection("conversation_settings")
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"key": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoConversationSettingsRepository{collection: collection}
+}
+
+func (r *mongoConversationSettingsRepository) GetDirectSettings(a, b string) (*models.DirectConversationSettings, error) {
+	ctx := context.Background()
+	var settings models.DirectConversationSettings
+	err := r.collection.FindOne(ctx, bson.M{"key": models.DirectPairKey(a, b)}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *mongoConversationSettingsRepository) SaveDirectSettings(settings *models.DirectConversationSettings) error {
+	ctx := context.Background()
+	settings.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"key": settings.Key}, settings, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeleteDirectSettings removes the settings of every direct conversation username takes part in
+func (r *mongoConversationSettingsRepository) DeleteDirectSettings(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteMany(ctx, bson.M{"participants": username})
+	return err
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoDigestSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDigestSettingsRepository(client *mongo.Client) DigestSettingsRepository {
+	collection := client.Database("chat").Collection("digest_settings")
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.M{"username": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"enabled": 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoDigestSettingsRepository{collection: collection}
+}
+
+// GetDigestSettings returns nil when the user never set up digests
+func (r *mongoDigestSettingsRepository) GetDigestSettings(username string) (*models.DigestSettings, error) {
+	ctx := context.Background()
+	var settings models.DigestSettings
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *mongoDigestSettingsRepository) GetEnabledDigestSettings() ([]*models.DigestSettings, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"enabled": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	settings := []*models.DigestSettings{}
+	if err := cursor.All(ctx, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (r *mongoDigestSettingsRepository) SaveDigestSettings(settings *models.DigestSettings) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"username": settings.Username}, settings, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ClaimDigest moves last_sent_at from previous to sentAt, so that only one server sends each digest
+func (r *mongoDigestSettingsRepository) ClaimDigest(username string, previous *time.Time, sentAt time.Time) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"username": username, "enabled": true, "last_sent_at": lastSentFilter(previous)},
+		bson.M{"$set": bson.M{"last_sent_at": sentAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ResetDigest puts last_sent_at back to previous after a digest could not be sent
+func (r *mongoDigestSettingsRepository) ResetDigest(username string, previous *time.Time) error {
+	ctx := context.Background()
+	update := bson.M{"$unset": bson.M{"last_sent_at": ""}}
+	if previous != nil {
+		update = bson.M{"$set": bson.M{"last_sent_at": *previous}}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"username": username}, update)
+	return err
+}
+
+func (r *mongoDigestSettingsRepository) DeleteDigestSettings(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"username": username})
+	return err
+}
+
+func lastSentFilter(previous *time.Time) bson.M {
+	if previous == nil {
+		return bson.M{"$exists": false}
+	}
+	return bson.M{"$eq": *previous}
+}
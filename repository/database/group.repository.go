@@ -7,6 +7,7 @@ import (
 	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoGroupRepository struct {
@@ -68,3 +69,73 @@ func (r *mongoGroupRepository) UpdateGroup(group *models.Group) error {
 	_, err := r.collection.ReplaceOne(ctx, bson.M{"id": group.ID}, group)
 	return err
 }
+
+// SetGroupLegalHold only touches the hold flag so it cannot race with membership changes.
+// It reports false when the group does not exist.
+func (r *mongoGroupRepository) SetGroupLegalHold(groupID string, hold bool) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.UpdateOne(ctx, bson.M{"id": groupID}, bson.M{"$set": bson.M{"legal_hold": hold}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *mongoGroupRepository) GetGroupsOnLegalHold() ([]string, error) {
+	ctx := context.Background()
+	values, err := r.collection.Distinct(ctx, "id", bson.M{"legal_hold": true})
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := []string{}
+	for _, value := range values {
+		if groupID, ok := value.(string); ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	return groupIDs, nil
+}
+
+func (r *mongoGroupRepository) StreamGroups(groupIDs []string, fn func(*models.Group) error) error {
+	ctx := context.Background()
+	for start := 0; start < len(groupIDs); start += streamBatchSize {
+		end := start + streamBatchSize
+		if end > len(groupIDs) {
+			end = len(groupIDs)
+		}
+		cursor, err := r.collection.Find(ctx, bson.M{"id": bson.M{"$in": groupIDs[start:end]}})
+		if err != nil {
+			return err
+		}
+		for cursor.Next(ctx) {
+			var group models.Group
+			if err := cursor.Decode(&group); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			if err := fn(&group); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportGroup inserts or replaces a group as-is, keeping its original timestamps
+func (r *mongoGroupRepository) ImportGroup(group *models.Group) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"id": group.ID}, group, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoGroupRepository) DeleteGroup(groupID string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"id": groupID})
+	return err
+}
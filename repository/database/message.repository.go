@@ -15,11 +15,36 @@ type mongoMessageRepository struct {
     collection *mongo.Collection
 }
 
+// expiredMessageGrace delays the Mongo TTL monitor so the expiry worker normally removes
+// messages first and can notify clients; the index is only a backstop
+const expiredMessageGrace = int32(time.Hour / time.Second)
+
 func NewMongoMessageRepository(client *mongo.Client) MessageRepository {
     collection := client.Database("chat").Collection("messages")
+    _, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+        {
+            Keys:    bson.M{"expires_at": 1},
+            Options: options.Index().SetExpireAfterSeconds(expiredMessageGrace),
+        },
+        {Keys: bson.D{{Key: "mentions.notified", Value: 1}, {Key: "timestamp", Value: -1}}},
+        // Presence audiences look up direct message partners from both sides
+        {Keys: bson.M{"sender": 1}},
+        {Keys: bson.M{"receiver": 1}},
+    })
+    if err != nil {
+        panic(err)
+    }
     return &mongoMessageRepository{collection: collection}
 }
 
+// notExpired matches messages without an expiry or whose expiry is still ahead
+func notExpired() bson.M {
+    return bson.M{"$or": []bson.M{
+        {"expires_at": bson.M{"$exists": false}},
+        {"expires_at": bson.M{"$gt": time.Now()}},
+    }}
+}
+
 func (r *mongoMessageRepository) SaveMessage(message *models.MessageDB) error {
     ctx := context.Background()
     if message.ID == "" {
@@ -32,7 +57,8 @@ func (r *mongoMessageRepository) SaveMessage(message *models.MessageDB) error {
 
 func (r *mongoMessageRepository) GetGroupMessages(groupID string) ([]*models.MessageDB, error) {
     ctx := context.Background()
-    cursor, err := r.collection.Find(ctx, bson.M{"group_id": groupID}, options.Find().SetSort(bson.M{"timestamp": 1}))
+    filter := bson.M{"group_id": groupID, "$and": []bson.M{notExpired()}}
+    cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
     if err != nil {
         return nil, err
     }
@@ -49,6 +75,32 @@ func (r *mongoMessageRepository) GetGroupMessages(groupID string) ([]*models.Mes
     return messages, nil
 }
 
+// GetMentions returns the messages in groupIDs that mention username, newest first,
+// sent before before when it is set
+func (r *mongoMessageRepository) GetMentions(username string, groupIDs []string, before *time.Time, limit int64) ([]*models.MessageDB, error) {
+    ctx := context.Background()
+    filter := bson.M{
+        "mentions.notified": username,
+        "group_id":          bson.M{"$in": groupIDs},
+        "$and":              []bson.M{notExpired()},
+    }
+    if before != nil {
+        filter["timestamp"] = bson.M{"$lt": *before}
+    }
+    opts := options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(limit)
+    cursor, err := r.collection.Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    messages := []*models.MessageDB{}
+    if err := cursor.All(ctx, &messages); err != nil {
+        return nil, err
+    }
+    return messages, nil
+}
+
 func (r *mongoMessageRepository) GetDirectMessages(sender, receiver string) ([]*models.MessageDB, error) {
     ctx := context.Background()
     filter := bson.M{
@@ -57,6 +109,7 @@ func (r *mongoMessageRepository) GetDirectMessages(sender, receiver string) ([]*
             {"sender": sender, "receiver": receiver},
             {"sender": receiver, "receiver": sender},
         },
+        "$and": []bson.M{notExpired()},
     }
     cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
     if err != nil {
@@ -73,4 +126,362 @@ func (r *mongoMessageRepository) GetDirectMessages(sender, receiver string) ([]*
         messages = append(messages, &msg)
     }
     return messages, nil
-}
\ No newline at end of file
+}
+
+func (r *mongoMessageRepository) GetDirectMessagePartners(username string) ([]string, error) {
+    ctx := context.Background()
+    receivers, err := r.collection.Distinct(ctx, "receiver", bson.M{"group_id": "", "sender": username})
+    if err != nil {
+        return nil, err
+    }
+    senders, err := r.collection.Distinct(ctx, "sender", bson.M{"group_id": "", "receiver": username})
+    if err != nil {
+        return nil, err
+    }
+
+    seen := make(map[string]bool)
+    partners := []string{}
+    for _, value := range append(receivers, senders...) {
+        partner, ok := value.(string)
+        if !ok || partner == "" || partner == username || seen[partner] {
+            continue
+        }
+        seen[partner] = true
+        partners = append(partners, partner)
+    }
+    return partners, nil
+}
+
+func (r *mongoMessageRepository) GetLastGroupMessage(groupID string) (*models.MessageDB, error) {
+    return r.findLatest(bson.M{"group_id": groupID})
+}
+
+func (r *mongoMessageRepository) GetLastDirectMessage(sender, receiver string) (*models.MessageDB, error) {
+    return r.findLatest(bson.M{
+        "group_id": "",
+        "$or": []bson.M{
+            {"sender": sender, "receiver": receiver},
+            {"sender": receiver, "receiver": sender},
+        },
+    })
+}
+
+// findLatest returns the newest message matching filter that has not expired
+func (r *mongoMessageRepository) findLatest(filter bson.M) (*models.MessageDB, error) {
+    ctx := context.Background()
+    filter["$and"] = []bson.M{notExpired()}
+    var msg models.MessageDB
+    err := r.collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.M{"timestamp": -1})).Decode(&msg)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &msg, nil
+}
+
+func (r *mongoMessageRepository) CountGroupMessagesSince(groupID, excludeSender string, since time.Time) (int64, error) {
+    ctx := context.Background()
+    return r.collection.CountDocuments(ctx, bson.M{
+        "group_id":  groupID,
+        "sender":    bson.M{"$ne": excludeSender},
+        "timestamp": bson.M{"$gt": since},
+        "$and":      []bson.M{notExpired()},
+    })
+}
+
+func (r *mongoMessageRepository) CountDirectMessagesSince(sender, receiver string, since time.Time) (int64, error) {
+    ctx := context.Background()
+    return r.collection.CountDocuments(ctx, bson.M{
+        "group_id":  "",
+        "sender":    sender,
+        "receiver":  receiver,
+        "timestamp": bson.M{"$gt": since},
+        "$and":      []bson.M{notExpired()},
+    })
+}
+
+// GetGroupMessagesSince returns the first limit messages of a group after since, oldest first
+func (r *mongoMessageRepository) GetGroupMessagesSince(groupID, excludeSender string, since time.Time, limit int64) ([]*models.MessageDB, error) {
+    return r.findSince(bson.M{
+        "group_id":  groupID,
+        "sender":    bson.M{"$ne": excludeSender},
+        "timestamp": bson.M{"$gt": since},
+        "$and":      []bson.M{notExpired()},
+    }, limit)
+}
+
+// GetDirectMessagesSince returns the first limit messages from sender to receiver after since, oldest first
+func (r *mongoMessageRepository) GetDirectMessagesSince(sender, receiver string, since time.Time, limit int64) ([]*models.MessageDB, error) {
+    return r.findSince(bson.M{
+        "group_id":  "",
+        "sender":    sender,
+        "receiver":  receiver,
+        "timestamp": bson.M{"$gt": since},
+        "$and":      []bson.M{notExpired()},
+    }, limit)
+}
+
+func (r *mongoMessageRepository) findSince(filter bson.M, limit int64) ([]*models.MessageDB, error) {
+    ctx := context.Background()
+    cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}).SetLimit(limit))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    messages := []*models.MessageDB{}
+    if err := cursor.All(ctx, &messages); err != nil {
+        return nil, err
+    }
+    return messages, nil
+}
+
+// DeleteExpiredMessages removes up to limit messages whose expiry has passed and returns
+// the ones it deleted
+func (r *mongoMessageRepository) DeleteExpiredMessages(now time.Time, limit int64) ([]*models.MessageDB, error) {
+    ctx := context.Background()
+    filter := bson.M{"expires_at": bson.M{"$lte": now}}
+    cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"expires_at": 1}).SetLimit(limit))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var messages []*models.MessageDB
+    ids := []string{}
+    for cursor.Next(ctx) {
+        var msg models.MessageDB
+        if err := cursor.Decode(&msg); err != nil {
+            return nil, err
+        }
+        messages = append(messages, &msg)
+        ids = append(ids, msg.ID)
+    }
+    if err := cursor.Err(); err != nil {
+        return nil, err
+    }
+    if len(ids) == 0 {
+        return messages, nil
+    }
+    // Repeat the expiry condition: a legal hold set since the lookup clears expires_at,
+    // and those messages must survive
+    result, err := r.collection.DeleteMany(ctx, bson.M{
+        "id":         bson.M{"$in": ids},
+        "expires_at": bson.M{"$exists": true, "$lte": now},
+    })
+    if err != nil {
+        return nil, err
+    }
+    if result.DeletedCount == int64(len(ids)) {
+        return messages, nil
+    }
+    return r.withoutRemaining(ctx, messages, ids)
+}
+
+// withoutRemaining drops the messages that are still stored from messages
+func (r *mongoMessageRepository) withoutRemaining(ctx context.Context, messages []*models.MessageDB, ids []string) ([]*models.MessageDB, error) {
+    cursor, err := r.collection.Find(ctx, bson.M{"id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"id": 1}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    remaining := map[string]bool{}
+    for cursor.Next(ctx) {
+        var msg models.MessageDB
+        if err := cursor.Decode(&msg); err != nil {
+            return nil, err
+        }
+        remaining[msg.ID] = true
+    }
+    if err := cursor.Err(); err != nil {
+        return nil, err
+    }
+    deleted := []*models.MessageDB{}
+    for _, msg := range messages {
+        if !remaining[msg.ID] {
+            deleted = append(deleted, msg)
+        }
+    }
+    return deleted, nil
+}
+
+// ClearMessageExpiry removes the expiry of a group's messages, or of every message a user
+// sent or received, so a legal hold also stops disappearing messages and the TTL index
+func (r *mongoMessageRepository) ClearMessageExpiry(groupID, username string) (int64, error) {
+    ctx := context.Background()
+    filter := bson.M{"expires_at": bson.M{"$exists": true}}
+    if groupID != "" {
+        filter["group_id"] = groupID
+    } else {
+        filter["$or"] = []bson.M{{"sender": username}, {"receiver": username}}
+    }
+    result, err := r.collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"expires_at": ""}})
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func (r *mongoMessageRepository) PurgeMessages(purge models.MessagePurgeFilter) (int64, error) {
+    ctx := context.Background()
+    filter := bson.M{"timestamp": bson.M{"$lt": purge.Before}}
+    if purge.GroupID != "" {
+        filter["group_id"] = purge.GroupID
+    } else if len(purge.ExcludeGroupIDs) > 0 {
+        filter["group_id"] = bson.M{"$nin": purge.ExcludeGroupIDs}
+    }
+    if len(purge.ExcludeUsers) > 0 {
+        filter["sender"] = bson.M{"$nin": purge.ExcludeUsers}
+        filter["receiver"] = bson.M{"$nin": purge.ExcludeUsers}
+    }
+    result, err := r.collection.DeleteMany(ctx, filter)
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+// StreamMessages walks the matching messages in chronological order without loading them all
+func (r *mongoMessageRepository) StreamMessages(export models.ExportFilter, fn func(*models.MessageDB) error) error {
+    ctx := context.Background()
+    filter := bson.M{}
+    if export.Username != "" {
+        filter["$or"] = []bson.M{{"sender": export.Username}, {"receiver": export.Username}}
+    }
+    if export.GroupID != "" {
+        filter["group_id"] = export.GroupID
+    }
+    if export.From != nil || export.To != nil {
+        timestamp := bson.M{}
+        if export.From != nil {
+            timestamp["$gte"] = *export.From
+        }
+        if export.To != nil {
+            timestamp["$lt"] = *export.To
+        }
+        filter["timestamp"] = timestamp
+    }
+    cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    for cursor.Next(ctx) {
+        var msg models.MessageDB
+        if err := cursor.Decode(&msg); err != nil {
+            return err
+        }
+        if err := fn(&msg); err != nil {
+            return err
+        }
+    }
+    return cursor.Err()
+}
+
+// ImportMessage inserts or replaces a message as-is, unlike SaveMessage which stamps it with now
+func (r *mongoMessageRepository) ImportMessage(message *models.MessageDB) error {
+    ctx := context.Background()
+    _, err := r.collection.ReplaceOne(ctx, bson.M{"id": message.ID}, message, options.Replace().SetUpsert(true))
+    return err
+}
+// DeleteUserMessages removes the messages a user sent, except those in the excluded groups
+// or direct conversations with the excluded users
+func (r *mongoMessageRepository) DeleteUserMessages(username string, excludeGroupIDs, excludeUsers []string) (int64, error) {
+    ctx := context.Background()
+    filter := bson.M{"sender": username}
+    if len(excludeGroupIDs) > 0 {
+        filter["group_id"] = bson.M{"$nin": excludeGroupIDs}
+    }
+    if len(excludeUsers) > 0 {
+        filter["receiver"] = bson.M{"$nin": excludeUsers}
+    }
+    result, err := r.collection.DeleteMany(ctx, filter)
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+// AnonymizeUserMessages replaces a username with a pseudonym on every message they sent or
+// received, except those in the excluded groups or direct conversations with the excluded users.
+// Group messages mentioning them name the pseudonym instead and drop them from the notified.
+func (r *mongoMessageRepository) AnonymizeUserMessages(username, pseudonym string, excludeGroupIDs, excludeUsers []string) (int64, error) {
+    ctx := context.Background()
+    var modified int64
+    // The other party of a direct message is in the opposite field
+    counterparts := map[string]string{"sender": "receiver", "receiver": "sender"}
+    for _, field := range []string{"sender", "receiver"} {
+        filter := bson.M{field: username}
+        if len(excludeGroupIDs) > 0 {
+            filter["group_id"] = bson.M{"$nin": excludeGroupIDs}
+        }
+        if len(excludeUsers) > 0 {
+            filter[counterparts[field]] = bson.M{"$nin": excludeUsers}
+        }
+        result, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: pseudonym}})
+        if err != nil {
+            return modified, err
+        }
+        modified += result.ModifiedCount
+    }
+
+    mentionFilter := bson.M{"mentions.users": username}
+    if len(excludeGroupIDs) > 0 {
+        mentionFilter["group_id"] = bson.M{"$nin": excludeGroupIDs}
+    }
+    opts := options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"mentioned": username}}})
+    result, err := r.collection.UpdateMany(ctx, mentionFilter, bson.M{"$set": bson.M{"mentions.users.$[mentioned]": pseudonym}}, opts)
+    if err != nil {
+        return modified, err
+    }
+    modified += result.ModifiedCount
+
+    notifiedFilter := bson.M{"mentions.notified": username}
+    if len(excludeGroupIDs) > 0 {
+        notifiedFilter["group_id"] = bson.M{"$nin": excludeGroupIDs}
+    }
+    if _, err := r.collection.UpdateMany(ctx, notifiedFilter, bson.M{"$pull": bson.M{"mentions.notified": username}}); err != nil {
+        return modified, err
+    }
+    return modified, nil
+}
+
+func (r *mongoMessageRepository) DeleteGroupMessages(groupID string) (int64, error) {
+    ctx := context.Background()
+    result, err := r.collection.DeleteMany(ctx, bson.M{"group_id": groupID})
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+// DeleteMessage removes a single message and returns it, or nil when it does not exist
+func (r *mongoMessageRepository) DeleteMessage(id string) (*models.MessageDB, error) {
+    ctx := context.Background()
+    var msg models.MessageDB
+    err := r.collection.FindOneAndDelete(ctx, bson.M{"id": id}).Decode(&msg)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &msg, nil
+}
+
+func (r *mongoMessageRepository) GetMessage(id string) (*models.MessageDB, error) {
+    ctx := context.Background()
+    var msg models.MessageDB
+    err := r.collection.FindOne(ctx, bson.M{"id": id}).Decode(&msg)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &msg, nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoModerationRepository struct {
+	rules *mongo.Collection
+	queue *mongo.Collection
+}
+
+func NewMongoModerationRepository(client *mongo.Client) ModerationRepository {
+	db := client.Database("chat")
+	rules := db.Collection("moderation_rules")
+	_, err := rules.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.M{"group_id": 1},
+	})
+	if err != nil {
+		panic(err)
+	}
+	queue := db.Collection("moderation_queue")
+	_, err = queue.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "group_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoModerationRepository{
+		rules: rules,
+		queue: queue,
+	}
+}
+
+func (r *mongoModerationRepository) GetModerationRules(groupID string) ([]*models.ModerationRule, error) {
+	ctx := context.Background()
+	cursor, err := r.rules.Find(ctx, bson.M{"group_id": groupID}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []*models.ModerationRule{}
+	for cursor.Next(ctx) {
+		var rule models.ModerationRule
+		if err := cursor.Decode(&rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, cursor.Err()
+}
+
+func (r *mongoModerationRepository) CreateModerationRule(rule *models.ModerationRule) error {
+	ctx := context.Background()
+	_, err := r.rules.InsertOne(ctx, rule)
+	return err
+}
+
+func (r *mongoModerationRepository) DeleteModerationRule(groupID, id string) (bool, error) {
+	ctx := context.Background()
+	result, err := r.rules.DeleteOne(ctx, bson.M{"group_id": groupID, "id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *mongoModerationRepository) CreateModerationItem(item *models.ModerationItem) error {
+	ctx := context.Background()
+	_, err := r.queue.InsertOne(ctx, item)
+	return err
+}
+
+func (r *mongoModerationRepository) GetModerationItem(id string) (*models.ModerationItem, error) {
+	ctx := context.Background()
+	var item models.ModerationItem
+	err := r.queue.FindOne(ctx, bson.M{"id": id}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// HasPendingReport reports whether reporter already has an open report on the message, or on the user when messageID is empty
+func (r *mongoModerationRepository) HasPendingReport(reporter, messageID, reportedUser string) (bool, error) {
+	ctx := context.Background()
+	filter := bson.M{"source": models.ModerationSourceReport, "status": models.ModerationItemPending, "reporter": reporter}
+	if messageID != "" {
+		filter["message_id"] = messageID
+	} else {
+		filter["reported_user"] = reportedUser
+		filter["message_id"] = bson.M{"$exists": false}
+	}
+	count, err := r.queue.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *mongoModerationRepository) GetModerationItems(queue models.ModerationQueueFilter) ([]*models.ModerationItem, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if queue.GroupID != "" {
+		filter["group_id"] = queue.GroupID
+	}
+	if queue.Source != "" {
+		filter["source"] = queue.Source
+	}
+	if queue.Status != "" {
+		filter["status"] = queue.Status
+	}
+	cursor, err := r.queue.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}).SetLimit(queue.Limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []*models.ModerationItem{}
+	for cursor.Next(ctx) {
+		var item models.ModerationItem
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, cursor.Err()
+}
+
+// ResolveModerationItem closes a pending item; it reports false when another moderator got there first
+func (r *mongoModerationRepository) ResolveModerationItem(id, resolution, reviewer string, reviewedAt time.Time) (bool, error) {
+	ctx := context.Background()
+	result, err := r.queue.UpdateOne(ctx,
+		bson.M{"id": id, "status": models.ModerationItemPending},
+		bson.M{"$set": bson.M{
+			"status":      models.ModerationItemResolved,
+			"resolution":  resolution,
+			"reviewed_by": reviewer,
+			"reviewed_at": reviewedAt,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
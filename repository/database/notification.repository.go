@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoNotificationSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoNotificationSettingsRepository(client *mongo.Client) NotificationSettingsRepository {
+	collection := client.Database("chat").Collection("notification_settings")
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"username": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoNotificationSettingsRepository{collection: collection}
+}
+
+// GetNotificationSettings returns nil when the user never changed their settings
+func (r *mongoNotificationSettingsRepository) GetNotificationSettings(username string) (*models.NotificationSettings, error) {
+	ctx := context.Background()
+	var settings models.NotificationSettings
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *mongoNotificationSettingsRepository) SaveNotificationSettings(settings *models.NotificationSettings) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"username": settings.Username}, settings, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoNotificationSettingsRepository) DeleteNotificationSettings(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"username": username})
+	return err
+}
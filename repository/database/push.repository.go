@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPushDeviceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPushDeviceRepository(client *mongo.Client) PushDeviceRepository {
+	collection := client.Database("chat").Collection("push_devices")
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "address", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "username", Value: 1}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoPushDeviceRepository{collection: collection}
+}
+
+// SavePushDevice stores a device, replacing any device registered at the same address
+func (r *mongoPushDeviceRepository) SavePushDevice(device *models.PushDevice) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx,
+		bson.M{"provider": device.Provider, "address": device.Address},
+		device,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoPushDeviceRepository) GetPushDevices(username string) ([]*models.PushDevice, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"username": username}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	devices := []*models.PushDevice{}
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *mongoPushDeviceRepository) DeletePushDevice(username, id string) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"username": username, "id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *mongoPushDeviceRepository) DeletePushDevices(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteMany(ctx, bson.M{"username": username})
+	return err
+}
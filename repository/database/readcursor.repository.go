@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoReadCursorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoReadCursorRepository(client *mongo.Client) ReadCursorRepository {
+	collection := client.Database("chat").Collection("read_cursors")
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}, {Key: "conversation_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoReadCursorRepository{collection: collection}
+}
+
+func (r *mongoReadCursorRepository) GetReadCursors(username string) ([]*models.ReadCursor, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"username": username})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	readCursors := []*models.ReadCursor{}
+	for cursor.Next(ctx) {
+		var readCursor models.ReadCursor
+		if err := cursor.Decode(&readCursor); err != nil {
+			return nil, err
+		}
+		readCursors = append(readCursors, &readCursor)
+	}
+	return readCursors, cursor.Err()
+}
+
+// SetReadCursor only ever moves a cursor forward
+func (r *mongoReadCursorRepository) SetReadCursor(readCursor *models.ReadCursor) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"username": readCursor.Username, "conversation_id": readCursor.ConversationID},
+		bson.M{"$max": bson.M{"last_read_at": readCursor.LastReadAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoReadCursorRepository) DeleteReadCursors(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteMany(ctx, bson.M{"username": username})
+	return err
+}
@@ -1,11 +1,23 @@
 package database
 
-import "github.com/JomnoiZ/network-backend-group-13.git/models"
+import (
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
 
 type UserRepository interface {
 	GetUser(username string) (*models.User, error)
 	GetAllUsers() ([]*models.User, error)
 	CreateUser(user *models.User) (*models.User, error)
+	UpdateUser(user *models.User) error
+	UpdateProfile(username string, update *models.UserProfileUpdate) (*models.User, error)
+	UpdateLastSeen(username string, lastSeen time.Time) error
+	SetUserLegalHold(username string, hold bool) (bool, error)
+	GetUsersOnLegalHold() ([]string, error)
+	StreamUsers(usernames []string, fn func(*models.User) error) error
+	ImportUser(user *models.User) error
+	DeleteUser(username string) error
 	GetUserGroups(username string) ([]*models.Group, error)
 }
 
@@ -14,10 +26,154 @@ type GroupRepository interface {
 	GetGroup(groupID string) (*models.Group, error)
 	CreateGroup(group *models.Group) (*models.Group, error)
 	UpdateGroup(group *models.Group) error
+	SetGroupLegalHold(groupID string, hold bool) (bool, error)
+	GetGroupsOnLegalHold() ([]string, error)
+	StreamGroups(groupIDs []string, fn func(*models.Group) error) error
+	ImportGroup(group *models.Group) error
+	DeleteGroup(groupID string) error
 }
 
 type MessageRepository interface {
 	SaveMessage(message *models.MessageDB) error
 	GetGroupMessages(groupID string) ([]*models.MessageDB, error)
 	GetDirectMessages(sender, receiver string) ([]*models.MessageDB, error)
+	GetMentions(username string, groupIDs []string, before *time.Time, limit int64) ([]*models.MessageDB, error)
+	GetDirectMessagePartners(username string) ([]string, error)
+	GetLastGroupMessage(groupID string) (*models.MessageDB, error)
+	GetLastDirectMessage(sender, receiver string) (*models.MessageDB, error)
+	CountGroupMessagesSince(groupID, excludeSender string, since time.Time) (int64, error)
+	CountDirectMessagesSince(sender, receiver string, since time.Time) (int64, error)
+	GetGroupMessagesSince(groupID, excludeSender string, since time.Time, limit int64) ([]*models.MessageDB, error)
+	GetDirectMessagesSince(sender, receiver string, since time.Time, limit int64) ([]*models.MessageDB, error)
+	DeleteExpiredMessages(now time.Time, limit int64) ([]*models.MessageDB, error)
+	ClearMessageExpiry(groupID, username string) (int64, error)
+	PurgeMessages(filter models.MessagePurgeFilter) (int64, error)
+	StreamMessages(filter models.ExportFilter, fn func(*models.MessageDB) error) error
+	ImportMessage(message *models.MessageDB) error
+	DeleteUserMessages(username string, excludeGroupIDs, excludeUsers []string) (int64, error)
+	AnonymizeUserMessages(username, pseudonym string, excludeGroupIDs, excludeUsers []string) (int64, error)
+	DeleteGroupMessages(groupID string) (int64, error)
+	GetMessage(id string) (*models.MessageDB, error)
+	DeleteMessage(id string) (*models.MessageDB, error)
+}
+
+type ContactRepository interface {
+	CreateFriendRequest(request *models.FriendRequest) (*models.FriendRequest, error)
+	GetFriendRequest(id string) (*models.FriendRequest, error)
+	GetPendingFriendRequest(from, to string) (*models.FriendRequest, error)
+	GetFriendRequests(username string) ([]*models.FriendRequest, error)
+	UpdateFriendRequest(request *models.FriendRequest) error
+	AddContact(contact *models.Contact) error
+	RemoveContact(username, contact string) error
+	GetContacts(username string) ([]*models.Contact, error)
+	IsContact(username, contact string) (bool, error)
+	BlockUser(block *models.Block) error
+	UnblockUser(blocker, blocked string) error
+	GetBlockedUsers(blocker string) ([]*models.Block, error)
+	GetBlockers(blocked string) ([]string, error)
+	IsBlocked(blocker, blocked string) (bool, error)
+	DeleteUserRelations(username string) error
+}
+
+type ReadCursorRepository interface {
+	GetReadCursors(username string) ([]*models.ReadCursor, error)
+	SetReadCursor(cursor *models.ReadCursor) error
+	DeleteReadCursors(username string) error
+}
+
+type ScheduledMessageRepository interface {
+	CreateScheduledMessage(message *models.ScheduledMessage) error
+	GetScheduledMessage(id string) (*models.ScheduledMessage, error)
+	GetPendingScheduledMessages(sender string) ([]*models.ScheduledMessage, error)
+	UpdatePendingScheduledMessage(message *models.ScheduledMessage) (bool, error)
+	ClaimDueScheduledMessage(now time.Time) (*models.ScheduledMessage, error)
+	ReleaseStaleClaims(claimedBefore time.Time) (int64, error)
+	MarkScheduledMessageSent(id string, sentAt time.Time) error
+	DeleteScheduledMessages(username string) (int64, error)
+}
+
+type ConversationSettingsRepository interface {
+	GetDirectSettings(a, b string) (*models.DirectConversationSettings, error)
+	SaveDirectSettings(settings *models.DirectConversationSettings) error
+	DeleteDirectSettings(username string) error
+}
+
+type RetentionRepository interface {
+	GetPolicies() ([]*models.RetentionPolicy, error)
+	GetPolicy(id string) (*models.RetentionPolicy, error)
+	SavePolicy(policy *models.RetentionPolicy) error
+	DeletePolicy(id string) error
+	SavePurgeRun(run *models.PurgeRun) error
+	GetPurgeRuns(limit int64) ([]*models.PurgeRun, error)
+}
+
+type AuditRepository interface {
+	AppendAuditEntry(entry *models.AuditEntry) error
+	GetAuditEntries(filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+type ModerationRepository interface {
+	GetModerationRules(groupID string) ([]*models.ModerationRule, error)
+	CreateModerationRule(rule *models.ModerationRule) error
+	DeleteModerationRule(groupID, id string) (bool, error)
+	CreateModerationItem(item *models.ModerationItem) error
+	GetModerationItem(id string) (*models.ModerationItem, error)
+	HasPendingReport(reporter, messageID, reportedUser string) (bool, error)
+	GetModerationItems(filter models.ModerationQueueFilter) ([]*models.ModerationItem, error)
+	ResolveModerationItem(id, resolution, reviewer string, reviewedAt time.Time) (bool, error)
+}
+
+type WebhookRepository interface {
+	CreateWebhook(webhook *models.Webhook) error
+	GetWebhook(id string) (*models.Webhook, error)
+	GetWebhooks(groupID string) ([]*models.Webhook, error)
+	GetSubscribedWebhooks(groupID, event string) ([]*models.Webhook, error)
+	DeleteWebhook(id string) error
+	EnqueueDelivery(delivery *models.WebhookDelivery) error
+	ClaimDueDelivery(now time.Time) (*models.WebhookDelivery, error)
+	ReleaseStaleDeliveries(claimedBefore time.Time) (int64, error)
+	RecordDeliveryAttempt(id string, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error
+	GetDeliveries(webhookID, status string, limit int64) ([]*models.WebhookDelivery, error)
+	GetDelivery(id string) (*models.WebhookDelivery, error)
+	RequeueDelivery(id string, now time.Time) (bool, error)
+}
+
+type BotRepository interface {
+	CreateBot(bot *models.Bot) error
+	GetBot(username string) (*models.Bot, error)
+	GetBotByKeyHash(keyHash string) (*models.Bot, error)
+	GetBots(owner string) ([]*models.Bot, error)
+	UpdateBotKey(username, keyHash, keyHint string) (bool, error)
+	TouchBot(username string, usedAt time.Time) error
+	DeleteBot(username string) (bool, error)
+}
+
+type CommandRepository interface {
+	CreateCommandHook(hook *models.CommandHook) error
+	GetCommandHook(id string) (*models.CommandHook, error)
+	FindCommandHook(groupID, name string) (*models.CommandHook, error)
+	GetCommandHooks(groupID string, includeGlobal bool) ([]*models.CommandHook, error)
+	DeleteCommandHook(id string) (bool, error)
+}
+
+type NotificationSettingsRepository interface {
+	GetNotificationSettings(username string) (*models.NotificationSettings, error)
+	SaveNotificationSettings(settings *models.NotificationSettings) error
+	DeleteNotificationSettings(username string) error
+}
+
+type PushDeviceRepository interface {
+	SavePushDevice(device *models.PushDevice) error
+	GetPushDevices(username string) ([]*models.PushDevice, error)
+	DeletePushDevice(username, id string) (bool, error)
+	DeletePushDevices(username string) error
+}
+
+type DigestSettingsRepository interface {
+	GetDigestSettings(username string) (*models.DigestSettings, error)
+	GetEnabledDigestSettings() ([]*models.DigestSettings, error)
+	SaveDigestSettings(settings *models.DigestSettings) error
+	ClaimDigest(username string, previous *time.Time, sentAt time.Time) (bool, error)
+	ResetDigest(username string, previous *time.Time) error
+	DeleteDigestSettings(username string) error
 }
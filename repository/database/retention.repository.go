@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRetentionRepository struct {
+	policies  *mongo.Collection
+	purgeRuns *mongo.Collection
+}
+
+func NewMongoRetentionRepository(client *mongo.Client) RetentionRepository {
+	db := client.Database("chat")
+	return &mongoRetentionRepository{
+		policies:  db.Collection("retention_policies"),
+		purgeRuns: db.Collection("purge_runs"),
+	}
+}
+
+func (r *mongoRetentionRepository) GetPolicies() ([]*models.RetentionPolicy, error) {
+	ctx := context.Background()
+	cursor, err := r.policies.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	policies := []*models.RetentionPolicy{}
+	for cursor.Next(ctx) {
+		var policy models.RetentionPolicy
+		if err := cursor.Decode(&policy); err != nil {
+			return nil, err
+		}
+		policies = append(policies, &policy)
+	}
+	return policies, cursor.Err()
+}
+
+func (r *mongoRetentionRepository) GetPolicy(id string) (*models.RetentionPolicy, error) {
+	ctx := context.Background()
+	var policy models.RetentionPolicy
+	err := r.policies.FindOne(ctx, bson.M{"id": id}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *mongoRetentionRepository) SavePolicy(policy *models.RetentionPolicy) error {
+	ctx := context.Background()
+	_, err := r.policies.ReplaceOne(ctx, bson.M{"id": policy.ID}, policy, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoRetentionRepository) DeletePolicy(id string) error {
+	ctx := context.Background()
+	_, err := r.policies.DeleteOne(ctx, bson.M{"id": id})
+	return err
+}
+
+func (r *mongoRetentionRepository) SavePurgeRun(run *models.PurgeRun) error {
+	ctx := context.Background()
+	_, err := r.purgeRuns.InsertOne(ctx, run)
+	return err
+}
+
+func (r *mongoRetentionRepository) GetPurgeRuns(limit int64) ([]*models.PurgeRun, error) {
+	ctx := context.Background()
+	cursor, err := r.purgeRuns.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"started_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []*models.PurgeRun{}
+	for cursor.Next(ctx) {
+		var run models.PurgeRun
+		if err := cursor.Decode(&run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+	return runs, cursor.Err()
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoScheduledMessageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoScheduledMessageRepository(client *mongo.Client) ScheduledMessageRepository {
+	collection := client.Database("chat").Collection("scheduled_messages")
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "send_at", Value: 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoScheduledMessageRepository{collection: collection}
+}
+
+func (r *mongoScheduledMessageRepository) CreateScheduledMessage(message *models.ScheduledMessage) error {
+	ctx := context.Background()
+	message.CreatedAt = time.Now()
+	message.UpdatedAt = message.CreatedAt
+	_, err := r.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (r *mongoScheduledMessageRepository) GetScheduledMessage(id string) (*models.ScheduledMessage, error) {
+	ctx := context.Background()
+	var message models.ScheduledMessage
+	err := r.collection.FindOne(ctx, bson.M{"id": id}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *mongoScheduledMessageRepository) GetPendingScheduledMessages(sender string) ([]*models.ScheduledMessage, error) {
+	ctx := context.Background()
+	filter := bson.M{"sender": sender, "status": models.ScheduledPending}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"send_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []*models.ScheduledMessage{}
+	for cursor.Next(ctx) {
+		var message models.ScheduledMessage
+		if err := cursor.Decode(&message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, cursor.Err()
+}
+
+// UpdatePendingScheduledMessage replaces a message only while it is still pending,
+// so an edit racing with delivery never resurrects a sent message
+func (r *mongoScheduledMessageRepository) UpdatePendingScheduledMessage(message *models.ScheduledMessage) (bool, error) {
+	ctx := context.Background()
+	message.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"id": message.ID, "status": models.ScheduledPending}, message)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ClaimDueScheduledMessage atomically moves the oldest due message to sending
+func (r *mongoScheduledMessageRepository) ClaimDueScheduledMessage(now time.Time) (*models.ScheduledMessage, error) {
+	ctx := context.Background()
+	var message models.ScheduledMessage
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"status": models.ScheduledPending, "send_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": models.ScheduledSending, "claimed_at": now}},
+		options.FindOneAndUpdate().SetSort(bson.M{"send_at": 1}).SetReturnDocument(options.After),
+	).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ReleaseStaleClaims returns messages left in sending by a crashed server to the queue
+func (r *mongoScheduledMessageRepository) ReleaseStaleClaims(claimedBefore time.Time) (int64, error) {
+	ctx := context.Background()
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": models.ScheduledSending, "claimed_at": bson.M{"$lt": claimedBefore}},
+		bson.M{"$set": bson.M{"status": models.ScheduledPending}, "$unset": bson.M{"claimed_at": ""}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoScheduledMessageRepository) MarkScheduledMessageSent(id string, sentAt time.Time) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"id": id},
+		bson.M{"$set": bson.M{"status": models.ScheduledSent, "sent_at": sentAt, "updated_at": sentAt}},
+	)
+	return err
+}
+
+// DeleteScheduledMessages removes every scheduled message sent by or addressed to username
+func (r *mongoScheduledMessageRepository) DeleteScheduledMessages(username string) (int64, error) {
+	ctx := context.Background()
+	result, err := r.collection.DeleteMany(ctx, bson.M{"$or": []bson.M{{"sender": username}, {"receiver": username}}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
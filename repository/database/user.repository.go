@@ -68,6 +68,118 @@ func (r *mongoUserRepository) CreateUser(user *models.User) (*models.User, error
 	return user, nil
 }
 
+func (r *mongoUserRepository) UpdateUser(user *models.User) error {
+	ctx := context.Background()
+	user.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"username": user.Username}, user)
+	return err
+}
+
+// UpdateProfile sets the non-nil fields of update and returns the updated user, or nil
+// when the user does not exist. Other fields are left alone, whatever changed them.
+func (r *mongoUserRepository) UpdateProfile(username string, update *models.UserProfileUpdate) (*models.User, error) {
+	ctx := context.Background()
+	fields := bson.M{"updated_at": time.Now()}
+	if update.DisplayName != nil {
+		fields["display_name"] = *update.DisplayName
+	}
+	if update.AvatarURL != nil {
+		fields["avatar_url"] = *update.AvatarURL
+	}
+	if update.Bio != nil {
+		fields["bio"] = *update.Bio
+	}
+	if update.TimeZone != nil {
+		fields["time_zone"] = *update.TimeZone
+	}
+	if update.StatusMessage != nil {
+		fields["status_message"] = *update.StatusMessage
+	}
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"username": username}, bson.M{"$set": fields}, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) UpdateLastSeen(username string, lastSeen time.Time) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"last_seen": lastSeen}})
+	return err
+}
+
+// SetUserLegalHold only touches the hold flag so it cannot race with profile updates.
+// It reports false when the user does not exist.
+func (r *mongoUserRepository) SetUserLegalHold(username string, hold bool) (bool, error) {
+	ctx := context.Background()
+	result, err := r.collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"legal_hold": hold}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *mongoUserRepository) GetUsersOnLegalHold() ([]string, error) {
+	ctx := context.Background()
+	values, err := r.collection.Distinct(ctx, "username", bson.M{"legal_hold": true})
+	if err != nil {
+		return nil, err
+	}
+	usernames := []string{}
+	for _, value := range values {
+		if username, ok := value.(string); ok {
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames, nil
+}
+
+// streamBatchSize bounds the size of $in lists when streaming documents by key
+const streamBatchSize = 1000
+
+func (r *mongoUserRepository) StreamUsers(usernames []string, fn func(*models.User) error) error {
+	ctx := context.Background()
+	for start := 0; start < len(usernames); start += streamBatchSize {
+		end := start + streamBatchSize
+		if end > len(usernames) {
+			end = len(usernames)
+		}
+		cursor, err := r.collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames[start:end]}})
+		if err != nil {
+			return err
+		}
+		for cursor.Next(ctx) {
+			var user models.User
+			if err := cursor.Decode(&user); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+			if err := fn(&user); err != nil {
+				cursor.Close(ctx)
+				return err
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportUser inserts or replaces a user as-is, keeping its original timestamps
+func (r *mongoUserRepository) ImportUser(user *models.User) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"username": user.Username}, user, options.Replace().SetUpsert(true))
+	return err
+}
+
 func (r *mongoUserRepository) GetUserGroups(username string) ([]*models.Group, error) {
 	ctx := context.Background()
 	cursor, err := r.collection.Database().Collection("groups").Find(ctx, bson.M{"members": username})
@@ -86,3 +198,9 @@ func (r *mongoUserRepository) GetUserGroups(username string) ([]*models.Group, e
 	}
 	return groups, nil
 }
+
+func (r *mongoUserRepository) DeleteUser(username string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"username": username})
+	return err
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoWebhookRepository struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+func NewMongoWebhookRepository(client *mongo.Client) WebhookRepository {
+	db := client.Database("chat")
+	webhooks := db.Collection("webhooks")
+	_, err := webhooks.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.M{"group_id": 1},
+	})
+	if err != nil {
+		panic(err)
+	}
+	deliveries := db.Collection("webhook_deliveries")
+	_, err = deliveries.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &mongoWebhookRepository{
+		webhooks:   webhooks,
+		deliveries: deliveries,
+	}
+}
+
+func (r *mongoWebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	ctx := context.Background()
+	_, err := r.webhooks.InsertOne(ctx, webhook)
+	return err
+}
+
+func (r *mongoWebhookRepository) GetWebhook(id string) (*models.Webhook, error) {
+	ctx := context.Background()
+	var webhook models.Webhook
+	err := r.webhooks.FindOne(ctx, bson.M{"id": id}).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhooks returns the webhooks of a group, or the global ones when groupID is empty
+func (r *mongoWebhookRepository) GetWebhooks(groupID string) ([]*models.Webhook, error) {
+	filter := bson.M{"group_id": groupID}
+	if groupID == "" {
+		filter = bson.M{"group_id": bson.M{"$exists": false}}
+	}
+	return r.findWebhooks(filter)
+}
+
+// GetSubscribedWebhooks returns the group's and the global webhooks subscribed to event
+func (r *mongoWebhookRepository) GetSubscribedWebhooks(groupID, event string) ([]*models.Webhook, error) {
+	scopes := []bson.M{{"group_id": bson.M{"$exists": false}}}
+	if groupID != "" {
+		scopes = append(scopes, bson.M{"group_id": groupID})
+	}
+	return r.findWebhooks(bson.M{"events": event, "$or": scopes})
+}
+
+func (r *mongoWebhookRepository) findWebhooks(filter bson.M) ([]*models.Webhook, error) {
+	ctx := context.Background()
+	cursor, err := r.webhooks.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []*models.Webhook{}
+	for cursor.Next(ctx) {
+		var webhook models.Webhook
+		if err := cursor.Decode(&webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, cursor.Err()
+}
+
+// DeleteWebhook removes a webhook and drops its deliveries that have not been sent yet
+func (r *mongoWebhookRepository) DeleteWebhook(id string) error {
+	ctx := context.Background()
+	if _, err := r.webhooks.DeleteOne(ctx, bson.M{"id": id}); err != nil {
+		return err
+	}
+	_, err := r.deliveries.DeleteMany(ctx, bson.M{"webhook_id": id, "status": models.WebhookDeliveryPending})
+	return err
+}
+
+func (r *mongoWebhookRepository) EnqueueDelivery(delivery *models.WebhookDelivery) error {
+	ctx := context.Background()
+	_, err := r.deliveries.InsertOne(ctx, delivery)
+	return err
+}
+
+// ClaimDueDelivery atomically moves the oldest due delivery to sending
+func (r *mongoWebhookRepository) ClaimDueDelivery(now time.Time) (*models.WebhookDelivery, error) {
+	ctx := context.Background()
+	var delivery models.WebhookDelivery
+	err := r.deliveries.FindOneAndUpdate(ctx,
+		bson.M{"status": models.WebhookDeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": models.WebhookDeliverySending, "claimed_at": now}},
+		options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1}).SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ReleaseStaleDeliveries returns deliveries left in sending by a crashed server to the queue
+func (r *mongoWebhookRepository) ReleaseStaleDeliveries(claimedBefore time.Time) (int64, error) {
+	ctx := context.Background()
+	result, err := r.deliveries.UpdateMany(ctx,
+		bson.M{"status": models.WebhookDeliverySending, "claimed_at": bson.M{"$lt": claimedBefore}},
+		bson.M{"$set": bson.M{"status": models.WebhookDeliveryPending}, "$unset": bson.M{"claimed_at": ""}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// RecordDeliveryAttempt appends an attempt to the delivery log and moves the delivery to status
+func (r *mongoWebhookRepository) RecordDeliveryAttempt(id string, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	ctx := context.Background()
+	set := bson.M{"status": status, "next_attempt_at": nextAttemptAt}
+	if status == models.WebhookDeliveryDelivered {
+		set["delivered_at"] = attempt.AttemptedAt
+	}
+	_, err := r.deliveries.UpdateOne(ctx,
+		bson.M{"id": id},
+		bson.M{
+			"$set":   set,
+			"$inc":   bson.M{"attempt_count": 1},
+			"$push":  bson.M{"attempts": attempt},
+			"$unset": bson.M{"claimed_at": ""},
+		},
+	)
+	return err
+}
+
+func (r *mongoWebhookRepository) GetDeliveries(webhookID, status string, limit int64) ([]*models.WebhookDelivery, error) {
+	ctx := context.Background()
+	filter := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := r.deliveries.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []*models.WebhookDelivery{}
+	for cursor.Next(ctx) {
+		var delivery models.WebhookDelivery
+		if err := cursor.Decode(&delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, cursor.Err()
+}
+
+func (r *mongoWebhookRepository) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	ctx := context.Background()
+	var delivery models.WebhookDelivery
+	err := r.deliveries.FindOne(ctx, bson.M{"id": id}).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// RequeueDelivery gives a dead-lettered delivery a fresh set of attempts
+func (r *mongoWebhookRepository) RequeueDelivery(id string, now time.Time) (bool, error) {
+	ctx := context.Background()
+	result, err := r.deliveries.UpdateOne(ctx,
+		bson.M{"id": id, "status": models.WebhookDeliveryDead},
+		bson.M{"$set": bson.M{"status": models.WebhookDeliveryPending, "next_attempt_at": now, "attempt_count": 0}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func AccountRoute(r *gin.Engine, accountService services.AccountService) {
+	accountController := controllers.NewAccountController(accountService)
+
+	rgu := r.Group("/users")
+	{
+		rgu.GET("/:username/export", accountController.ExportAccount)
+		rgu.DELETE("/:username", accountController.DeleteAccount)
+	}
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoute(r *gin.Engine, auditService services.AuditService) {
+	auditController := controllers.NewAuditController(auditService)
+
+	r.GET("/groups/:id/audit", auditController.ListGroupEntries)
+	r.GET("/admin/audit", auditController.ListEntries)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func BotRoute(r *gin.Engine, botService services.BotService) {
+	botController := controllers.NewBotController(botService)
+
+	// Bot management, done by the people who own the bots
+	rg := r.Group("/bots")
+	{
+		rg.POST("", botController.CreateBot)
+		rg.GET("", botController.ListBots)
+		rg.DELETE("/:username", botController.DeleteBot)
+		rg.POST("/:username/key", botController.RotateKey)
+	}
+
+	// Bot API, authenticated with the bot's API key
+	rb := r.Group("/bot")
+	{
+		rb.GET("/me", botController.Me)
+		rb.GET("/groups", botController.ListGroups)
+		rb.POST("/messages", botController.PostMessage)
+	}
+}
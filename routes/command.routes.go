@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func CommandRoute(r *gin.Engine, commandService services.CommandService) {
+	commandController := controllers.NewCommandController(commandService)
+
+	rgg := r.Group("/groups")
+	{
+		rgg.GET("/:id/commands", commandController.ListGroupCommands)
+		rgg.POST("/:id/commands", commandController.CreateGroupHook)
+		rgg.DELETE("/:id/commands/:command_id", commandController.DeleteHook)
+	}
+
+	rgc := r.Group("/commands")
+	{
+		rgc.GET("", commandController.ListGlobalCommands)
+		rgc.POST("", commandController.CreateGlobalHook)
+		rgc.DELETE("/:command_id", commandController.DeleteHook)
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ComplianceRoute(r *gin.Engine, complianceService services.ComplianceService) {
+	complianceController := controllers.NewComplianceController(complianceService)
+
+	rgu := r.Group("/admin")
+	{
+		rgu.GET("/exports", complianceController.Export)
+		rgu.POST("/imports", complianceController.Import)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ContactRoute(r *gin.Engine, contactService services.ContactService) {
+	contactController := controllers.NewContactController(contactService)
+
+	rgu := r.Group("/users/:username")
+	{
+		rgu.GET("/contacts", contactController.ListContacts)
+		rgu.DELETE("/contacts/:contact", contactController.RemoveContact)
+		rgu.GET("/friend-requests", contactController.ListFriendRequests)
+		rgu.POST("/friend-requests", contactController.SendFriendRequest)
+		rgu.POST("/friend-requests/:id/accept", contactController.AcceptFriendRequest)
+		rgu.POST("/friend-requests/:id/reject", contactController.RejectFriendRequest)
+		rgu.GET("/blocks", contactController.ListBlockedUsers)
+		rgu.POST("/blocks", contactController.BlockUser)
+		rgu.DELETE("/blocks/:blocked", contactController.UnblockUser)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ConversationRoute(r *gin.Engine, conversationService services.ConversationService) {
+	conversationController := controllers.NewConversationController(conversationService)
+
+	rgu := r.Group("/users/:username")
+	{
+		rgu.GET("/conversations", conversationController.ListConversations)
+		rgu.POST("/conversations/read", conversationController.MarkRead)
+		rgu.GET("/mentions", conversationController.ListMentions)
+		rgu.PUT("/messages/:receiver/message-ttl", conversationController.SetDirectMessageTTL)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func DigestRoute(r *gin.Engine, digestService services.DigestService) {
+	digestController := controllers.NewDigestController(digestService)
+
+	rgu := r.Group("/users/:username/digest")
+	{
+		rgu.GET("", digestController.GetSettings)
+		rgu.PUT("", digestController.UpdateSettings)
+		rgu.GET("/preview", digestController.Preview)
+	}
+}
@@ -19,5 +19,13 @@ func GroupRoute(r *gin.Engine, groupService services.GroupService) {
 		rgu.POST("/:id/admins", groupController.AddAdmin)
 		rgu.DELETE("/:id/admins/:username", groupController.RemoveAdmin)
 		rgu.GET("/:id/messages", groupController.GetGroupMessages)
+		rgu.PUT("/:id/message-ttl", groupController.SetMessageTTL)
+		rgu.PUT("/:id/topic", groupController.SetTopic)
+		rgu.GET("/:id/mutes", groupController.ListMutes)
+		rgu.POST("/:id/mutes", groupController.MuteMember)
+		rgu.DELETE("/:id/mutes/:username", groupController.UnmuteMember)
+		rgu.GET("/:id/bans", groupController.ListBans)
+		rgu.POST("/:id/bans", groupController.BanMember)
+		rgu.DELETE("/:id/bans/:username", groupController.UnbanMember)
 	}
 }
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ModerationRoute(r *gin.Engine, moderationService services.ModerationService) {
+	moderationController := controllers.NewModerationController(moderationService)
+
+	rgg := r.Group("/groups")
+	{
+		rgg.GET("/:id/moderation/rules", moderationController.ListRules)
+		rgg.POST("/:id/moderation/rules", moderationController.AddRule)
+		rgg.DELETE("/:id/moderation/rules/:rule_id", moderationController.DeleteRule)
+	}
+
+	rgm := r.Group("/moderation")
+	{
+		rgm.GET("/queue", moderationController.ListQueue)
+		rgm.POST("/queue/:id/resolve", moderationController.ResolveItem)
+	}
+
+	r.POST("/reports", moderationController.CreateReport)
+}
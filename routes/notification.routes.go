@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationRoute(r *gin.Engine, notificationService services.NotificationService) {
+	notificationController := controllers.NewNotificationController(notificationService)
+
+	rgu := r.Group("/users/:username/notifications")
+	{
+		rgu.GET("", notificationController.GetSettings)
+		rgu.PUT("/conversations", notificationController.SetConversationLevel)
+		rgu.DELETE("/conversations", notificationController.ClearConversationLevel)
+		rgu.PUT("/do-not-disturb", notificationController.SetDoNotDisturb)
+		rgu.DELETE("/do-not-disturb", notificationController.ClearDoNotDisturb)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func PushRoute(r *gin.Engine, pushService services.PushService) {
+	pushController := controllers.NewPushController(pushService)
+
+	rgu := r.Group("/users/:username/devices")
+	{
+		rgu.GET("", pushController.ListDevices)
+		rgu.POST("", pushController.RegisterDevice)
+		rgu.DELETE("/:id", pushController.DeleteDevice)
+	}
+
+	r.GET("/push/vapid-public-key", pushController.GetVAPIDPublicKey)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func RetentionRoute(r *gin.Engine, retentionService services.RetentionService) {
+	retentionController := controllers.NewRetentionController(retentionService)
+
+	rgu := r.Group("/admin")
+	{
+		rgu.GET("/retention/policies", retentionController.ListPolicies)
+		rgu.PUT("/retention/policies/global", retentionController.SetGlobalPolicy)
+		rgu.DELETE("/retention/policies/global", retentionController.DeleteGlobalPolicy)
+		rgu.PUT("/retention/policies/groups/:id", retentionController.SetGroupPolicy)
+		rgu.DELETE("/retention/policies/groups/:id", retentionController.DeleteGroupPolicy)
+		rgu.GET("/retention/runs", retentionController.ListPurgeRuns)
+		rgu.POST("/retention/purge", retentionController.Purge)
+		rgu.GET("/legal-holds", retentionController.ListLegalHolds)
+		rgu.PUT("/legal-holds/groups/:id", retentionController.SetGroupLegalHold)
+		rgu.PUT("/legal-holds/users/:username", retentionController.SetUserLegalHold)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ScheduledMessageRoute(r *gin.Engine, scheduledMessageService services.ScheduledMessageService) {
+	scheduledMessageController := controllers.NewScheduledMessageController(scheduledMessageService)
+
+	rgu := r.Group("/users/:username/scheduled-messages")
+	{
+		rgu.GET("", scheduledMessageController.ListScheduledMessages)
+		rgu.PATCH("/:id", scheduledMessageController.UpdateScheduledMessage)
+		rgu.DELETE("/:id", scheduledMessageController.CancelScheduledMessage)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func StreamRoute(r *gin.Engine, websocketService services.WebsocketService, botService services.BotService) {
+	streamController := controllers.NewStreamController(websocketService, botService)
+
+	r.GET("/sse", streamController.HandleSSE)
+	r.GET("/poll", streamController.HandlePoll)
+	rgu := r.Group("/streams")
+	{
+		rgu.POST("/:session_id/messages", streamController.PostFrame)
+		rgu.DELETE("/:session_id", streamController.CloseStream)
+	}
+}
@@ -14,6 +14,7 @@ func UserRoute(r *gin.Engine, userService services.UserService, websocketService
 		rgu.GET("/:username", userController.GetUser)
 		rgu.GET("/", userController.GetAllUsers)
 		rgu.POST("/", userController.CreateUser)
+		rgu.PATCH("/:username", userController.UpdateProfile)
 		rgu.GET("/online", userController.ListOnlineUsers)
 		rgu.GET("/:username/groups", userController.ListUserGroups)
 		rgu.GET("/:username/messages/:receiver", userController.GetDirectMessages)
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/JomnoiZ/network-backend-group-13.git/controllers"
+	"github.com/JomnoiZ/network-backend-group-13.git/services"
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoute(r *gin.Engine, webhookService services.WebhookService) {
+	webhookController := controllers.NewWebhookController(webhookService)
+
+	rg := r.Group("/webhooks")
+	{
+		rg.POST("", webhookController.CreateWebhook)
+		rg.GET("", webhookController.ListWebhooks)
+		rg.DELETE("/:id", webhookController.DeleteWebhook)
+		rg.POST("/:id/ping", webhookController.Ping)
+		rg.GET("/:id/deliveries", webhookController.ListDeliveries)
+		rg.POST("/:id/deliveries/:delivery_id/redeliver", webhookController.Redeliver)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func WebsocketRoute(websocketService services.WebsocketService, r *gin.Engine) {
-    websocketController := controllers.NewWebsocketController(websocketService)
+func WebsocketRoute(websocketService services.WebsocketService, botService services.BotService, r *gin.Engine) {
+    websocketController := controllers.NewWebsocketController(websocketService, botService)
     r.GET("/ws", websocketController.HandleWebSocket)
 }
\ No newline at end of file
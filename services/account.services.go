@@ -0,0 +1,243 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+type accountService struct {
+	userRepository         database.UserRepository
+	groupRepository        database.GroupRepository
+	messageRepository      database.MessageRepository
+	contactRepository      database.ContactRepository
+	readCursorRepository   database.ReadCursorRepository
+	scheduledRepository    database.ScheduledMessageRepository
+	settingsRepository     database.ConversationSettingsRepository
+	notificationRepository database.NotificationSettingsRepository
+	pushDeviceRepository   database.PushDeviceRepository
+	digestRepository       database.DigestSettingsRepository
+	complianceService      ComplianceService
+	botService             BotService
+	auditService           AuditService
+	websocketService       WebsocketService
+}
+
+type AccountService interface {
+	AuthorizeExport(username, requester string) error
+	Export(w io.Writer, username, requester string) error
+	DeleteAccount(username, requester string) (*models.AccountDeletion, error)
+}
+
+func NewAccountService(userRepo database.UserRepository, groupRepo database.GroupRepository, messageRepo database.MessageRepository, contactRepo database.ContactRepository, readCursorRepo database.ReadCursorRepository, scheduledRepo database.ScheduledMessageRepository, settingsRepo database.ConversationSettingsRepository, notificationRepo database.NotificationSettingsRepository, pushDeviceRepo database.PushDeviceRepository, digestRepo database.DigestSettingsRepository, complianceService ComplianceService, botService BotService, auditService AuditService, wsService WebsocketService) AccountService {
+	return &accountService{
+		userRepository:         userRepo,
+		groupRepository:        groupRepo,
+		messageRepository:      messageRepo,
+		contactRepository:      contactRepo,
+		readCursorRepository:   readCursorRepo,
+		scheduledRepository:    scheduledRepo,
+		settingsRepository:     settingsRepo,
+		notificationRepository: notificationRepo,
+		pushDeviceRepository:   pushDeviceRepo,
+		digestRepository:       digestRepo,
+		complianceService:      complianceService,
+		botService:             botService,
+		auditService:           auditService,
+		websocketService:       wsService,
+	}
+}
+
+func (s *accountService) AuthorizeExport(username, requester string) error {
+	return s.complianceService.AuthorizeAccountExport(username, requester)
+}
+
+func (s *accountService) Export(w io.Writer, username, requester string) error {
+	return s.complianceService.ExportAccount(w, username, requester)
+}
+
+// DeleteAccount removes a user and everything tied to them. Their messages are deleted or
+// anonymised according to configs.DeletedMessagePolicy; messages under legal hold are left
+// as they are.
+func (s *accountService) DeleteAccount(username, requester string) (*models.AccountDeletion, error) {
+	if requester != username && !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: users can only delete their own account")
+	}
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.LegalHold {
+		return nil, errors.New("account is on legal hold")
+	}
+
+	// End every session and refuse new ones before anything is removed, so the user
+	// cannot act on a half deleted account
+	s.websocketService.BlockSessions(username)
+	defer s.websocketService.UnblockSessions(username)
+	s.websocketService.DisconnectUser(username, "Account deleted")
+
+	deletion := &models.AccountDeletion{
+		Username:          username,
+		Pseudonym:         models.DeletedUserPrefix + uuid.New().String()[:8],
+		MessagePolicy:     configs.DeletedMessagePolicy(),
+		GroupsLeft:        []string{},
+		GroupsTransferred: []string{},
+		GroupsDeleted:     []string{},
+		BotsDeleted:       []string{},
+	}
+
+	if err := s.leaveGroups(deletion, requester); err != nil {
+		return nil, err
+	}
+	if err := s.deleteBots(deletion); err != nil {
+		return nil, err
+	}
+
+	// Messages in held groups and direct conversations with held users stay untouched
+	heldGroups, err := s.groupRepository.GetGroupsOnLegalHold()
+	if err != nil {
+		return nil, err
+	}
+	heldUsers, err := s.userRepository.GetUsersOnLegalHold()
+	if err != nil {
+		return nil, err
+	}
+	if deletion.MessagePolicy == models.DeletedMessagesDelete {
+		deletion.MessagesDeleted, err = s.messageRepository.DeleteUserMessages(username, heldGroups, heldUsers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Whatever is left, including direct messages others sent them, keeps its content
+	deletion.MessagesAnonymized, err = s.messageRepository.AnonymizeUserMessages(username, deletion.Pseudonym, heldGroups, heldUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.scheduledRepository.DeleteScheduledMessages(username); err != nil {
+		return nil, err
+	}
+	if err := s.contactRepository.DeleteUserRelations(username); err != nil {
+		return nil, err
+	}
+	if err := s.readCursorRepository.DeleteReadCursors(username); err != nil {
+		return nil, err
+	}
+	if err := s.settingsRepository.DeleteDirectSettings(username); err != nil {
+		return nil, err
+	}
+	if err := s.notificationRepository.DeleteNotificationSettings(username); err != nil {
+		return nil, err
+	}
+	if err := s.pushDeviceRepository.DeletePushDevices(username); err != nil {
+		return nil, err
+	}
+	if err := s.digestRepository.DeleteDigestSettings(username); err != nil {
+		return nil, err
+	}
+	if err := s.userRepository.DeleteUser(username); err != nil {
+		return nil, err
+	}
+
+	deletion.DeletedAt = time.Now()
+	log.Printf("Account %s deleted by %s: %d messages deleted, %d anonymised, %d groups deleted",
+		username, requester, deletion.MessagesDeleted, deletion.MessagesAnonymized, len(deletion.GroupsDeleted))
+	return deletion, nil
+}
+
+// leaveGroups removes the user from every group. Groups they own pass to the longest
+// standing admin, then to the longest standing member; groups left empty are deleted
+// unless they are on legal hold. Every change is recorded in the audit log as done by requester.
+func (s *accountService) leaveGroups(deletion *models.AccountDeletion, requester string) error {
+	username := deletion.Username
+	groups, err := s.userRepository.GetUserGroups(username)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		before := snapshotGroup(group)
+		group.Members = withoutUser(group.Members, username)
+		group.Admins = withoutUser(group.Admins, username)
+
+		if len(group.Members) == 0 && !group.LegalHold {
+			if _, err := s.messageRepository.DeleteGroupMessages(group.ID); err != nil {
+				return err
+			}
+			if err := s.groupRepository.DeleteGroup(group.ID); err != nil {
+				return err
+			}
+			s.auditService.Record(requester, models.AuditGroupDeleted, group.ID, before, nil)
+			s.websocketService.KickFromGroup(username, group.ID)
+			deletion.GroupsDeleted = append(deletion.GroupsDeleted, group.ID)
+			continue
+		}
+
+		previousOwner := group.Owner
+		if group.Owner == username {
+			group.Owner = ""
+			if len(group.Admins) > 0 {
+				group.Owner = group.Admins[0]
+			} else if len(group.Members) > 0 {
+				group.Owner = group.Members[0]
+				group.Admins = append(group.Admins, group.Owner)
+			}
+		}
+		if err := s.groupRepository.UpdateGroup(group); err != nil {
+			return err
+		}
+		s.auditService.Record(requester, models.AuditMemberLeft, username, before, group)
+		if group.Owner != previousOwner {
+			s.auditService.Record(requester, models.AuditOwnerChanged, group.Owner, before, group)
+		}
+
+		s.websocketService.InvalidateAudience(group.Members...)
+		s.websocketService.KickFromGroup(username, group.ID)
+		s.websocketService.NotifyGroupUpdate(group.ID, "member_left", map[string]string{"username": username})
+		if group.Owner != previousOwner {
+			s.websocketService.NotifyGroupUpdate(group.ID, "owner_changed", map[string]string{"username": group.Owner})
+			deletion.GroupsTransferred = append(deletion.GroupsTransferred, group.ID)
+		} else {
+			deletion.GroupsLeft = append(deletion.GroupsLeft, group.ID)
+		}
+	}
+	return nil
+}
+
+// deleteBots removes the bots the user owns, so none is left without anyone to manage it
+func (s *accountService) deleteBots(deletion *models.AccountDeletion) error {
+	bots, err := s.botService.ListBots(deletion.Username)
+	if err != nil {
+		return err
+	}
+	for _, bot := range bots {
+		// System admins are listed every bot
+		if bot.Owner != deletion.Username {
+			continue
+		}
+		if err := s.botService.DeleteBot(bot.Username, deletion.Username); err != nil {
+			return err
+		}
+		deletion.BotsDeleted = append(deletion.BotsDeleted, bot.Username)
+	}
+	return nil
+}
+
+func withoutUser(usernames []string, username string) []string {
+	remaining := []string{}
+	for _, u := range usernames {
+		if u != username {
+			remaining = append(remaining, u)
+		}
+	}
+	return remaining
+}
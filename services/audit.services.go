@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+type auditService struct {
+	auditRepository database.AuditRepository
+	groupRepository database.GroupRepository
+}
+
+type AuditService interface {
+	Record(actor, action, target string, before, after *models.Group)
+	RecordLegalHold(actor, target string, wasHeld, held bool, before, after *models.Group)
+	ListGroupEntries(groupID, requester string, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	ListEntries(requester string, filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+func NewAuditService(auditRepo database.AuditRepository, groupRepo database.GroupRepository) AuditService {
+	return &auditService{
+		auditRepository: auditRepo,
+		groupRepository: groupRepo,
+	}
+}
+
+// Record appends an entry for an action that already happened. A failure is logged
+// rather than returned since the change itself cannot be rolled back.
+func (s *auditService) Record(actor, action, target string, before, after *models.Group) {
+	s.append(newAuditEntry(actor, action, target, before, after))
+}
+
+// RecordLegalHold appends a legal_hold.set or legal_hold.released entry. before and after
+// are the group on hold, or nil for a user's hold.
+func (s *auditService) RecordLegalHold(actor, target string, wasHeld, held bool, before, after *models.Group) {
+	action := models.AuditLegalHoldReleased
+	if held {
+		action = models.AuditLegalHoldSet
+	}
+	entry := newAuditEntry(actor, action, target, before, after)
+	entry.LegalHold = &models.AuditLegalHold{Before: wasHeld, After: held}
+	s.append(entry)
+}
+
+func newAuditEntry(actor, action, target string, before, after *models.Group) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		ID:        uuid.New().String(),
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    auditGroup(before),
+		After:     auditGroup(after),
+		CreatedAt: time.Now(),
+	}
+	if after != nil {
+		entry.GroupID = after.ID
+	} else if before != nil {
+		entry.GroupID = before.ID
+	}
+	return entry
+}
+
+func (s *auditService) append(entry *models.AuditEntry) {
+	if err := s.auditRepository.AppendAuditEntry(entry); err != nil {
+		log.Printf("Failed to record audit entry %s by %s on %s: %v", entry.Action, entry.Actor, entry.Target, err)
+	}
+}
+
+// ListGroupEntries returns a group's audit trail to its owner or a system admin
+func (s *auditService) ListGroupEntries(groupID, requester string, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if !configs.IsSystemAdmin(requester) {
+		group, err := s.groupRepository.GetGroup(groupID)
+		if err != nil || group == nil {
+			return nil, errors.New("group not found")
+		}
+		if group.Owner != requester {
+			return nil, errors.New("unauthorized: only the group owner or system admins can read the audit log")
+		}
+	}
+	filter.GroupID = groupID
+	return s.list(filter)
+}
+
+// ListEntries returns audit entries across every group to a system admin
+func (s *auditService) ListEntries(requester string, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: only system admins can read the audit log")
+	}
+	return s.list(filter)
+}
+
+func (s *auditService) list(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Limit < 0 || filter.Limit > maxAuditLimit {
+		return nil, errors.New("invalid audit query: limit must be between 1 and 500")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	return s.auditRepository.GetAuditEntries(filter)
+}
+
+// snapshotGroup copies a group so later in-place changes do not leak into a recorded state
+func snapshotGroup(group *models.Group) *models.Group {
+	if group == nil {
+		return nil
+	}
+	snapshot := *group
+	snapshot.Admins = append([]string{}, group.Admins...)
+	snapshot.Members = append([]string{}, group.Members...)
+	snapshot.Mutes = append([]models.GroupMute{}, group.Mutes...)
+	snapshot.Bans = append([]models.GroupBan{}, group.Bans...)
+	return &snapshot
+}
+
+// auditGroup copies a group into the form kept in the audit log
+func auditGroup(group *models.Group) *models.AuditGroup {
+	if group == nil {
+		return nil
+	}
+	return &models.AuditGroup{
+		ID:         group.ID,
+		Name:       group.Name,
+		Topic:      group.Topic,
+		Owner:      group.Owner,
+		Admins:     append([]string{}, group.Admins...),
+		Members:    append([]string{}, group.Members...),
+		MessageTTL: group.MessageTTL,
+		LegalHold:  group.LegalHold,
+		Mutes:      append([]models.GroupMute{}, group.Mutes...),
+		Bans:       append([]models.GroupBan{}, group.Bans...),
+		CreatedAt:  group.CreatedAt,
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// fakeAuditRepository keeps appended entries in memory
+type fakeAuditRepository struct {
+	database.AuditRepository
+	entries []*models.AuditEntry
+}
+
+func (r *fakeAuditRepository) AppendAuditEntry(entry *models.AuditEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// servedEntry decodes an entry the way an API client sees it
+func servedEntry(t *testing.T, entry *models.AuditEntry) map[string]map[string]interface{} {
+	t.Helper()
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := map[string]map[string]interface{}{}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(raw, &fields)
+	for _, name := range []string{"before", "after", "legal_hold"} {
+		var value map[string]interface{}
+		json.Unmarshal(fields[name], &value)
+		served[name] = value
+	}
+	return served
+}
+
+func TestAuditedGroupLegalHoldShowsTheChange(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	s := NewAuditService(repo, nil)
+	before := &models.Group{ID: "g1", Name: "Team"}
+	after := &models.Group{ID: "g1", Name: "Team", LegalHold: true}
+
+	s.RecordLegalHold("root", "g1", false, true, before, after)
+
+	if len(repo.entries) != 1 {
+		t.Fatalf("appended %d entries, want 1", len(repo.entries))
+	}
+	entry := repo.entries[0]
+	if entry.Action != models.AuditLegalHoldSet || entry.GroupID != "g1" {
+		t.Errorf("entry = %s on %s, want %s on g1", entry.Action, entry.GroupID, models.AuditLegalHoldSet)
+	}
+	served := servedEntry(t, entry)
+	if served["before"]["legal_hold"] != false || served["after"]["legal_hold"] != true {
+		t.Errorf("served group hold %v -> %v, want false -> true", served["before"]["legal_hold"], served["after"]["legal_hold"])
+	}
+	if served["legal_hold"]["before"] != false || served["legal_hold"]["after"] != true {
+		t.Errorf("served legal_hold = %v, want false -> true", served["legal_hold"])
+	}
+}
+
+func TestAuditedUserLegalHoldShowsTheChange(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	s := NewAuditService(repo, nil)
+
+	s.RecordLegalHold("root", "bob", true, false, nil, nil)
+
+	entry := repo.entries[0]
+	if entry.Action != models.AuditLegalHoldReleased || entry.Target != "bob" || entry.GroupID != "" {
+		t.Errorf("entry = %s on %s in group %q", entry.Action, entry.Target, entry.GroupID)
+	}
+	if served := servedEntry(t, entry); served["legal_hold"]["before"] != true || served["legal_hold"]["after"] != false {
+		t.Errorf("served legal_hold = %v, want true -> false", served["legal_hold"])
+	}
+}
+
+func TestAuditedModerationShowsMutesAndBans(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	s := NewAuditService(repo, nil)
+	group := &models.Group{ID: "g1", Name: "Team", Members: []string{"alice", "bob", "carol"}}
+	before := snapshotGroup(group)
+	group.Members = []string{"alice", "carol"}
+	group.Bans = append(group.Bans, models.GroupBan{Username: "bob", BannedBy: "alice"})
+	group.Mutes = append(group.Mutes, models.GroupMute{Username: "carol", MutedBy: "alice"})
+
+	s.Record("alice", models.AuditMemberBanned, "bob", before, group)
+	group.Bans = nil
+
+	served := servedEntry(t, repo.entries[0])
+	if served["before"]["bans"] != nil || served["before"]["mutes"] != nil {
+		t.Errorf("before = %v, want no bans or mutes", served["before"])
+	}
+	bans, _ := served["after"]["bans"].([]interface{})
+	mutes, _ := served["after"]["mutes"].([]interface{})
+	if len(bans) != 1 || bans[0].(map[string]interface{})["username"] != "bob" {
+		t.Errorf("after bans = %v, want bob's ban recorded despite later changes", served["after"]["bans"])
+	}
+	if len(mutes) != 1 || mutes[0].(map[string]interface{})["username"] != "carol" {
+		t.Errorf("after mutes = %v, want carol's mute", served["after"]["mutes"])
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxBotDescriptionLength = 500
+	// botTouchInterval limits how often last_used_at is written for a busy bot
+	botTouchInterval = time.Minute
+)
+
+var botUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,32}$`)
+
+type botService struct {
+	botRepository   database.BotRepository
+	userRepository  database.UserRepository
+	groupRepository database.GroupRepository
+	auditService    AuditService
+	websocket       WebsocketService
+}
+
+type BotService interface {
+	CreateBot(username, displayName, description, requester string) (*models.BotCredentials, error)
+	ListBots(requester string) ([]*models.Bot, error)
+	DeleteBot(username, requester string) error
+	RotateKey(username, requester string) (*models.BotCredentials, error)
+	Authenticate(apiKey string) (*models.Bot, error)
+	IsBot(username string) bool
+	ListGroups(bot *models.Bot) ([]*models.Group, error)
+	PostMessage(bot *models.Bot, message *models.BotMessage) (*models.Message, error)
+}
+
+func NewBotService(botRepo database.BotRepository, userRepo database.UserRepository, groupRepo database.GroupRepository, auditService AuditService, ws WebsocketService) BotService {
+	return &botService{
+		botRepository:   botRepo,
+		userRepository:  userRepo,
+		groupRepository: groupRepo,
+		auditService:    auditService,
+		websocket:       ws,
+	}
+}
+
+func (s *botService) CreateBot(username, displayName, description, requester string) (*models.BotCredentials, error) {
+	if !botUsernamePattern.MatchString(username) || strings.HasPrefix(username, models.DeletedUserPrefix) {
+		return nil, errors.New("invalid bot: username must be 3 to 32 letters, digits, '-' or '_'")
+	}
+	if len(displayName) > maxDisplayNameLength {
+		return nil, errors.New("invalid bot: display name is too long")
+	}
+	if len(description) > maxBotDescriptionLength {
+		return nil, errors.New("invalid bot: description is too long")
+	}
+	owner, err := s.userRepository.GetUser(requester)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, errors.New("unauthorized: only registered users can create bots")
+	}
+
+	// Bots and people share one namespace in groups and messages
+	if user, err := s.userRepository.GetUser(username); err != nil {
+		return nil, err
+	} else if user != nil {
+		return nil, errors.New("username is already taken")
+	}
+	if existing, err := s.botRepository.GetBot(username); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, errors.New("username is already taken")
+	}
+
+	key, hash, hint, err := newBotKey()
+	if err != nil {
+		return nil, err
+	}
+	bot := &models.Bot{
+		Username:    username,
+		DisplayName: displayName,
+		Description: description,
+		Owner:       requester,
+		KeyHash:     hash,
+		KeyHint:     hint,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.botRepository.CreateBot(bot); err != nil {
+		return nil, err
+	}
+	log.Printf("User %s created bot %s", requester, username)
+	return &models.BotCredentials{Bot: bot, APIKey: key}, nil
+}
+
+// ListBots returns the requester's bots, or every bot for system admins
+func (s *botService) ListBots(requester string) ([]*models.Bot, error) {
+	if requester == "" {
+		return nil, errors.New("unauthorized: requester is required")
+	}
+	owner := requester
+	if configs.IsSystemAdmin(requester) {
+		owner = ""
+	}
+	return s.botRepository.GetBots(owner)
+}
+
+// getOwned loads a bot the requester may manage
+func (s *botService) getOwned(username, requester string) (*models.Bot, error) {
+	bot, err := s.botRepository.GetBot(username)
+	if err != nil {
+		return nil, err
+	}
+	if bot == nil {
+		return nil, errors.New("bot not found")
+	}
+	if bot.Owner != requester && !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: only the owner can manage this bot")
+	}
+	return bot, nil
+}
+
+// DeleteBot removes a bot from its groups and disconnects it. Its messages stay in history.
+func (s *botService) DeleteBot(username, requester string) error {
+	if _, err := s.getOwned(username, requester); err != nil {
+		return err
+	}
+	groups, err := s.userRepository.GetUserGroups(username)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		before := snapshotGroup(group)
+		group.Members = withoutUser(group.Members, username)
+		group.Admins = withoutUser(group.Admins, username)
+		if err := s.groupRepository.UpdateGroup(group); err != nil {
+			return err
+		}
+		s.auditService.Record(requester, models.AuditMemberRemoved, username, before, group)
+		s.websocket.KickFromGroup(username, group.ID)
+		s.websocket.InvalidateAudience(group.Members...)
+	}
+	if _, err := s.botRepository.DeleteBot(username); err != nil {
+		return err
+	}
+	s.websocket.DisconnectUser(username, "bot deleted")
+	log.Printf("User %s deleted bot %s", requester, username)
+	return nil
+}
+
+// RotateKey replaces a bot's API key; the old key stops working immediately
+func (s *botService) RotateKey(username, requester string) (*models.BotCredentials, error) {
+	bot, err := s.getOwned(username, requester)
+	if err != nil {
+		return nil, err
+	}
+	key, hash, hint, err := newBotKey()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.botRepository.UpdateBotKey(username, hash, hint)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("bot not found")
+	}
+	bot.KeyHash, bot.KeyHint = hash, hint
+	s.websocket.DisconnectUser(username, "api key rotated")
+	return &models.BotCredentials{Bot: bot, APIKey: key}, nil
+}
+
+func (s *botService) Authenticate(apiKey string) (*models.Bot, error) {
+	if !strings.HasPrefix(apiKey, models.BotKeyPrefix) {
+		return nil, errors.New("unauthorized: invalid api key")
+	}
+	bot, err := s.botRepository.GetBotByKeyHash(hashBotKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	if bot == nil {
+		return nil, errors.New("unauthorized: invalid api key")
+	}
+	now := time.Now()
+	if bot.LastUsedAt == nil || now.Sub(*bot.LastUsedAt) > botTouchInterval {
+		if err := s.botRepository.TouchBot(bot.Username, now); err != nil {
+			log.Printf("Failed to record use of bot %s: %v", bot.Username, err)
+		}
+		bot.LastUsedAt = &now
+	}
+	return bot, nil
+}
+
+// IsBot reports whether username belongs to a bot; lookup failures count as a bot so
+// the name cannot be borrowed while the database is unreachable
+func (s *botService) IsBot(username string) bool {
+	bot, err := s.botRepository.GetBot(username)
+	return err != nil || bot != nil
+}
+
+func (s *botService) ListGroups(bot *models.Bot) ([]*models.Group, error) {
+	return s.userRepository.GetUserGroups(bot.Username)
+}
+
+func (s *botService) PostMessage(bot *models.Bot, message *models.BotMessage) (*models.Message, error) {
+	if strings.TrimSpace(message.Content) == "" {
+		return nil, errors.New("invalid message: content is required")
+	}
+	group, err := s.groupRepository.GetGroup(message.GroupID)
+	if err != nil || group == nil {
+		return nil, errors.New("group not found")
+	}
+	if !group.IsMember(bot.Username) {
+		return nil, errors.New("unauthorized: bot is not a member of this group")
+	}
+	now := time.Now()
+	if group.IsBanned(bot.Username, now) {
+		return nil, errors.New("unauthorized: bot is banned from this group")
+	}
+	if group.IsMuted(bot.Username, now) {
+		return nil, errors.New("unauthorized: bot is muted in this group")
+	}
+	msg := &models.Message{
+		ID:      uuid.New().String(),
+		Sender:  bot.Username,
+		GroupID: message.GroupID,
+		Content: message.Content,
+		Bot:     true,
+	}
+	if err := s.websocket.DispatchMessage(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// newBotKey returns a fresh API key with the hash that is stored and a short hint
+// that lets owners tell their keys apart
+func newBotKey() (key, hash, hint string, err error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	key = models.BotKeyPrefix + hex.EncodeToString(secret)
+	return key, hashBotKey(key), key[len(key)-4:], nil
+}
+
+func hashBotKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	commandHookTimeout = 3 * time.Second
+	maxCommandReply    = 4000
+)
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// CommandHandler runs a command and returns the reply shown to the invoker
+type CommandHandler func(invocation *models.CommandInvocation) (string, error)
+
+// CommandExecutor answers slash commands typed into a conversation
+type CommandExecutor interface {
+	Execute(invocation *models.CommandInvocation) string
+}
+
+type command struct {
+	info      models.CommandInfo
+	groupOnly bool
+	handler   CommandHandler
+}
+
+type commandService struct {
+	commandRepository database.CommandRepository
+	groupRepository   database.GroupRepository
+	userRepository    database.UserRepository
+	botRepository     database.BotRepository
+	groupService      GroupService
+	moderator         MessageModerator
+	client            *http.Client
+
+	commands map[string]*command
+	mutex    sync.RWMutex
+}
+
+type CommandService interface {
+	CommandExecutor
+	Register(name, usage, description string, groupOnly bool, handler CommandHandler)
+	ListCommands(groupID, requester string) ([]models.CommandInfo, error)
+	CreateHook(groupID, name, url, usage, description, requester string) (*models.CommandHook, error)
+	DeleteHook(id, requester string) error
+}
+
+func NewCommandService(commandRepo database.CommandRepository, groupRepo database.GroupRepository, userRepo database.UserRepository, botRepo database.BotRepository, groupService GroupService, moderator MessageModerator) CommandService {
+	s := &commandService{
+		commandRepository: commandRepo,
+		groupRepository:   groupRepo,
+		userRepository:    userRepo,
+		botRepository:     botRepo,
+		groupService:      groupService,
+		moderator:         moderator,
+		client:            newOutboundClient(commandHookTimeout),
+		commands:          make(map[string]*command),
+	}
+	s.Register("help", "/help", "List the commands available here", false, s.help)
+	s.Register("kick", "/kick <username>", "Remove a member from the group", true, s.kick)
+	s.Register("mute", "/mute <username> [duration]", "Stop a member from posting, e.g. /mute bob 30m", true, s.mute)
+	s.Register("topic", "/topic [text]", "Show the group topic, or change it", true, s.topic)
+	s.Register("invite", "/invite <username>", "Add someone to the group", true, s.invite)
+	return s
+}
+
+// Register adds a built-in command. groupOnly commands are refused outside groups.
+func (s *commandService) Register(name, usage, description string, groupOnly bool, handler CommandHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands[name] = &command{
+		info:      models.CommandInfo{Name: name, Usage: usage, Description: description, Builtin: true},
+		groupOnly: groupOnly,
+		handler:   handler,
+	}
+}
+
+// parseCommand turns a message starting with "/" into an invocation. Messages that
+// only look like commands, such as paths, and messages escaped with "//" are left alone.
+func parseCommand(msg *models.Message) *models.CommandInvocation {
+	if !strings.HasPrefix(msg.Content, "/") || strings.HasPrefix(msg.Content, "//") {
+		return nil
+	}
+	fields := strings.Fields(msg.Content[1:])
+	if len(fields) == 0 {
+		return nil
+	}
+	name := strings.ToLower(fields[0])
+	if !commandNamePattern.MatchString(name) {
+		return nil
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg.Content[1:]), fields[0]))
+	return &models.CommandInvocation{
+		Command:  name,
+		Args:     fields[1:],
+		Text:     text,
+		Invoker:  msg.Sender,
+		GroupID:  msg.GroupID,
+		Receiver: msg.Receiver,
+	}
+}
+
+// Execute runs a command and returns the reply for the invoker; failures are replied too
+func (s *commandService) Execute(invocation *models.CommandInvocation) string {
+	if invocation.GroupID != "" {
+		group, err := s.groupRepository.GetGroup(invocation.GroupID)
+		if err != nil || group == nil {
+			return "group not found"
+		}
+		if !group.IsMember(invocation.Invoker) && !configs.IsSystemAdmin(invocation.Invoker) {
+			return "you are not a member of this group"
+		}
+	}
+
+	s.mutex.RLock()
+	builtin := s.commands[invocation.Command]
+	s.mutex.RUnlock()
+
+	var reply string
+	var err error
+	if builtin != nil {
+		if builtin.groupOnly && invocation.GroupID == "" {
+			return fmt.Sprintf("/%s only works in groups", invocation.Command)
+		}
+		reply, err = builtin.handler(invocation)
+	} else {
+		var hook *models.CommandHook
+		hook, err = s.commandRepository.FindCommandHook(invocation.GroupID, invocation.Command)
+		if err == nil && hook == nil {
+			return fmt.Sprintf("unknown command /%s, try /help", invocation.Command)
+		}
+		if err == nil {
+			reply, err = s.callHook(hook, invocation)
+		}
+	}
+	if err != nil {
+		log.Printf("Command /%s from %s failed: %v", invocation.Command, invocation.Invoker, err)
+		return fmt.Sprintf("/%s failed: %v", invocation.Command, err)
+	}
+	return reply
+}
+
+// callHook posts the invocation to a command hook, signed like webhook deliveries
+func (s *commandService) callHook(hook *models.CommandHook, invocation *models.CommandInvocation) (string, error) {
+	payload, err := json.Marshal(invocation)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", hook.ID)
+	req.Header.Set("X-Webhook-Event", "command")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(hook.Secret, timestamp, string(payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", errors.New("the command did not answer")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("the command answered %s", resp.Status)
+	}
+	var reply models.CommandHookReply
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &reply); err != nil {
+			return "", errors.New("the command answered with invalid JSON")
+		}
+	}
+	return truncate(reply.Content, maxCommandReply), nil
+}
+
+func (s *commandService) help(invocation *models.CommandInvocation) (string, error) {
+	commands, err := s.available(invocation.GroupID)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{"Commands:"}
+	for _, info := range commands {
+		usage := info.Usage
+		if usage == "" {
+			usage = "/" + info.Name
+		}
+		line := usage
+		if info.Description != "" {
+			line += " - " + info.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *commandService) kick(invocation *models.CommandInvocation) (string, error) {
+	if len(invocation.Args) != 1 {
+		return "usage: /kick <username>", nil
+	}
+	username := invocation.Args[0]
+	if err := s.groupService.KickMember(invocation.GroupID, username, invocation.Invoker); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Removed %s from the group", username), nil
+}
+
+func (s *commandService) mute(invocation *models.CommandInvocation) (string, error) {
+	if len(invocation.Args) < 1 || len(invocation.Args) > 2 {
+		return "usage: /mute <username> [duration]", nil
+	}
+	username := invocation.Args[0]
+	var duration time.Duration
+	if len(invocation.Args) == 2 {
+		parsed, err := time.ParseDuration(invocation.Args[1])
+		if err != nil || parsed <= 0 {
+			return "invalid duration, use for example 30m or 2h", nil
+		}
+		duration = parsed
+	}
+	if err := s.groupService.MuteMember(invocation.GroupID, username, duration, invocation.Invoker); err != nil {
+		return "", err
+	}
+	if duration == 0 {
+		return fmt.Sprintf("Muted %s until unmuted", username), nil
+	}
+	return fmt.Sprintf("Muted %s for %s", username, duration), nil
+}
+
+func (s *commandService) topic(invocation *models.CommandInvocation) (string, error) {
+	if invocation.Text == "" {
+		group, err := s.groupService.GetGroup(invocation.GroupID)
+		if err != nil || group == nil {
+			return "", errors.New("group not found")
+		}
+		if group.Topic == "" {
+			return "This group has no topic", nil
+		}
+		return "Topic: " + group.Topic, nil
+	}
+	// The topic is shown to every member, so it gets the same filters as a message
+	topic := invocation.Text
+	if s.moderator != nil {
+		verdict := s.moderator.Moderate(&models.Message{
+			Type:    "message",
+			Sender:  invocation.Invoker,
+			GroupID: invocation.GroupID,
+			Content: topic,
+		})
+		if verdict.Action == models.ModerationReject {
+			return "", fmt.Errorf("topic rejected: %s", verdict.Reason)
+		}
+		topic = verdict.Content
+	}
+	if err := s.groupService.SetTopic(invocation.GroupID, topic, invocation.Invoker); err != nil {
+		return "", err
+	}
+	return "Topic updated", nil
+}
+
+func (s *commandService) invite(invocation *models.CommandInvocation) (string, error) {
+	if len(invocation.Args) != 1 {
+		return "usage: /invite <username>", nil
+	}
+	username := invocation.Args[0]
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		bot, err := s.botRepository.GetBot(username)
+		if err != nil {
+			return "", err
+		}
+		if bot == nil {
+			return "", errors.New("user not found")
+		}
+	}
+	if err := s.groupService.AddMember(invocation.GroupID, username, invocation.Invoker); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Added %s to the group", username), nil
+}
+
+// available lists the built-in and hook commands usable in a conversation
+func (s *commandService) available(groupID string) ([]models.CommandInfo, error) {
+	commands := []models.CommandInfo{}
+	s.mutex.RLock()
+	for _, builtin := range s.commands {
+		if groupID != "" || !builtin.groupOnly {
+			commands = append(commands, builtin.info)
+		}
+	}
+	s.mutex.RUnlock()
+
+	hooks, err := s.commandRepository.GetCommandHooks(groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, hook := range hooks {
+		// The group's own hook shadows a global one of the same name
+		if seen[hook.Name] || (hook.GroupID == "" && groupID != "" && hasGroupHook(hooks, hook.Name)) {
+			continue
+		}
+		seen[hook.Name] = true
+		commands = append(commands, models.CommandInfo{
+			Name:        hook.Name,
+			Usage:       hook.Usage,
+			Description: hook.Description,
+			HookID:      hook.ID,
+		})
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands, nil
+}
+
+func hasGroupHook(hooks []*models.CommandHook, name string) bool {
+	for _, hook := range hooks {
+		if hook.Name == name && hook.GroupID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *commandService) ListCommands(groupID, requester string) ([]models.CommandInfo, error) {
+	if requester == "" {
+		return nil, errors.New("unauthorized: requester is required")
+	}
+	if groupID != "" {
+		group, err := s.groupRepository.GetGroup(groupID)
+		if err != nil || group == nil {
+			return nil, errors.New("group not found")
+		}
+		if !group.IsMember(requester) && !configs.IsSystemAdmin(requester) {
+			return nil, errors.New("unauthorized: only members can list the group's commands")
+		}
+	}
+	return s.available(groupID)
+}
+
+// authorizeHook lets group moderators manage a group's hooks and system admins manage every hook
+func (s *commandService) authorizeHook(groupID, requester string) error {
+	if groupID == "" {
+		if !configs.IsSystemAdmin(requester) {
+			return errors.New("unauthorized: only system admins can manage global commands")
+		}
+		return nil
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can manage commands")
+	}
+	return nil
+}
+
+func (s *commandService) CreateHook(groupID, name, target, usage, description, requester string) (*models.CommandHook, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if !commandNamePattern.MatchString(name) {
+		return nil, errors.New("invalid command: name must be 1 to 32 lowercase letters, digits, '-' or '_'")
+	}
+	s.mutex.RLock()
+	_, reserved := s.commands[name]
+	s.mutex.RUnlock()
+	if reserved {
+		return nil, fmt.Errorf("invalid command: /%s is built in", name)
+	}
+	parsed, err := url.ParseRequestURI(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.New("invalid command: url must be an http(s) url")
+	}
+	if err := checkOutboundURL(parsed); err != nil {
+		return nil, fmt.Errorf("invalid command: %v", err)
+	}
+	if err := s.authorizeHook(groupID, requester); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.commandRepository.FindCommandHook(groupID, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.GroupID == groupID {
+		return nil, fmt.Errorf("command /%s already exists", name)
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	hook := &models.CommandHook{
+		ID:          uuid.New().String(),
+		Name:        name,
+		GroupID:     groupID,
+		URL:         target,
+		Usage:       usage,
+		Description: description,
+		Secret:      secret,
+		CreatedBy:   requester,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.commandRepository.CreateCommandHook(hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *commandService) DeleteHook(id, requester string) error {
+	hook, err := s.commandRepository.GetCommandHook(id)
+	if err != nil {
+		return err
+	}
+	if hook == nil {
+		return errors.New("command not found")
+	}
+	if err := s.authorizeHook(hook.GroupID, requester); err != nil {
+		return err
+	}
+	_, err = s.commandRepository.DeleteCommandHook(id)
+	return err
+}
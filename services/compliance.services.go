@@ -0,0 +1,310 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// Entries of an export archive. Messages are written first so the users and groups
+// they reference can be exported after them without buffering the messages.
+const (
+	exportMessagesEntry    = "messages.jsonl"
+	exportGroupsEntry      = "groups.jsonl"
+	exportUsersEntry       = "users.jsonl"
+	exportAttachmentsEntry = "attachments.json"
+	exportManifestEntry    = "manifest.json"
+)
+
+type complianceService struct {
+	userRepository    database.UserRepository
+	groupRepository   database.GroupRepository
+	messageRepository database.MessageRepository
+}
+
+type ComplianceService interface {
+	AuthorizeExport(filter models.ExportFilter, requester string) error
+	Export(w io.Writer, filter models.ExportFilter, requester string) error
+	AuthorizeAccountExport(username, requester string) error
+	ExportAccount(w io.Writer, username, requester string) error
+	Import(archive io.ReaderAt, size int64, requester string) (*models.ImportResult, error)
+}
+
+func NewComplianceService(userRepo database.UserRepository, groupRepo database.GroupRepository, messageRepo database.MessageRepository) ComplianceService {
+	return &complianceService{
+		userRepository:    userRepo,
+		groupRepository:   groupRepo,
+		messageRepository: messageRepo,
+	}
+}
+
+func (s *complianceService) AuthorizeExport(filter models.ExportFilter, requester string) error {
+	if !configs.IsSystemAdmin(requester) {
+		return errors.New("unauthorized: only system admins can export conversations")
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return errors.New("invalid export: from must be before to")
+	}
+	return nil
+}
+
+// Export streams a zip archive of JSON Lines files to w
+func (s *complianceService) Export(w io.Writer, filter models.ExportFilter, requester string) error {
+	if err := s.AuthorizeExport(filter, requester); err != nil {
+		return err
+	}
+	return s.writeArchive(w, filter, requester, false)
+}
+
+// AuthorizeAccountExport lets users export their own data, and system admins anyone's
+func (s *complianceService) AuthorizeAccountExport(username, requester string) error {
+	if requester != username && !configs.IsSystemAdmin(requester) {
+		return errors.New("unauthorized: users can only export their own data")
+	}
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// ExportAccount streams the same archive as Export for everything tied to username.
+// Only the subject's own profile is included, not those of the people they talked to.
+func (s *complianceService) ExportAccount(w io.Writer, username, requester string) error {
+	if err := s.AuthorizeAccountExport(username, requester); err != nil {
+		return err
+	}
+	return s.writeArchive(w, models.ExportFilter{Username: username}, requester, true)
+}
+
+// writeArchive writes the export archive. With subjectOnly the users entry is limited to
+// filter.Username instead of everyone the exported messages reference.
+func (s *complianceService) writeArchive(w io.Writer, filter models.ExportFilter, requester string, subjectOnly bool) error {
+	usernames := make(map[string]bool)
+	groupIDs := make(map[string]bool)
+	if filter.Username != "" {
+		usernames[filter.Username] = true
+		groups, err := s.userRepository.GetUserGroups(filter.Username)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			groupIDs[group.ID] = true
+		}
+	}
+	if filter.GroupID != "" {
+		groupIDs[filter.GroupID] = true
+	}
+
+	archive := zip.NewWriter(w)
+	counts := make(map[string]int)
+
+	entry, err := archive.Create(exportMessagesEntry)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	err = s.messageRepository.StreamMessages(filter, func(message *models.MessageDB) error {
+		if !subjectOnly {
+			usernames[message.Sender] = true
+			if message.Receiver != "" {
+				usernames[message.Receiver] = true
+			}
+		}
+		if message.GroupID != "" {
+			groupIDs[message.GroupID] = true
+		}
+		counts["messages"]++
+		return encoder.Encode(message)
+	})
+	if err != nil {
+		return err
+	}
+
+	entry, err = archive.Create(exportGroupsEntry)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(entry)
+	err = s.groupRepository.StreamGroups(sortedKeys(groupIDs), func(group *models.Group) error {
+		if filter.GroupID != "" && group.ID == filter.GroupID {
+			for _, member := range group.Members {
+				usernames[member] = true
+			}
+		}
+		counts["groups"]++
+		return encoder.Encode(models.ExportedGroup{Group: group, LegalHold: group.LegalHold, Mutes: group.Mutes, Bans: group.Bans})
+	})
+	if err != nil {
+		return err
+	}
+
+	entry, err = archive.Create(exportUsersEntry)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(entry)
+	err = s.userRepository.StreamUsers(sortedKeys(usernames), func(user *models.User) error {
+		counts["users"]++
+		return encoder.Encode(models.ExportedUser{User: user, LegalHold: user.LegalHold})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Messages carry no attachments yet, the manifest keeps the archive layout stable
+	entry, err = archive.Create(exportAttachmentsEntry)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(entry).Encode([]models.AttachmentEntry{}); err != nil {
+		return err
+	}
+	counts["attachments"] = 0
+
+	entry, err = archive.Create(exportManifestEntry)
+	if err != nil {
+		return err
+	}
+	manifest := models.ExportManifest{
+		Version:     models.ExportFormatVersion,
+		GeneratedAt: time.Now(),
+		GeneratedBy: requester,
+		Filter:      filter,
+		Counts:      counts,
+	}
+	if err := json.NewEncoder(entry).Encode(manifest); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	log.Printf("Export by %s wrote %d messages, %d groups and %d users", requester, counts["messages"], counts["groups"], counts["users"])
+	return nil
+}
+
+// Import restores an archive produced by Export. Records are upserted one at a time,
+// so importing the same archive twice is harmless.
+func (s *complianceService) Import(archive io.ReaderAt, size int64, requester string) (*models.ImportResult, error) {
+	if !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: only system admins can import conversations")
+	}
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, errors.New("invalid archive: not a zip file")
+	}
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+
+	manifestFile, exists := entries[exportManifestEntry]
+	if !exists {
+		return nil, errors.New("invalid archive: missing manifest")
+	}
+	var manifest models.ExportManifest
+	if err := decodeEntry(manifestFile, func(decoder *json.Decoder) error {
+		return decoder.Decode(&manifest)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+	if manifest.Version != models.ExportFormatVersion {
+		return nil, fmt.Errorf("invalid archive: unsupported version %d", manifest.Version)
+	}
+
+	result := &models.ImportResult{}
+	if file, exists := entries[exportUsersEntry]; exists {
+		err := decodeEntry(file, func(decoder *json.Decoder) error {
+			var user models.User
+			exported := models.ExportedUser{User: &user}
+			if err := decoder.Decode(&exported); err != nil {
+				return err
+			}
+			user.LegalHold = exported.LegalHold
+			if user.Username == "" {
+				return errors.New("user without username")
+			}
+			result.Users++
+			return s.userRepository.ImportUser(&user)
+		})
+		if err != nil {
+			return result, fmt.Errorf("invalid archive: %s: %v", exportUsersEntry, err)
+		}
+	}
+	if file, exists := entries[exportGroupsEntry]; exists {
+		err := decodeEntry(file, func(decoder *json.Decoder) error {
+			var group models.Group
+			exported := models.ExportedGroup{Group: &group}
+			if err := decoder.Decode(&exported); err != nil {
+				return err
+			}
+			group.LegalHold, group.Mutes, group.Bans = exported.LegalHold, exported.Mutes, exported.Bans
+			if group.ID == "" {
+				return errors.New("group without id")
+			}
+			result.Groups++
+			return s.groupRepository.ImportGroup(&group)
+		})
+		if err != nil {
+			return result, fmt.Errorf("invalid archive: %s: %v", exportGroupsEntry, err)
+		}
+	}
+	if file, exists := entries[exportMessagesEntry]; exists {
+		err := decodeEntry(file, func(decoder *json.Decoder) error {
+			var message models.MessageDB
+			if err := decoder.Decode(&message); err != nil {
+				return err
+			}
+			if message.ID == "" {
+				return errors.New("message without id")
+			}
+			result.Messages++
+			return s.messageRepository.ImportMessage(&message)
+		})
+		if err != nil {
+			return result, fmt.Errorf("invalid archive: %s: %v", exportMessagesEntry, err)
+		}
+	}
+	log.Printf("Import by %s restored %d messages, %d groups and %d users", requester, result.Messages, result.Groups, result.Users)
+	return result, nil
+}
+
+// decodeEntry calls decode once per JSON value in a zip entry
+func decodeEntry(file *zip.File, decode func(*json.Decoder) error) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	decoder := json.NewDecoder(rc)
+	for decoder.More() {
+		if err := decode(decoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// longPollQueueSize caps the frames waiting for a long-poll client to collect them
+	longPollQueueSize = 256
+	// maxPollWait caps how long a long-poll request is held open
+	maxPollWait = 30 * time.Second
+	// maxPollBatch caps the frames returned by one long-poll request
+	maxPollBatch = 100
+	// longPollExpiry ends a long-poll session that has not polled for this long
+	longPollExpiry = pongWait
+)
+
+var errConnectionClosed = errors.New("connection closed")
+
+// StreamConnection is a connection served by a long-lived HTTP request; Done is closed
+// once the hub is finished with it and the request may complete
+type StreamConnection interface {
+	models.Connection
+	Done() <-chan struct{}
+}
+
+// PollingConnection is a connection whose frames the client collects with requests of
+// its own. Poll waits up to wait for queued frames, giving up early when cancel is closed,
+// and reports false once the connection is closed and every queued frame was collected.
+type PollingConnection interface {
+	models.Connection
+	Poll(cancel <-chan struct{}, wait time.Duration) ([]json.RawMessage, bool)
+}
+
+// wsConnection carries a session over a gorilla WebSocket
+type wsConnection struct {
+	conn *websocket.Conn
+}
+
+func NewWebSocketConnection(conn *websocket.Conn) models.Connection {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	return &wsConnection{conn: conn}
+}
+
+func (c *wsConnection) Transport() string {
+	return models.TransportWebSocket
+}
+
+func (c *wsConnection) ReadFrame() ([]byte, error) {
+	_, frame, err := c.conn.ReadMessage()
+	if err != nil && !websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+		return nil, io.EOF
+	}
+	return frame, err
+}
+
+func (c *wsConnection) WriteFrame(frame []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+func (c *wsConnection) Ping() error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// Close sends a close frame and closes the socket. WriteControl may be used alongside
+// the write pump, unlike WriteMessage.
+func (c *wsConnection) Close(code int, reason string) error {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	return c.conn.Close()
+}
+
+// sseConnection writes frames as Server-Sent Events. Clients send frames with separate
+// POST requests, so there is nothing to read; ReadFrame only waits for the end.
+type sseConnection struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	done    chan struct{}
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// NewSSEConnection wraps the response of an event stream whose headers were already sent
+func NewSSEConnection(w http.ResponseWriter) (StreamConnection, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported by the response writer")
+	}
+	return &sseConnection{w: w, flusher: flusher, done: make(chan struct{})}, nil
+}
+
+func (c *sseConnection) Transport() string {
+	return models.TransportSSE
+}
+
+func (c *sseConnection) ReadFrame() ([]byte, error) {
+	<-c.done
+	return nil, io.EOF
+}
+
+func (c *sseConnection) WriteFrame(frame []byte) error {
+	return c.write("data: %s\n\n", frame)
+}
+
+// Ping writes a comment so proxies see traffic on an idle stream
+func (c *sseConnection) Ping() error {
+	return c.write(": keep-alive\n\n")
+}
+
+func (c *sseConnection) write(format string, args ...interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// The response must not be touched once the handler may have returned
+	if c.closed {
+		return errConnectionClosed
+	}
+	if _, err := fmt.Fprintf(c.w, format, args...); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+	return nil
+}
+
+func (c *sseConnection) Close(code int, reason string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+func (c *sseConnection) Done() <-chan struct{} {
+	return c.done
+}
+
+// longPollConnection queues frames until the client polls for them. Like SSE, clients
+// post their frames separately. A session that stops polling expires.
+type longPollConnection struct {
+	outbox   chan []byte
+	activity chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func NewLongPollConnection() PollingConnection {
+	return &longPollConnection{
+		outbox:   make(chan []byte, longPollQueueSize),
+		activity: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *longPollConnection) Transport() string {
+	return models.TransportLongPoll
+}
+
+func (c *longPollConnection) ReadFrame() ([]byte, error) {
+	expiry := time.NewTimer(longPollExpiry)
+	defer expiry.Stop()
+	for {
+		select {
+		case <-c.done:
+			return nil, io.EOF
+		case <-c.activity:
+			if !expiry.Stop() {
+				<-expiry.C
+			}
+			expiry.Reset(longPollExpiry)
+		case <-expiry.C:
+			return nil, errors.New("long-poll session expired")
+		}
+	}
+}
+
+// WriteFrame queues a frame. Frames written after Close are still queued so the last
+// poll can collect them.
+func (c *longPollConnection) WriteFrame(frame []byte) error {
+	select {
+	case c.outbox <- frame:
+		return nil
+	default:
+		return errors.New("long-poll queue is full")
+	}
+}
+
+func (c *longPollConnection) Ping() error {
+	return nil
+}
+
+func (c *longPollConnection) Close(code int, reason string) error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
+// Poll waits up to wait for queued frames, giving up early when cancel is closed. It
+// reports false once the connection is closed and every queued frame was collected.
+func (c *longPollConnection) Poll(cancel <-chan struct{}, wait time.Duration) ([]json.RawMessage, bool) {
+	c.touch()
+	defer c.touch()
+
+	frames := []json.RawMessage{}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case frame := <-c.outbox:
+		frames = append(frames, frame)
+	case <-c.done:
+	case <-timer.C:
+		return frames, true
+	case <-cancel:
+		return frames, true
+	}
+	for len(frames) < maxPollBatch {
+		select {
+		case frame := <-c.outbox:
+			frames = append(frames, frame)
+		default:
+			return frames, !c.isClosed() || len(frames) > 0
+		}
+	}
+	return frames, true
+}
+
+func (c *longPollConnection) touch() {
+	select {
+	case c.activity <- struct{}{}:
+	default:
+	}
+}
+
+func (c *longPollConnection) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
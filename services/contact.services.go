@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+type contactService struct {
+	contactRepository database.ContactRepository
+	userRepository    database.UserRepository
+	websocketService  WebsocketService
+}
+
+type ContactService interface {
+	ListContacts(username string) ([]*models.Contact, error)
+	RemoveContact(username, contact string) error
+	ListFriendRequests(username string) ([]*models.FriendRequest, error)
+	SendFriendRequest(from, to string) (*models.FriendRequest, error)
+	AcceptFriendRequest(requestID, username string) (*models.FriendRequest, error)
+	RejectFriendRequest(requestID, username string) (*models.FriendRequest, error)
+	ListBlockedUsers(username string) ([]*models.Block, error)
+	BlockUser(blocker, blocked string) error
+	UnblockUser(blocker, blocked string) error
+}
+
+func NewContactService(contactRepo database.ContactRepository, userRepo database.UserRepository, wsService WebsocketService) ContactService {
+	return &contactService{
+		contactRepository: contactRepo,
+		userRepository:    userRepo,
+		websocketService:  wsService,
+	}
+}
+
+func (s *contactService) ListContacts(username string) ([]*models.Contact, error) {
+	return s.contactRepository.GetContacts(username)
+}
+
+func (s *contactService) RemoveContact(username, contact string) error {
+	isContact, err := s.contactRepository.IsContact(username, contact)
+	if err != nil {
+		return err
+	}
+	if !isContact {
+		return errors.New("contact not found")
+	}
+	if err := s.contactRepository.RemoveContact(username, contact); err != nil {
+		return err
+	}
+	if err := s.contactRepository.RemoveContact(contact, username); err != nil {
+		return err
+	}
+	s.websocketService.InvalidateAudience(username, contact)
+	return nil
+}
+
+func (s *contactService) ListFriendRequests(username string) ([]*models.FriendRequest, error) {
+	return s.contactRepository.GetFriendRequests(username)
+}
+
+func (s *contactService) SendFriendRequest(from, to string) (*models.FriendRequest, error) {
+	if from == "" || to == "" {
+		return nil, errors.New("sender and receiver usernames are required")
+	}
+	if from == to {
+		return nil, errors.New("cannot send a friend request to yourself")
+	}
+	user, err := s.userRepository.GetUser(to)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	blocked, err := s.contactRepository.IsBlocked(to, from)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		// Do not reveal the block to the sender
+		return nil, errors.New("user not found")
+	}
+	isContact, err := s.contactRepository.IsContact(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if isContact {
+		return nil, errors.New("already a contact")
+	}
+
+	// A request crossing one in the opposite direction is treated as an acceptance
+	reverse, err := s.contactRepository.GetPendingFriendRequest(to, from)
+	if err != nil {
+		return nil, err
+	}
+	if reverse != nil {
+		return s.AcceptFriendRequest(reverse.ID, from)
+	}
+	existing, err := s.contactRepository.GetPendingFriendRequest(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	request, err := s.contactRepository.CreateFriendRequest(&models.FriendRequest{
+		ID:     uuid.New().String(),
+		From:   from,
+		To:     to,
+		Status: models.FriendRequestPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.websocketService.SendToUser(to, models.Message{
+		Type:   "friend_request",
+		Sender: from,
+		Data:   request,
+	})
+	return request, nil
+}
+
+func (s *contactService) AcceptFriendRequest(requestID, username string) (*models.FriendRequest, error) {
+	request, err := s.respond(requestID, username, models.FriendRequestAccepted)
+	if err != nil {
+		return nil, err
+	}
+	for _, contact := range []*models.Contact{
+		{Username: request.From, Contact: request.To},
+		{Username: request.To, Contact: request.From},
+	} {
+		if err := s.contactRepository.AddContact(contact); err != nil {
+			return nil, err
+		}
+	}
+	s.websocketService.InvalidateAudience(request.From, request.To)
+	s.websocketService.SendToUser(request.From, models.Message{
+		Type:   "friend_request_accepted",
+		Sender: request.To,
+		Data:   request,
+	})
+	return request, nil
+}
+
+func (s *contactService) RejectFriendRequest(requestID, username string) (*models.FriendRequest, error) {
+	request, err := s.respond(requestID, username, models.FriendRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+	s.websocketService.SendToUser(request.From, models.Message{
+		Type:   "friend_request_rejected",
+		Sender: request.To,
+		Data:   request,
+	})
+	return request, nil
+}
+
+func (s *contactService) respond(requestID, username, status string) (*models.FriendRequest, error) {
+	request, err := s.contactRepository.GetFriendRequest(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, errors.New("friend request not found")
+	}
+	if request.To != username {
+		return nil, errors.New("unauthorized: only the receiver can respond to a friend request")
+	}
+	if request.Status != models.FriendRequestPending {
+		return nil, errors.New("friend request already answered")
+	}
+	now := time.Now()
+	request.Status = status
+	request.RespondedAt = &now
+	if err := s.contactRepository.UpdateFriendRequest(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (s *contactService) ListBlockedUsers(username string) ([]*models.Block, error) {
+	return s.contactRepository.GetBlockedUsers(username)
+}
+
+func (s *contactService) BlockUser(blocker, blocked string) error {
+	if blocker == "" || blocked == "" {
+		return errors.New("blocker and blocked usernames are required")
+	}
+	if blocker == blocked {
+		return errors.New("cannot block yourself")
+	}
+	user, err := s.userRepository.GetUser(blocked)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	if err := s.contactRepository.BlockUser(&models.Block{Blocker: blocker, Blocked: blocked}); err != nil {
+		return err
+	}
+
+	// Blocking ends the friendship and any pending request between the two users
+	if err := s.contactRepository.RemoveContact(blocker, blocked); err != nil {
+		return err
+	}
+	if err := s.contactRepository.RemoveContact(blocked, blocker); err != nil {
+		return err
+	}
+	for _, pair := range [][2]string{{blocker, blocked}, {blocked, blocker}} {
+		request, err := s.contactRepository.GetPendingFriendRequest(pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+		if request == nil {
+			continue
+		}
+		now := time.Now()
+		request.Status = models.FriendRequestRejected
+		request.RespondedAt = &now
+		if err := s.contactRepository.UpdateFriendRequest(request); err != nil {
+			return err
+		}
+	}
+	s.websocketService.InvalidateAudience(blocker, blocked)
+	return nil
+}
+
+func (s *contactService) UnblockUser(blocker, blocked string) error {
+	isBlocked, err := s.contactRepository.IsBlocked(blocker, blocked)
+	if err != nil {
+		return err
+	}
+	if !isBlocked {
+		return errors.New("user is not blocked")
+	}
+	if err := s.contactRepository.UnblockUser(blocker, blocked); err != nil {
+		return err
+	}
+	s.websocketService.InvalidateAudience(blocker, blocked)
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+type conversationService struct {
+	userRepository       database.UserRepository
+	messageRepository    database.MessageRepository
+	readCursorRepository database.ReadCursorRepository
+	settingsRepository   database.ConversationSettingsRepository
+	websocketService     WebsocketService
+}
+
+type ConversationService interface {
+	ListConversations(username string) ([]*models.Conversation, error)
+	MarkRead(username, conversationType, id string) (*models.ReadCursor, error)
+	SetDirectMessageTTL(username, partner string, ttl int64) (*models.DirectConversationSettings, error)
+	ListMentions(username string, before *time.Time, limit int64) ([]*models.MessageDB, error)
+}
+
+func NewConversationService(userRepo database.UserRepository, messageRepo database.MessageRepository, readCursorRepo database.ReadCursorRepository, settingsRepo database.ConversationSettingsRepository, wsService WebsocketService) ConversationService {
+	return &conversationService{
+		userRepository:       userRepo,
+		messageRepository:    messageRepo,
+		readCursorRepository: readCursorRepo,
+		settingsRepository:   settingsRepo,
+		websocketService:     wsService,
+	}
+}
+
+// ListConversations returns every group and direct thread of a user, most recent activity first
+func (s *conversationService) ListConversations(username string) ([]*models.Conversation, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	cursors, err := s.readCursorRepository.GetReadCursors(username)
+	if err != nil {
+		return nil, err
+	}
+	lastRead := make(map[string]time.Time, len(cursors))
+	for _, cursor := range cursors {
+		lastRead[cursor.ConversationID] = cursor.LastReadAt
+	}
+
+	conversations := []*models.Conversation{}
+
+	groups, err := s.userRepository.GetUserGroups(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		conversation := &models.Conversation{
+			Type:         models.ConversationGroup,
+			ID:           group.ID,
+			Name:         group.Name,
+			LastActivity: group.CreatedAt,
+		}
+		conversation.LastMessage, err = s.messageRepository.GetLastGroupMessage(group.ID)
+		if err != nil {
+			return nil, err
+		}
+		if conversation.LastMessage != nil {
+			conversation.LastActivity = conversation.LastMessage.Timestamp
+			since := lastRead[models.ConversationKey(models.ConversationGroup, group.ID)]
+			conversation.UnreadCount, err = s.messageRepository.CountGroupMessagesSince(group.ID, username, since)
+			if err != nil {
+				return nil, err
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	partners, err := s.messageRepository.GetDirectMessagePartners(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, partner := range partners {
+		conversation := &models.Conversation{
+			Type: models.ConversationDirect,
+			ID:   partner,
+			Name: partner,
+		}
+		if user, err := s.userRepository.GetUser(partner); err == nil && user != nil && user.DisplayName != "" {
+			conversation.Name = user.DisplayName
+		}
+		conversation.LastMessage, err = s.messageRepository.GetLastDirectMessage(username, partner)
+		if err != nil {
+			return nil, err
+		}
+		if conversation.LastMessage != nil {
+			conversation.LastActivity = conversation.LastMessage.Timestamp
+			since := lastRead[models.ConversationKey(models.ConversationDirect, partner)]
+			conversation.UnreadCount, err = s.messageRepository.CountDirectMessagesSince(partner, username, since)
+			if err != nil {
+				return nil, err
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].LastActivity.After(conversations[j].LastActivity)
+	})
+	return conversations, nil
+}
+
+// MarkRead moves the read cursor of a conversation to now and syncs the user's other devices
+func (s *conversationService) MarkRead(username, conversationType, id string) (*models.ReadCursor, error) {
+	if username == "" || id == "" {
+		return nil, errors.New("username and conversation are required")
+	}
+	if conversationType != models.ConversationGroup && conversationType != models.ConversationDirect {
+		return nil, errors.New("invalid conversation type")
+	}
+	cursor := &models.ReadCursor{
+		Username:       username,
+		ConversationID: models.ConversationKey(conversationType, id),
+		LastReadAt:     time.Now(),
+	}
+	if err := s.readCursorRepository.SetReadCursor(cursor); err != nil {
+		return nil, err
+	}
+	s.websocketService.SendToUser(username, models.Message{
+		Type:   "read",
+		Sender: username,
+		Data:   cursor,
+	})
+	return cursor, nil
+}
+
+// SetDirectMessageTTL changes the disappearing message timer shared by both participants
+func (s *conversationService) SetDirectMessageTTL(username, partner string, ttl int64) (*models.DirectConversationSettings, error) {
+	if username == "" || partner == "" {
+		return nil, errors.New("username and conversation are required")
+	}
+	if err := validateMessageTTL(ttl); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepository.GetUser(partner)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	settings := &models.DirectConversationSettings{
+		Key:          models.DirectPairKey(username, partner),
+		Participants: []string{username, partner},
+		MessageTTL:   ttl,
+	}
+	if err := s.settingsRepository.SaveDirectSettings(settings); err != nil {
+		return nil, err
+	}
+	for _, participant := range []string{username, partner} {
+		s.websocketService.SendToUser(participant, models.Message{
+			Type:     "conversation_update",
+			Sender:   username,
+			Receiver: partner,
+			Data:     settings,
+		})
+	}
+	return settings, nil
+}
+
+const (
+	defaultMentionLimit = 50
+	maxMentionLimit     = 200
+)
+
+// ListMentions returns the messages mentioning a user, newest first. Mentions in groups
+// the user has since left are not listed.
+func (s *conversationService) ListMentions(username string, before *time.Time, limit int64) ([]*models.MessageDB, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if limit < 0 || limit > maxMentionLimit {
+		return nil, errors.New("invalid mentions query: limit must be between 1 and 200")
+	}
+	if limit == 0 {
+		limit = defaultMentionLimit
+	}
+	groups, err := s.userRepository.GetUserGroups(username)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+	return s.messageRepository.GetMentions(username, groupIDs, before, limit)
+}
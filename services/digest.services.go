@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+const (
+	// digestMessagesPerConversation caps the messages quoted for one conversation
+	digestMessagesPerConversation = 5
+	// digestMentionScanLimit caps the messages searched for mentions in a mentions-only group
+	digestMentionScanLimit = 200
+	maxDigestLineLength    = 200
+)
+
+type digestService struct {
+	settingsRepository     database.DigestSettingsRepository
+	userRepository         database.UserRepository
+	messageRepository      database.MessageRepository
+	readCursorRepository   database.ReadCursorRepository
+	notificationRepository database.NotificationSettingsRepository
+	contactRepository      database.ContactRepository
+	websocketService       WebsocketService
+	transport              MailTransport
+	from                   string
+	config                 configs.DigestConfig
+}
+
+type DigestService interface {
+	GetSettings(username string) (*models.DigestSettings, error)
+	UpdateSettings(username string, update models.DigestSettingsUpdate) (*models.DigestSettings, error)
+	Preview(username string) (*models.Digest, error)
+	Run(ctx context.Context)
+}
+
+func NewDigestService(settingsRepo database.DigestSettingsRepository, userRepo database.UserRepository, messageRepo database.MessageRepository, readCursorRepo database.ReadCursorRepository, notificationRepo database.NotificationSettingsRepository, contactRepo database.ContactRepository, wsService WebsocketService, transport MailTransport, mailConfig configs.MailConfig, cfg configs.DigestConfig) DigestService {
+	return &digestService{
+		settingsRepository:     settingsRepo,
+		userRepository:         userRepo,
+		messageRepository:      messageRepo,
+		readCursorRepository:   readCursorRepo,
+		notificationRepository: notificationRepo,
+		contactRepository:      contactRepo,
+		websocketService:       wsService,
+		transport:              transport,
+		from:                   mailConfig.From,
+		config:                 cfg,
+	}
+}
+
+// GetSettings returns the user's digest settings, or the defaults when they never set them
+func (s *digestService) GetSettings(username string) (*models.DigestSettings, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	settings, err := s.settingsRepository.GetDigestSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		user, err := s.userRepository.GetUser(username)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("user not found")
+		}
+		settings = &models.DigestSettings{Username: username, Frequency: models.DigestDaily}
+	}
+	return settings, nil
+}
+
+func (s *digestService) UpdateSettings(username string, update models.DigestSettingsUpdate) (*models.DigestSettings, error) {
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if email != "" {
+			address, err := mail.ParseAddress(email)
+			if err != nil || address.Address != email {
+				return nil, errors.New("invalid digest settings: email is not a valid address")
+			}
+		}
+		settings.Email = email
+	}
+	if update.Frequency != nil {
+		if _, ok := models.DigestPeriod(*update.Frequency); !ok {
+			return nil, errors.New("invalid digest settings: frequency must be hourly, daily or weekly")
+		}
+		settings.Frequency = *update.Frequency
+	}
+	if update.Enabled != nil {
+		if *update.Enabled && settings.Email == "" {
+			return nil, errors.New("invalid digest settings: an email is required to enable digests")
+		}
+		if *update.Enabled && !settings.Enabled {
+			// Start counting from now so the first digest does not dig up old history
+			now := time.Now()
+			settings.LastSentAt = &now
+		}
+		settings.Enabled = *update.Enabled
+	}
+	if settings.Enabled && settings.Email == "" {
+		return nil, errors.New("invalid digest settings: an email is required to enable digests")
+	}
+	settings.UpdatedAt = time.Now()
+	if err := s.settingsRepository.SaveDigestSettings(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Preview compiles the digest the user would get now, without sending it or
+// checking whether they are offline
+func (s *digestService) Preview(username string) (*models.Digest, error) {
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return s.compile(user, digestSince(settings, user))
+}
+
+func (s *digestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *digestService) sendDue() {
+	if s.transport == nil {
+		return
+	}
+	all, err := s.settingsRepository.GetEnabledDigestSettings()
+	if err != nil {
+		log.Printf("Failed to load digest settings: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, settings := range all {
+		if err := s.sendDigest(settings, now); err != nil {
+			log.Printf("Failed to send digest to %s: %v", settings.Username, err)
+		}
+	}
+}
+
+// sendDigest mails the user their unread messages when their frequency allows it and they
+// have been offline for longer than the threshold
+func (s *digestService) sendDigest(settings *models.DigestSettings, now time.Time) error {
+	period, ok := models.DigestPeriod(settings.Frequency)
+	if !ok || settings.Email == "" {
+		return nil
+	}
+	if settings.LastSentAt != nil && now.Sub(*settings.LastSentAt) < period {
+		return nil
+	}
+	if s.websocketService.IsConnected(settings.Username) {
+		return nil
+	}
+	user, err := s.userRepository.GetUser(settings.Username)
+	if err != nil || user == nil {
+		return err
+	}
+	if user.LastSeen != nil && now.Sub(*user.LastSeen) < s.config.OfflineThreshold {
+		return nil
+	}
+
+	digest, err := s.compile(user, digestSince(settings, user))
+	if err != nil {
+		return err
+	}
+	if len(digest.Conversations) == 0 {
+		return nil
+	}
+	claimed, err := s.settingsRepository.ClaimDigest(settings.Username, settings.LastSentAt, now)
+	if err != nil || !claimed {
+		return err
+	}
+	message := &models.MailMessage{
+		From:    s.from,
+		To:      settings.Email,
+		Subject: digestSubject(digest),
+		Body:    renderDigest(user, digest),
+	}
+	if err := s.transport.Send(message); err != nil {
+		if resetErr := s.settingsRepository.ResetDigest(settings.Username, settings.LastSentAt); resetErr != nil {
+			log.Printf("Failed to reset digest of %s: %v", settings.Username, resetErr)
+		}
+		return err
+	}
+	log.Printf("Sent digest of %d messages to %s", digest.MessageCount(), settings.Username)
+	return nil
+}
+
+// digestSince is where a digest starts: after the last one, and never before the user was
+// last online since they saw anything older then
+func digestSince(settings *models.DigestSettings, user *models.User) time.Time {
+	var since time.Time
+	if settings.LastSentAt != nil {
+		since = *settings.LastSentAt
+	}
+	if user.LastSeen != nil && user.LastSeen.After(since) {
+		since = *user.LastSeen
+	}
+	return since
+}
+
+// compile collects the messages the user has not read since since, skipping muted
+// conversations and, in mentions-only ones, messages that do not mention them
+func (s *digestService) compile(user *models.User, since time.Time) (*models.Digest, error) {
+	username := user.Username
+	digest := &models.Digest{Username: username, Since: since, Conversations: []*models.DigestConversation{}}
+
+	cursors, err := s.readCursorRepository.GetReadCursors(username)
+	if err != nil {
+		return nil, err
+	}
+	lastRead := make(map[string]time.Time, len(cursors))
+	for _, cursor := range cursors {
+		lastRead[cursor.ConversationID] = cursor.LastReadAt
+	}
+	unreadSince := func(key string) time.Time {
+		if read := lastRead[key]; read.After(since) {
+			return read
+		}
+		return since
+	}
+
+	notifications, err := s.notificationRepository.GetNotificationSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	level := func(key string) string {
+		if notifications == nil {
+			return models.NotifyAll
+		}
+		return notifications.Level(key, time.Now())
+	}
+
+	blocks, err := s.contactRepository.GetBlockedUsers(username)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		blocked[block.Blocked] = true
+	}
+
+	groups, err := s.userRepository.GetUserGroups(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		key := models.ConversationKey(models.ConversationGroup, group.ID)
+		conversation := &models.DigestConversation{Type: models.ConversationGroup, ID: group.ID, Name: group.Name}
+		switch level(key) {
+		case models.NotifyNone:
+			continue
+		case models.NotifyMentions:
+			messages, err := s.messageRepository.GetGroupMessagesSince(group.ID, username, unreadSince(key), digestMentionScanLimit)
+			if err != nil {
+				return nil, err
+			}
+			for _, message := range messages {
+				if !blocked[message.Sender] && mentionsUser(message, username) {
+					conversation.Messages = append(conversation.Messages, message)
+				}
+			}
+			conversation.Total = int64(len(conversation.Messages))
+			if len(conversation.Messages) > digestMessagesPerConversation {
+				conversation.Messages = conversation.Messages[:digestMessagesPerConversation]
+			}
+		default:
+			messages, err := s.messageRepository.GetGroupMessagesSince(group.ID, username, unreadSince(key), digestMessagesPerConversation)
+			if err != nil {
+				return nil, err
+			}
+			for _, message := range messages {
+				if !blocked[message.Sender] {
+					conversation.Messages = append(conversation.Messages, message)
+				}
+			}
+			if len(messages) > 0 {
+				conversation.Total, err = s.messageRepository.CountGroupMessagesSince(group.ID, username, unreadSince(key))
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(conversation.Messages) > 0 {
+			digest.Conversations = append(digest.Conversations, conversation)
+		}
+	}
+
+	partners, err := s.messageRepository.GetDirectMessagePartners(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, partner := range partners {
+		key := models.ConversationKey(models.ConversationDirect, partner)
+		if blocked[partner] || level(key) == models.NotifyNone {
+			continue
+		}
+		messages, err := s.messageRepository.GetDirectMessagesSince(partner, username, unreadSince(key), digestMessagesPerConversation)
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		conversation := &models.DigestConversation{Type: models.ConversationDirect, ID: partner, Name: partner, Messages: messages}
+		if sender, err := s.userRepository.GetUser(partner); err == nil && sender != nil && sender.DisplayName != "" {
+			conversation.Name = sender.DisplayName
+		}
+		conversation.Total, err = s.messageRepository.CountDirectMessagesSince(partner, username, unreadSince(key))
+		if err != nil {
+			return nil, err
+		}
+		digest.Conversations = append(digest.Conversations, conversation)
+	}
+	return digest, nil
+}
+
+func mentionsUser(message *models.MessageDB, username string) bool {
+	if message.Mentions == nil {
+		return false
+	}
+	for _, notified := range message.Mentions.Notified {
+		if notified == username {
+			return true
+		}
+	}
+	return false
+}
+
+func digestSubject(digest *models.Digest) string {
+	count := digest.MessageCount()
+	if count == 1 {
+		return "You have 1 unread message"
+	}
+	return fmt.Sprintf("You have %d unread messages", count)
+}
+
+// renderDigest formats the digest as plain text, with times in the user's time zone
+func renderDigest(user *models.User, digest *models.Digest) string {
+	location := time.UTC
+	if user.TimeZone != "" {
+		if loaded, err := time.LoadLocation(user.TimeZone); err == nil {
+			location = loaded
+		}
+	}
+	name := user.DisplayName
+	if name == "" {
+		name = user.Username
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n", name)
+	fmt.Fprintf(&b, "Here is what you missed while you were away.\n")
+	for _, conversation := range digest.Conversations {
+		title := conversation.Name
+		if conversation.Type == models.ConversationDirect {
+			title = "Direct messages from " + conversation.Name
+		}
+		fmt.Fprintf(&b, "\n%s (%d unread)\n", title, conversation.Total)
+		for _, message := range conversation.Messages {
+			fmt.Fprintf(&b, "  [%s] %s: %s\n",
+				message.Timestamp.In(location).Format("Jan 2 15:04"),
+				message.Sender,
+				truncate(strings.Join(strings.Fields(message.Content), " "), maxDigestLineLength),
+			)
+		}
+		if more := conversation.Total - int64(len(conversation.Messages)); more > 0 {
+			fmt.Fprintf(&b, "  ...and %d more\n", more)
+		}
+	}
+	b.WriteString("\n-- \nYou are receiving this because e-mail digests are turned on for your account.\n")
+	return b.String()
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// capturingMailTransport records every message instead of sending it
+type capturingMailTransport struct {
+	sent []*models.MailMessage
+	err  error
+}
+
+func (t *capturingMailTransport) Send(message *models.MailMessage) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.sent = append(t.sent, message)
+	return nil
+}
+
+type fakeDigestSettingsRepository struct {
+	database.DigestSettingsRepository
+	settings []*models.DigestSettings
+	claimed  []string
+	reset    []string
+}
+
+func (r *fakeDigestSettingsRepository) GetDigestSettings(username string) (*models.DigestSettings, error) {
+	for _, settings := range r.settings {
+		if settings.Username == username {
+			return settings, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeDigestSettingsRepository) GetEnabledDigestSettings() ([]*models.DigestSettings, error) {
+	return r.settings, nil
+}
+
+func (r *fakeDigestSettingsRepository) ClaimDigest(username string, previous *time.Time, sentAt time.Time) (bool, error) {
+	r.claimed = append(r.claimed, username)
+	return true, nil
+}
+
+func (r *fakeDigestSettingsRepository) ResetDigest(username string, previous *time.Time) error {
+	r.reset = append(r.reset, username)
+	return nil
+}
+
+type fakeDigestUserRepository struct {
+	database.UserRepository
+	users  map[string]*models.User
+	groups map[string][]*models.Group
+}
+
+func (r *fakeDigestUserRepository) GetUser(username string) (*models.User, error) {
+	return r.users[username], nil
+}
+
+func (r *fakeDigestUserRepository) GetUserGroups(username string) ([]*models.Group, error) {
+	return r.groups[username], nil
+}
+
+// fakeDigestMessageRepository answers the unread message queries from a slice, oldest first
+type fakeDigestMessageRepository struct {
+	database.MessageRepository
+	messages []*models.MessageDB
+}
+
+func (r *fakeDigestMessageRepository) matching(match func(*models.MessageDB) bool, since time.Time) []*models.MessageDB {
+	var found []*models.MessageDB
+	for _, message := range r.messages {
+		if message.Timestamp.After(since) && match(message) {
+			found = append(found, message)
+		}
+	}
+	return found
+}
+
+func inGroup(groupID, excludeSender string) func(*models.MessageDB) bool {
+	return func(message *models.MessageDB) bool {
+		return message.GroupID == groupID && message.Sender != excludeSender
+	}
+}
+
+func directFrom(sender, receiver string) func(*models.MessageDB) bool {
+	return func(message *models.MessageDB) bool {
+		return message.GroupID == "" && message.Sender == sender && message.Receiver == receiver
+	}
+}
+
+func limited(messages []*models.MessageDB, limit int64) []*models.MessageDB {
+	if int64(len(messages)) > limit {
+		return messages[:limit]
+	}
+	return messages
+}
+
+func (r *fakeDigestMessageRepository) GetGroupMessagesSince(groupID, excludeSender string, since time.Time, limit int64) ([]*models.MessageDB, error) {
+	return limited(r.matching(inGroup(groupID, excludeSender), since), limit), nil
+}
+
+func (r *fakeDigestMessageRepository) CountGroupMessagesSince(groupID, excludeSender string, since time.Time) (int64, error) {
+	return int64(len(r.matching(inGroup(groupID, excludeSender), since))), nil
+}
+
+func (r *fakeDigestMessageRepository) GetDirectMessagesSince(sender, receiver string, since time.Time, limit int64) ([]*models.MessageDB, error) {
+	return limited(r.matching(directFrom(sender, receiver), since), limit), nil
+}
+
+func (r *fakeDigestMessageRepository) CountDirectMessagesSince(sender, receiver string, since time.Time) (int64, error) {
+	return int64(len(r.matching(directFrom(sender, receiver), since))), nil
+}
+
+func (r *fakeDigestMessageRepository) GetDirectMessagePartners(username string) ([]string, error) {
+	partners := []string{}
+	seen := map[string]bool{}
+	for _, message := range r.messages {
+		if message.GroupID == "" && message.Receiver == username && !seen[message.Sender] {
+			seen[message.Sender] = true
+			partners = append(partners, message.Sender)
+		}
+	}
+	return partners, nil
+}
+
+type fakeReadCursorRepository struct {
+	database.ReadCursorRepository
+	cursors []*models.ReadCursor
+}
+
+func (r *fakeReadCursorRepository) GetReadCursors(username string) ([]*models.ReadCursor, error) {
+	return r.cursors, nil
+}
+
+type fakeNotificationSettingsRepository struct {
+	database.NotificationSettingsRepository
+	settings *models.NotificationSettings
+}
+
+func (r *fakeNotificationSettingsRepository) GetNotificationSettings(username string) (*models.NotificationSettings, error) {
+	return r.settings, nil
+}
+
+type fakeContactRepository struct {
+	database.ContactRepository
+	blocks []*models.Block
+}
+
+func (r *fakeContactRepository) GetBlockedUsers(blocker string) ([]*models.Block, error) {
+	return r.blocks, nil
+}
+
+// fakePresence reports the users in connected as online
+type fakePresence struct {
+	WebsocketService
+	connected map[string]bool
+}
+
+func (p *fakePresence) IsConnected(username string) bool {
+	return p.connected[username]
+}
+
+// digestFixture is alice, offline for two hours, with unread messages in every kind of
+// conversation the digest treats differently
+type digestFixture struct {
+	settings  *fakeDigestSettingsRepository
+	users     *fakeDigestUserRepository
+	messages  *fakeDigestMessageRepository
+	presence  *fakePresence
+	transport *capturingMailTransport
+	service   *digestService
+}
+
+func newDigestFixture(now time.Time) *digestFixture {
+	lastSeen := now.Add(-2 * time.Hour)
+	at := func(minutes int) time.Time {
+		return lastSeen.Add(time.Duration(minutes) * time.Minute)
+	}
+	f := &digestFixture{
+		settings: &fakeDigestSettingsRepository{settings: []*models.DigestSettings{{
+			Username: "alice", Email: "alice@example.com", Enabled: true, Frequency: models.DigestDaily,
+		}}},
+		users: &fakeDigestUserRepository{
+			users: map[string]*models.User{
+				"alice": {Username: "alice", DisplayName: "Alice", LastSeen: &lastSeen},
+				"carol": {Username: "carol", DisplayName: "Carol C"},
+			},
+			groups: map[string][]*models.Group{"alice": {
+				{ID: "team", Name: "Team"},
+				{ID: "muted", Name: "Muted"},
+				{ID: "busy", Name: "Busy"},
+			}},
+		},
+		messages: &fakeDigestMessageRepository{messages: []*models.MessageDB{
+			// Seen before alice went offline
+			{ID: "m0", Sender: "bob", GroupID: "team", Content: "old news", Timestamp: at(-10)},
+			// Read on another device before the read cursor
+			{ID: "m1", Sender: "bob", GroupID: "team", Content: "already read", Timestamp: at(1)},
+			{ID: "m2", Sender: "bob", GroupID: "team", Content: "standup   moved\nto ten", Timestamp: at(3)},
+			{ID: "m3", Sender: "alice", GroupID: "team", Content: "her own message", Timestamp: at(4)},
+			{ID: "m4", Sender: "dave", GroupID: "busy", Content: "@alice from a blocked user", Timestamp: at(5),
+				Mentions: &models.Mentions{Users: []string{"alice"}, Notified: []string{"alice"}}},
+			{ID: "m5", Sender: "bob", GroupID: "muted", Content: "in a muted group", Timestamp: at(6)},
+			{ID: "m6", Sender: "bob", GroupID: "busy", Content: "chatter", Timestamp: at(7)},
+			{ID: "m7", Sender: "bob", GroupID: "busy", Content: "@alice please review", Timestamp: at(8),
+				Mentions: &models.Mentions{Users: []string{"alice"}, Notified: []string{"alice"}}},
+			{ID: "m8", Sender: "carol", Receiver: "alice", Content: "lunch?", Timestamp: at(9)},
+			{ID: "m9", Sender: "dave", Receiver: "alice", Content: "blocked direct message", Timestamp: at(10)},
+		}},
+		presence:  &fakePresence{connected: map[string]bool{}},
+		transport: &capturingMailTransport{},
+	}
+	f.service = &digestService{
+		settingsRepository: f.settings,
+		userRepository:     f.users,
+		messageRepository:  f.messages,
+		readCursorRepository: &fakeReadCursorRepository{cursors: []*models.ReadCursor{
+			{Username: "alice", ConversationID: models.ConversationKey(models.ConversationGroup, "team"), LastReadAt: at(2)},
+		}},
+		notificationRepository: &fakeNotificationSettingsRepository{settings: &models.NotificationSettings{
+			Username: "alice",
+			Conversations: []models.ConversationNotification{
+				{Conversation: models.ConversationKey(models.ConversationGroup, "muted"), Level: models.NotifyNone},
+				{Conversation: models.ConversationKey(models.ConversationGroup, "busy"), Level: models.NotifyMentions},
+			},
+		}},
+		contactRepository: &fakeContactRepository{blocks: []*models.Block{{Blocker: "alice", Blocked: "dave"}}},
+		websocketService:  f.presence,
+		transport:         f.transport,
+		from:              "digest@example.com",
+		config:            configs.DigestConfig{OfflineThreshold: time.Hour, Interval: time.Minute},
+	}
+	return f
+}
+
+func TestDigestIsMailedToOfflineUsers(t *testing.T) {
+	f := newDigestFixture(time.Now())
+
+	f.service.sendDue()
+
+	if len(f.transport.sent) != 1 {
+		t.Fatalf("sent %d digests, want 1", len(f.transport.sent))
+	}
+	mail := f.transport.sent[0]
+	if mail.From != "digest@example.com" || mail.To != "alice@example.com" {
+		t.Errorf("mail from %s to %s", mail.From, mail.To)
+	}
+	// m2 is unread in team, m7 in busy and m8 in the direct conversation
+	if mail.Subject != "You have 3 unread messages" {
+		t.Errorf("subject = %q", mail.Subject)
+	}
+	for _, want := range []string{
+		"Hi Alice,",
+		"Team (1 unread)",
+		"bob: standup moved to ten",
+		"Busy (1 unread)",
+		"bob: @alice please review",
+		"Direct messages from Carol C (1 unread)",
+		"carol: lunch?",
+	} {
+		if !strings.Contains(mail.Body, want) {
+			t.Errorf("digest does not contain %q:\n%s", want, mail.Body)
+		}
+	}
+	for _, unwanted := range []string{"old news", "already read", "her own message", "blocked", "muted group", "chatter"} {
+		if strings.Contains(mail.Body, unwanted) {
+			t.Errorf("digest contains %q:\n%s", unwanted, mail.Body)
+		}
+	}
+	if len(f.settings.claimed) != 1 || len(f.settings.reset) != 0 {
+		t.Errorf("claimed %v and reset %v, want one claim", f.settings.claimed, f.settings.reset)
+	}
+}
+
+func TestDigestListsHowManyMoreAreUnread(t *testing.T) {
+	now := time.Now()
+	f := newDigestFixture(now)
+	for i := 0; i < digestMessagesPerConversation+2; i++ {
+		f.messages.messages = append(f.messages.messages, &models.MessageDB{
+			ID: fmt.Sprintf("extra-%d", i), Sender: "erin", Receiver: "alice", Content: "ping", Timestamp: now.Add(-time.Minute),
+		})
+	}
+
+	f.service.sendDue()
+
+	if len(f.transport.sent) != 1 {
+		t.Fatalf("sent %d digests, want 1", len(f.transport.sent))
+	}
+	body := f.transport.sent[0].Body
+	if !strings.Contains(body, "Direct messages from erin (7 unread)") || !strings.Contains(body, "...and 2 more") {
+		t.Errorf("digest does not cap erin's messages:\n%s", body)
+	}
+	if got := strings.Count(body, "erin: ping"); got != digestMessagesPerConversation {
+		t.Errorf("quoted %d of erin's messages, want %d", got, digestMessagesPerConversation)
+	}
+}
+
+func TestDigestIsNotSentWhenNotDue(t *testing.T) {
+	now := time.Now()
+	recently := now.Add(-10 * time.Minute)
+	sentRecently := now.Add(-30 * time.Minute)
+	cases := map[string]func(f *digestFixture){
+		"connected":      func(f *digestFixture) { f.presence.connected["alice"] = true },
+		"seen recently":  func(f *digestFixture) { f.users.users["alice"].LastSeen = &recently },
+		"sent recently":  func(f *digestFixture) { f.settings.settings[0].LastSentAt = &sentRecently },
+		"nothing unread": func(f *digestFixture) { f.messages.messages = nil },
+		"no email":       func(f *digestFixture) { f.settings.settings[0].Email = "" },
+	}
+	for name, prepare := range cases {
+		f := newDigestFixture(now)
+		prepare(f)
+
+		f.service.sendDue()
+
+		if len(f.transport.sent) != 0 || len(f.settings.claimed) != 0 {
+			t.Errorf("%s: sent %d digests and claimed %v, want none", name, len(f.transport.sent), f.settings.claimed)
+		}
+	}
+}
+
+func TestDigestIsReleasedWhenSendingFails(t *testing.T) {
+	f := newDigestFixture(time.Now())
+	f.transport.err = errors.New("smtp server unavailable")
+
+	f.service.sendDue()
+
+	if len(f.settings.claimed) != 1 || len(f.settings.reset) != 1 {
+		t.Errorf("claimed %v and reset %v, want the claim released", f.settings.claimed, f.settings.reset)
+	}
+}
+
+func TestDigestPreviewDoesNotSend(t *testing.T) {
+	f := newDigestFixture(time.Now())
+	f.presence.connected["alice"] = true
+	f.settings.settings[0].Enabled = false
+
+	digest, err := f.service.Preview("alice")
+	if err != nil {
+		t.Fatalf("Preview() = %v", err)
+	}
+	if got := digest.MessageCount(); got != 3 {
+		t.Errorf("preview has %d messages, want 3", got)
+	}
+	if len(f.transport.sent) != 0 {
+		t.Errorf("Preview() sent %d digests", len(f.transport.sent))
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+const (
+	expiryInterval  = 10 * time.Second
+	expiryBatchSize = 500
+	maxMessageTTL   = int64(365 * 24 * time.Hour / time.Second)
+)
+
+type messageExpiryService struct {
+	messageRepository database.MessageRepository
+	websocketService  WebsocketService
+}
+
+// MessageExpiryService removes disappearing messages once their TTL has passed
+type MessageExpiryService interface {
+	Run(ctx context.Context)
+}
+
+func NewMessageExpiryService(messageRepo database.MessageRepository, wsService WebsocketService) MessageExpiryService {
+	return &messageExpiryService{
+		messageRepository: messageRepo,
+		websocketService:  wsService,
+	}
+}
+
+func (s *messageExpiryService) Run(ctx context.Context) {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deleteExpired()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *messageExpiryService) deleteExpired() {
+	for {
+		messages, err := s.messageRepository.DeleteExpiredMessages(time.Now(), expiryBatchSize)
+		if err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+			return
+		}
+		for _, message := range messages {
+			s.websocketService.BroadcastMessageDeleted(message, "expired")
+		}
+		if len(messages) > 0 {
+			log.Printf("Deleted %d expired messages", len(messages))
+		}
+		if len(messages) < expiryBatchSize {
+			return
+		}
+	}
+}
+
+func validateMessageTTL(ttl int64) error {
+	if ttl < 0 || ttl > maxMessageTTL {
+		return errors.New("invalid message ttl: must be between 0 and one year in seconds")
+	}
+	return nil
+}
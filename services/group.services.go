@@ -2,8 +2,11 @@ package services
 
 import (
 	"errors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
 	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
 	"github.com/google/uuid"
@@ -14,25 +17,37 @@ type groupService struct {
 	userRepository    database.UserRepository
 	messageRepository database.MessageRepository
 	websocketService  WebsocketService
+	auditService      AuditService
+	publisher         EventPublisher
 }
 
 type GroupService interface {
 	GetAllGroups() ([]*models.Group, error)
 	GetGroup(groupID string) (*models.Group, error)
 	CreateGroup(name, owner string) (*models.Group, error)
-	AddMember(groupID, username string) error
+	AddMember(groupID, username, requester string) error
 	KickMember(groupID, username, requester string) error
 	AddAdmin(groupID, username, requester string) error
 	RemoveAdmin(groupID, username, requester string) error
 	GetGroupMessages(groupID string) ([]*models.MessageDB, error)
+	SetMessageTTL(groupID string, ttl int64, requester string) error
+	SetTopic(groupID, topic, requester string) error
+	MuteMember(groupID, username string, duration time.Duration, requester string) error
+	UnmuteMember(groupID, username, requester string) error
+	ListMutes(groupID, requester string) ([]models.GroupMute, error)
+	BanMember(groupID, username, reason string, duration time.Duration, requester string) error
+	UnbanMember(groupID, username, requester string) error
+	ListBans(groupID, requester string) ([]models.GroupBan, error)
 }
 
-func NewGroupService(groupRepo database.GroupRepository, userRepo database.UserRepository, messageRepo database.MessageRepository, wsService WebsocketService) GroupService {
+func NewGroupService(groupRepo database.GroupRepository, userRepo database.UserRepository, messageRepo database.MessageRepository, wsService WebsocketService, auditService AuditService, publisher EventPublisher) GroupService {
 	return &groupService{
 		groupRepository:   groupRepo,
 		userRepository:    userRepo,
 		messageRepository: messageRepo,
 		websocketService:  wsService,
+		auditService:      auditService,
+		publisher:         publisher,
 	}
 }
 
@@ -68,12 +83,18 @@ func (s *groupService) CreateGroup(name, owner string) (*models.Group, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.auditService.Record(owner, models.AuditGroupCreated, group.ID, nil, createdGroup)
+	s.publish(models.WebhookGroupCreated, createdGroup, owner, "")
 	s.websocketService.AddToGroup(&models.Client{Username: owner}, group.ID)
 	s.websocketService.BroadcastGroupCreated(owner, group.ID)
 	return createdGroup, nil
 }
 
-func (s *groupService) AddMember(groupID, username string) error {
+// AddMember adds username to a group. An empty requester means the user joined on their own.
+func (s *groupService) AddMember(groupID, username, requester string) error {
+	if requester == "" {
+		requester = username
+	}
 	group, err := s.groupRepository.GetGroup(groupID)
 	if err != nil || group == nil {
 		return errors.New("group not found")
@@ -82,17 +103,24 @@ func (s *groupService) AddMember(groupID, username string) error {
 	if err != nil {
 		return errors.New("user not found")
 	}
+	if group.IsBanned(username, time.Now()) {
+		return errors.New("user is banned from this group")
+	}
 	for _, m := range group.Members {
 		if m == username {
 			// Do nothing when the user is already a member
 			return nil
 		}
 	}
+	before := snapshotGroup(group)
 	group.Members = append(group.Members, username)
 	err = s.groupRepository.UpdateGroup(group)
 	if err != nil {
 		return err
 	}
+	s.auditService.Record(requester, models.AuditMemberAdded, username, before, group)
+	s.publish(models.WebhookMemberAdded, group, requester, username)
+	s.websocketService.InvalidateAudience(group.Members...)
 	s.websocketService.AddToGroup(&models.Client{Username: username}, groupID)
 	s.websocketService.NotifyGroupUpdate(groupID, "member_added", map[string]string{"username": username})
 	return nil
@@ -103,16 +131,7 @@ func (s *groupService) KickMember(groupID, username, requester string) error {
 	if err != nil || group == nil {
 		return errors.New("group not found")
 	}
-	isAuthorized := group.Owner == requester
-	if !isAuthorized {
-		for _, admin := range group.Admins {
-			if admin == requester {
-				isAuthorized = true
-				break
-			}
-		}
-	}
-	if !isAuthorized {
+	if !isGroupModerator(group, requester) {
 		return errors.New("unauthorized: only owner or admins can kick members")
 	}
 	if username == group.Owner {
@@ -130,6 +149,7 @@ func (s *groupService) KickMember(groupID, username, requester string) error {
 	if !wasMember {
 		return errors.New("user is not a group member")
 	}
+	before := snapshotGroup(group)
 	group.Members = newMembers
 	newAdmins := []string{}
 	for _, a := range group.Admins {
@@ -142,6 +162,9 @@ func (s *groupService) KickMember(groupID, username, requester string) error {
 	if err != nil {
 		return err
 	}
+	s.auditService.Record(requester, models.AuditMemberKicked, username, before, group)
+	s.publish(models.WebhookMemberKicked, group, requester, username)
+	s.websocketService.InvalidateAudience(append(group.Members, username)...)
 	s.websocketService.KickFromGroup(username, groupID)
 	s.websocketService.NotifyGroupUpdate(groupID, "member_kicked", map[string]string{"username": username})
 	return nil
@@ -170,11 +193,14 @@ func (s *groupService) AddAdmin(groupID, username, requester string) error {
 			return errors.New("user is already an admin")
 		}
 	}
+	before := snapshotGroup(group)
 	group.Admins = append(group.Admins, username)
 	err = s.groupRepository.UpdateGroup(group)
 	if err != nil {
 		return err
 	}
+	s.auditService.Record(requester, models.AuditAdminAdded, username, before, group)
+	s.publish(models.WebhookAdminAdded, group, requester, username)
 	s.websocketService.NotifyGroupUpdate(groupID, "admin_added", map[string]string{"username": username})
 	return nil
 }
@@ -202,11 +228,14 @@ func (s *groupService) RemoveAdmin(groupID, username, requester string) error {
 	if !wasAdmin {
 		return errors.New("user is not an admin")
 	}
+	before := snapshotGroup(group)
 	group.Admins = newAdmins
 	err = s.groupRepository.UpdateGroup(group)
 	if err != nil {
 		return err
 	}
+	s.auditService.Record(requester, models.AuditAdminRemoved, username, before, group)
+	s.publish(models.WebhookAdminRemoved, group, requester, username)
 	s.websocketService.NotifyGroupUpdate(groupID, "admin_removed", map[string]string{"username": username})
 	return nil
 }
@@ -214,3 +243,292 @@ func (s *groupService) RemoveAdmin(groupID, username, requester string) error {
 func (s *groupService) GetGroupMessages(groupID string) ([]*models.MessageDB, error) {
 	return s.messageRepository.GetGroupMessages(groupID)
 }
+
+func (s *groupService) SetMessageTTL(groupID string, ttl int64, requester string) error {
+	if err := validateMessageTTL(ttl); err != nil {
+		return err
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can change message retention")
+	}
+	before := snapshotGroup(group)
+	group.MessageTTL = ttl
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditMessageTTLChanged, group.ID, before, group)
+	// Only messages sent from now on pick up the new TTL
+	s.websocketService.NotifyGroupUpdate(groupID, "message_ttl_changed", map[string]interface{}{"username": requester, "message_ttl": ttl})
+	return nil
+}
+
+const maxTopicLength = 250
+
+// SetTopic changes the group topic; an empty topic clears it
+func (s *groupService) SetTopic(groupID, topic, requester string) error {
+	topic = strings.TrimSpace(topic)
+	if utf8.RuneCountInString(topic) > maxTopicLength {
+		return errors.New("invalid topic: must be at most 250 characters")
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can change the topic")
+	}
+	before := snapshotGroup(group)
+	group.Topic = topic
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditTopicChanged, group.ID, before, group)
+	s.websocketService.NotifyGroupUpdate(groupID, "topic_changed", map[string]string{"username": requester, "topic": topic})
+	return nil
+}
+
+// isGroupModerator reports whether username may moderate the group: its owner, one of
+// its admins or a system admin
+func isGroupModerator(group *models.Group, username string) bool {
+	if group.Owner == username || configs.IsSystemAdmin(username) {
+		return true
+	}
+	for _, admin := range group.Admins {
+		if admin == username {
+			return true
+		}
+	}
+	return false
+}
+
+// MuteMember stops a member from posting for duration, or until unmuted when duration is zero
+func (s *groupService) MuteMember(groupID, username string, duration time.Duration, requester string) error {
+	if duration < 0 {
+		return errors.New("invalid mute: duration cannot be negative")
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can mute members")
+	}
+	if username == group.Owner {
+		return errors.New("cannot mute group owner")
+	}
+	isMember := false
+	for _, m := range group.Members {
+		if m == username {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		return errors.New("user is not a group member")
+	}
+
+	before := snapshotGroup(group)
+	now := time.Now()
+	mute := models.GroupMute{Username: username, MutedBy: requester, MutedAt: now}
+	if duration > 0 {
+		until := now.Add(duration)
+		mute.Until = &until
+	}
+	group.Mutes = append(withoutMute(group.Mutes, username), mute)
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditMemberMuted, username, before, group)
+	s.websocketService.NotifyGroupUpdate(groupID, "member_muted", map[string]interface{}{"username": username, "until": mute.Until})
+	return nil
+}
+
+func (s *groupService) UnmuteMember(groupID, username, requester string) error {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can unmute members")
+	}
+	remaining := withoutMute(group.Mutes, username)
+	if len(remaining) == len(group.Mutes) {
+		return errors.New("user is not muted")
+	}
+	before := snapshotGroup(group)
+	group.Mutes = remaining
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditMemberUnmuted, username, before, group)
+	s.websocketService.NotifyGroupUpdate(groupID, "member_unmuted", map[string]string{"username": username})
+	return nil
+}
+
+// ListMutes returns the mutes still in effect to the group's moderators
+func (s *groupService) ListMutes(groupID, requester string) ([]models.GroupMute, error) {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return nil, errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return nil, errors.New("unauthorized: only owner or admins can list mutes")
+	}
+	now := time.Now()
+	mutes := []models.GroupMute{}
+	for _, mute := range group.Mutes {
+		if mute.Until == nil || mute.Until.After(now) {
+			mutes = append(mutes, mute)
+		}
+	}
+	return mutes, nil
+}
+
+// BanMember removes a user from the group and keeps them out for duration, or until unbanned
+// when duration is zero. Banning someone already banned replaces the reason and expiry.
+func (s *groupService) BanMember(groupID, username, reason string, duration time.Duration, requester string) error {
+	if duration < 0 {
+		return errors.New("invalid ban: duration cannot be negative")
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can ban members")
+	}
+	if username == group.Owner {
+		return errors.New("cannot ban group owner")
+	}
+
+	before := snapshotGroup(group)
+	wasMember := false
+	newMembers := []string{}
+	for _, m := range group.Members {
+		if m != username {
+			newMembers = append(newMembers, m)
+		} else {
+			wasMember = true
+		}
+	}
+	group.Members = newMembers
+	newAdmins := []string{}
+	for _, a := range group.Admins {
+		if a != username {
+			newAdmins = append(newAdmins, a)
+		}
+	}
+	group.Admins = newAdmins
+	group.Mutes = withoutMute(group.Mutes, username)
+	now := time.Now()
+	ban := models.GroupBan{
+		Username: username,
+		Reason:   strings.TrimSpace(reason),
+		BannedBy: requester,
+		BannedAt: now,
+	}
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+	group.Bans = append(withoutBan(group.Bans, username, now), ban)
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditMemberBanned, username, before, group)
+	if wasMember {
+		// To webhooks a ban that removes a member is a kick
+		s.publish(models.WebhookMemberKicked, group, requester, username)
+		s.websocketService.InvalidateAudience(append(group.Members, username)...)
+	}
+	// Someone who left may still be subscribed to the group's live messages
+	s.websocketService.KickFromGroup(username, groupID)
+	s.websocketService.NotifyGroupUpdate(groupID, "member_banned", ban)
+	s.websocketService.SendToUser(username, models.Message{Type: "banned", GroupID: groupID, Data: ban})
+	return nil
+}
+
+func (s *groupService) UnbanMember(groupID, username, requester string) error {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can unban members")
+	}
+	now := time.Now()
+	if !group.IsBanned(username, now) {
+		return errors.New("user is not banned")
+	}
+	before := snapshotGroup(group)
+	group.Bans = withoutBan(group.Bans, username, now)
+	err = s.groupRepository.UpdateGroup(group)
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(requester, models.AuditMemberUnbanned, username, before, group)
+	s.websocketService.NotifyGroupUpdate(groupID, "member_unbanned", map[string]string{"username": username})
+	s.websocketService.SendToUser(username, models.Message{Type: "unbanned", GroupID: groupID})
+	return nil
+}
+
+// ListBans returns the bans still in effect to the group's moderators
+func (s *groupService) ListBans(groupID, requester string) ([]models.GroupBan, error) {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return nil, errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return nil, errors.New("unauthorized: only owner or admins can list bans")
+	}
+	now := time.Now()
+	bans := []models.GroupBan{}
+	for _, ban := range group.Bans {
+		if ban.Active(now) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+// withoutBan drops the bans of username along with every ban that has expired
+func withoutBan(bans []models.GroupBan, username string, now time.Time) []models.GroupBan {
+	remaining := []models.GroupBan{}
+	for _, ban := range bans {
+		if ban.Username != username && ban.Active(now) {
+			remaining = append(remaining, ban)
+		}
+	}
+	return remaining
+}
+
+func withoutMute(mutes []models.GroupMute, username string) []models.GroupMute {
+	remaining := []models.GroupMute{}
+	for _, mute := range mutes {
+		if mute.Username != username {
+			remaining = append(remaining, mute)
+		}
+	}
+	return remaining
+}
+
+// publish hands a group event to the outbound webhooks
+func (s *groupService) publish(event string, group *models.Group, actor, username string) {
+	if s.publisher == nil || group == nil {
+		return
+	}
+	data := map[string]interface{}{"group": group, "actor": actor}
+	if username != "" {
+		data["username"] = username
+	}
+	s.publisher.Publish(event, group.ID, data)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+// fakeGroupHub accepts the notifications group changes send to connected clients
+type fakeGroupHub struct {
+	WebsocketService
+	kicked []string
+}
+
+func (h *fakeGroupHub) KickFromGroup(username string, groupID string) {
+	h.kicked = append(h.kicked, username)
+}
+
+func (h *fakeGroupHub) NotifyGroupUpdate(groupID string, updateType string, data interface{}) {}
+
+func (h *fakeGroupHub) InvalidateAudience(usernames ...string) {}
+
+func (h *fakeGroupHub) SendToUser(username string, message models.Message) {}
+
+type publishedEvent struct {
+	event, groupID string
+	data           interface{}
+}
+
+// fakePublisher records the webhook events a service publishes
+type fakePublisher struct {
+	published []publishedEvent
+}
+
+func (p *fakePublisher) Publish(event, groupID string, data interface{}) {
+	p.published = append(p.published, publishedEvent{event, groupID, data})
+}
+
+func TestBanningAMemberIsPublishedAsAKick(t *testing.T) {
+	groups := &fakeGroupRepository{groups: map[string]models.Group{
+		"g1": {ID: "g1", Name: "Team", Owner: "alice", Members: []string{"alice", "bob"}},
+	}}
+	hub, publisher := &fakeGroupHub{}, &fakePublisher{}
+	s := NewGroupService(groups, nil, nil, hub, &fakeAuditService{}, publisher)
+
+	if err := s.BanMember("g1", "bob", "spam", 0, "alice"); err != nil {
+		t.Fatalf("BanMember() = %v", err)
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("published %d events, want 1", len(publisher.published))
+	}
+	event := publisher.published[0]
+	data, _ := event.data.(map[string]interface{})
+	if event.event != models.WebhookMemberKicked || event.groupID != "g1" || data["username"] != "bob" || data["actor"] != "alice" {
+		t.Errorf("published %s on %s with %v, want bob kicked from g1 by alice", event.event, event.groupID, event.data)
+	}
+
+	// Banning someone who already left removes nobody
+	if err := s.BanMember("g1", "carol", "", 0, "alice"); err != nil {
+		t.Fatalf("BanMember() = %v", err)
+	}
+	if len(publisher.published) != 1 {
+		t.Errorf("banning a non-member published %d more events", len(publisher.published)-1)
+	}
+	if len(hub.kicked) != 2 {
+		t.Errorf("unsubscribed %v, want both banned users", hub.kicked)
+	}
+}
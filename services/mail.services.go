@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/google/uuid"
+)
+
+// MailTransport sends plain text e-mail
+type MailTransport interface {
+	Send(message *models.MailMessage) error
+}
+
+// NewConfiguredMailTransport builds the transport selected by cfg, or nil when e-mail is off
+func NewConfiguredMailTransport(cfg configs.MailConfig) (MailTransport, error) {
+	switch cfg.Transport {
+	case "":
+		return nil, nil
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.From == "" {
+			return nil, errors.New("SMTP_HOST and MAIL_FROM are required for the smtp mail transport")
+		}
+		return &smtpMailTransport{
+			addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+			host:     cfg.SMTPHost,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+		}, nil
+	case "file":
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			return nil, err
+		}
+		return &fileMailTransport{dir: cfg.Dir}, nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+}
+
+// smtpMailTransport relays through an SMTP server; net/smtp upgrades to STARTTLS when offered
+type smtpMailTransport struct {
+	addr     string
+	host     string
+	username string
+	password string
+}
+
+func (t *smtpMailTransport) Send(message *models.MailMessage) error {
+	var auth smtp.Auth
+	if t.username != "" {
+		auth = smtp.PlainAuth("", t.username, t.password, t.host)
+	}
+	return smtp.SendMail(t.addr, auth, message.From, []string{message.To}, formatMail(message))
+}
+
+// fileMailTransport writes every message to an .eml file instead of sending it, for
+// development and tests
+type fileMailTransport struct {
+	dir string
+}
+
+func (t *fileMailTransport) Send(message *models.MailMessage) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(t.dir, name), formatMail(message), 0o644)
+}
+
+// formatMail renders message as an RFC 5322 message with a UTF-8 plain text body
+func formatMail(message *models.MailMessage) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", message.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes()
+}
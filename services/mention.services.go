@@ -0,0 +1,68 @@
+package services
+
+import (
+	"regexp"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+const (
+	mentionAdmins = "admins"
+	mentionAll    = "all"
+)
+
+// mentionPattern matches @name at the start of the text or after a character that
+// cannot be part of a name, so e-mail addresses are not mistaken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@.-])@([a-zA-Z0-9_-]{1,32})`)
+
+// parseMentions finds the mentions of a group message. Names that are not members are
+// ignored, and the sender is never notified of their own message. It returns nil when
+// the message mentions nobody.
+func parseMentions(content string, group *models.Group, sender string) *models.Mentions {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	mentions := &models.Mentions{}
+	named := make(map[string]bool)
+	for _, match := range matches {
+		name := match[1]
+		switch {
+		case name == mentionAll:
+			mentions.All = true
+		case name == mentionAdmins:
+			mentions.Admins = true
+		case !named[name] && group.IsMember(name):
+			named[name] = true
+			mentions.Users = append(mentions.Users, name)
+		}
+	}
+
+	notified := make(map[string]bool)
+	notify := func(username string) {
+		if username != sender && !notified[username] && group.IsMember(username) {
+			notified[username] = true
+			mentions.Notified = append(mentions.Notified, username)
+		}
+	}
+	if mentions.All {
+		for _, member := range group.Members {
+			notify(member)
+		}
+	}
+	if mentions.Admins {
+		notify(group.Owner)
+		for _, admin := range group.Admins {
+			notify(admin)
+		}
+	}
+	for _, username := range mentions.Users {
+		notify(username)
+	}
+
+	if len(mentions.Users) == 0 && !mentions.All && !mentions.Admins {
+		return nil
+	}
+	return mentions
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxReportReasonLength  = 500
+	maxRulePatternLength   = 512
+	defaultModerationLimit = 100
+	maxModerationLimit     = 500
+)
+
+type moderationService struct {
+	moderationRepository database.ModerationRepository
+	groupRepository      database.GroupRepository
+	messageRepository    database.MessageRepository
+	groupService         GroupService
+	websocketService     WebsocketService
+}
+
+type ModerationService interface {
+	ListRules(groupID, requester string) ([]*models.ModerationRule, error)
+	AddRule(groupID, pattern, action, reason, requester string) (*models.ModerationRule, error)
+	DeleteRule(groupID, ruleID, requester string) error
+	ListQueue(requester string, filter models.ModerationQueueFilter) ([]*models.ModerationItem, error)
+	ResolveItem(id, requester string, decision models.ModerationDecision) (*models.ModerationItem, error)
+	CreateReport(report *models.Report) (*models.ModerationItem, error)
+}
+
+func NewModerationService(moderationRepo database.ModerationRepository, groupRepo database.GroupRepository, messageRepo database.MessageRepository, groupService GroupService, wsService WebsocketService) ModerationService {
+	return &moderationService{
+		moderationRepository: moderationRepo,
+		groupRepository:      groupRepo,
+		messageRepository:    messageRepo,
+		groupService:         groupService,
+		websocketService:     wsService,
+	}
+}
+
+// requireGroupModerator allows the group owner, its admins and system admins
+func (s *moderationService) requireGroupModerator(groupID, requester string) error {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only group admins or system admins can moderate")
+	}
+	return nil
+}
+
+func (s *moderationService) ListRules(groupID, requester string) ([]*models.ModerationRule, error) {
+	if err := s.requireGroupModerator(groupID, requester); err != nil {
+		return nil, err
+	}
+	return s.moderationRepository.GetModerationRules(groupID)
+}
+
+func (s *moderationService) AddRule(groupID, pattern, action, reason, requester string) (*models.ModerationRule, error) {
+	if pattern == "" || len(pattern) > maxRulePatternLength {
+		return nil, errors.New("invalid moderation rule: pattern must be between 1 and 512 characters")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, errors.New("invalid moderation rule: " + err.Error())
+	}
+	if validFilterAction(action, "") == "" {
+		return nil, errors.New("invalid moderation rule: action must be mask, flag or reject")
+	}
+	if err := s.requireGroupModerator(groupID, requester); err != nil {
+		return nil, err
+	}
+	rule := &models.ModerationRule{
+		ID:        uuid.New().String(),
+		GroupID:   groupID,
+		Pattern:   pattern,
+		Action:    action,
+		Reason:    strings.TrimSpace(reason),
+		CreatedBy: requester,
+		CreatedAt: time.Now(),
+	}
+	if err := s.moderationRepository.CreateModerationRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *moderationService) DeleteRule(groupID, ruleID, requester string) error {
+	if err := s.requireGroupModerator(groupID, requester); err != nil {
+		return err
+	}
+	deleted, err := s.moderationRepository.DeleteModerationRule(groupID, ruleID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("moderation rule not found")
+	}
+	return nil
+}
+
+// ListQueue returns review items of one group to its moderators, or of every
+// conversation to system admins
+func (s *moderationService) ListQueue(requester string, filter models.ModerationQueueFilter) ([]*models.ModerationItem, error) {
+	if filter.GroupID != "" {
+		if err := s.requireGroupModerator(filter.GroupID, requester); err != nil {
+			return nil, err
+		}
+	} else if !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: only system admins can review every conversation")
+	}
+	if filter.Limit < 0 || filter.Limit > maxModerationLimit {
+		return nil, errors.New("invalid moderation query: limit must be between 1 and 500")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultModerationLimit
+	}
+	return s.moderationRepository.GetModerationItems(filter)
+}
+
+func (s *moderationService) ResolveItem(id, requester string, decision models.ModerationDecision) (*models.ModerationItem, error) {
+	item, err := s.moderationRepository.GetModerationItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errors.New("moderation item not found")
+	}
+	if item.GroupID != "" {
+		if err := s.requireGroupModerator(item.GroupID, requester); err != nil {
+			return nil, err
+		}
+	} else if !configs.IsSystemAdmin(requester) {
+		return nil, errors.New("unauthorized: only system admins can moderate direct messages")
+	}
+	if item.Status != models.ModerationItemPending {
+		return nil, errors.New("moderation item already resolved")
+	}
+
+	resolution := decision.Action
+	if err := s.apply(item, requester, decision); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resolved, err := s.moderationRepository.ResolveModerationItem(item.ID, resolution, requester, now)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, errors.New("moderation item already resolved")
+	}
+	item.Status = models.ModerationItemResolved
+	item.Resolution = resolution
+	item.ReviewedBy = requester
+	item.ReviewedAt = &now
+	log.Printf("Moderation item %s resolved by %s: %s", item.ID, requester, resolution)
+
+	if item.Reporter != "" {
+		s.websocketService.SendToUser(item.Reporter, models.Message{
+			Type:    "report_update",
+			ID:      item.ID,
+			GroupID: item.GroupID,
+			Status:  resolution,
+			Data:    item,
+		})
+	}
+	return item, nil
+}
+
+// apply carries out a moderator's decision. Mute, kick and ban go through GroupService
+// so they are authorised, audited and announced like any other group change.
+func (s *moderationService) apply(item *models.ModerationItem, requester string, decision models.ModerationDecision) error {
+	switch decision.Action {
+	case models.ModerationDismiss:
+		return nil
+	case models.ModerationDeleteMessage:
+		return s.deleteMessage(item)
+	case models.ModerationMute, models.ModerationKick, models.ModerationBan:
+	default:
+		return errors.New("invalid moderation action: " + decision.Action)
+	}
+
+	if item.GroupID == "" {
+		return errors.New("invalid moderation action: " + decision.Action + " only applies to group conversations")
+	}
+	if decision.Duration < 0 {
+		return errors.New("invalid moderation action: duration cannot be negative")
+	}
+	switch decision.Action {
+	case models.ModerationMute:
+		return s.groupService.MuteMember(item.GroupID, item.Subject(), time.Duration(decision.Duration)*time.Second, requester)
+	case models.ModerationKick:
+		return s.groupService.KickMember(item.GroupID, item.Subject(), requester)
+	default:
+		reason := strings.TrimSpace(decision.Reason)
+		if reason == "" {
+			reason = item.Reason
+		}
+		return s.groupService.BanMember(item.GroupID, item.Subject(), reason, time.Duration(decision.Duration)*time.Second, requester)
+	}
+}
+
+// CreateReport queues a member's report about a message or a user. Reporters must be able
+// to see what they report: a participant of the conversation or a member of the group.
+func (s *moderationService) CreateReport(report *models.Report) (*models.ModerationItem, error) {
+	report.Reason = strings.TrimSpace(report.Reason)
+	if report.Reporter == "" || report.Reason == "" {
+		return nil, errors.New("invalid report: requester and reason are required")
+	}
+	if len([]rune(report.Reason)) > maxReportReasonLength {
+		return nil, errors.New("invalid report: reason is too long")
+	}
+	if report.MessageID == "" && report.Username == "" {
+		return nil, errors.New("invalid report: a message_id or username is required")
+	}
+
+	item := &models.ModerationItem{
+		ID:        uuid.New().String(),
+		Source:    models.ModerationSourceReport,
+		Status:    models.ModerationItemPending,
+		Reason:    report.Reason,
+		Reporter:  report.Reporter,
+		CreatedAt: time.Now(),
+	}
+	if report.MessageID != "" {
+		message, err := s.messageRepository.GetMessage(report.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil {
+			return nil, errors.New("message not found")
+		}
+		if message.GroupID != "" {
+			if err := s.requireMember(message.GroupID, report.Reporter); err != nil {
+				return nil, err
+			}
+		} else if message.Sender != report.Reporter && message.Receiver != report.Reporter {
+			return nil, errors.New("unauthorized: only participants can report a direct message")
+		}
+		item.MessageID = message.ID
+		item.Sender = message.Sender
+		item.Receiver = message.Receiver
+		item.GroupID = message.GroupID
+		item.Content = message.Content
+		item.ReportedUser = message.Sender
+	} else {
+		if report.GroupID != "" {
+			if err := s.requireMember(report.GroupID, report.Reporter); err != nil {
+				return nil, err
+			}
+		}
+		item.GroupID = report.GroupID
+		item.ReportedUser = report.Username
+	}
+	if item.ReportedUser == report.Reporter {
+		return nil, errors.New("invalid report: you cannot report yourself")
+	}
+
+	exists, err := s.moderationRepository.HasPendingReport(report.Reporter, item.MessageID, item.ReportedUser)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("report already submitted")
+	}
+	if err := s.moderationRepository.CreateModerationItem(item); err != nil {
+		return nil, err
+	}
+	log.Printf("User %s reported %s (item %s)", report.Reporter, item.ReportedUser, item.ID)
+	return item, nil
+}
+
+func (s *moderationService) requireMember(groupID, username string) error {
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	for _, member := range group.Members {
+		if member == username {
+			return nil
+		}
+	}
+	return errors.New("unauthorized: only group members can report in this group")
+}
+
+// deleteMessage removes the reported message and tells the conversation it is gone
+func (s *moderationService) deleteMessage(item *models.ModerationItem) error {
+	if item.MessageID == "" {
+		return errors.New("invalid moderation action: item has no message")
+	}
+	message, err := s.messageRepository.DeleteMessage(item.MessageID)
+	if err != nil {
+		return err
+	}
+	// The message may already be gone through expiry or retention
+	if message != nil {
+		s.websocketService.BroadcastMessageDeleted(message, "moderated")
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const classifierTimeout = 2 * time.Second
+
+// MessageFilter inspects a chat message before it is stored. Filters run in order and see
+// the content as masked by the filters before them. A verdict carries Content only when
+// the filter masked something. A filter that fails is skipped.
+type MessageFilter interface {
+	Name() string
+	Check(msg *models.Message) (models.ModerationVerdict, error)
+}
+
+// MessageModerator runs inbound chat messages through the filter chain
+type MessageModerator interface {
+	Moderate(msg *models.Message) models.ModerationVerdict
+}
+
+type messageModerator struct {
+	filters              []MessageFilter
+	moderationRepository database.ModerationRepository
+}
+
+func NewMessageModerator(moderationRepo database.ModerationRepository, filters ...MessageFilter) MessageModerator {
+	return &messageModerator{
+		filters:              filters,
+		moderationRepository: moderationRepo,
+	}
+}
+
+// NewConfiguredMessageFilters builds the filter chain described by the server configuration,
+// followed by the per-group rules
+func NewConfiguredMessageFilters(config configs.ModerationConfig, moderationRepo database.ModerationRepository) []MessageFilter {
+	filters := []MessageFilter{}
+	if len(config.Keywords) > 0 {
+		filters = append(filters, NewKeywordFilter(config.Keywords, config.KeywordAction))
+	}
+	if config.BlockLinks {
+		filters = append(filters, NewLinkFilter(config.AllowedDomains, config.LinkAction))
+	}
+	filters = append(filters, NewGroupRuleFilter(moderationRepo))
+	if config.ClassifierURL != "" {
+		filters = append(filters, NewClassifierFilter(config.ClassifierURL))
+	}
+	return filters
+}
+
+// Moderate returns the strictest verdict of the chain. Flagged messages are still
+// delivered and are added to the review queue.
+func (m *messageModerator) Moderate(msg *models.Message) models.ModerationVerdict {
+	verdict := models.ModerationVerdict{Action: models.ModerationAllow, Content: msg.Content}
+	for _, filter := range m.filters {
+		current := *msg
+		current.Content = verdict.Content
+		result, err := filter.Check(&current)
+		if err != nil {
+			log.Printf("Moderation filter %s failed on message %s: %v", filter.Name(), msg.ID, err)
+			continue
+		}
+		if result.Content != "" {
+			verdict.Content = result.Content
+		}
+		if models.ModerationSeverity(result.Action) > models.ModerationSeverity(verdict.Action) {
+			verdict.Action = result.Action
+			verdict.Reason = result.Reason
+			verdict.Filter = filter.Name()
+		}
+		if verdict.Action == models.ModerationReject {
+			break
+		}
+	}
+
+	if verdict.Action != models.ModerationAllow {
+		log.Printf("Moderation %s message %s from %s (%s: %s)", verdict.Action, msg.ID, msg.Sender, verdict.Filter, verdict.Reason)
+	}
+	if verdict.Action == models.ModerationFlag {
+		item := &models.ModerationItem{
+			ID:        uuid.New().String(),
+			Source:    models.ModerationSourceFilter,
+			Status:    models.ModerationItemPending,
+			MessageID: msg.ID,
+			Sender:    msg.Sender,
+			Receiver:  msg.Receiver,
+			GroupID:   msg.GroupID,
+			Content:   msg.Content,
+			Action:    verdict.Action,
+			Reason:    verdict.Reason,
+			Filter:    verdict.Filter,
+			CreatedAt: time.Now(),
+		}
+		if err := m.moderationRepository.CreateModerationItem(item); err != nil {
+			log.Printf("Failed to queue flagged message %s for review: %v", msg.ID, err)
+		}
+	}
+	return verdict
+}
+
+// validFilterAction returns action when a filter may take it, or fallback otherwise
+func validFilterAction(action, fallback string) string {
+	switch action {
+	case models.ModerationMask, models.ModerationFlag, models.ModerationReject:
+		return action
+	}
+	return fallback
+}
+
+// applyPattern turns a pattern match into a verdict, masking every match when asked to
+func applyPattern(pattern *regexp.Regexp, content, action, reason string, mask func(string) string) models.ModerationVerdict {
+	if !pattern.MatchString(content) {
+		return models.ModerationVerdict{Action: models.ModerationAllow}
+	}
+	verdict := models.ModerationVerdict{Action: action, Reason: reason}
+	if action == models.ModerationMask {
+		verdict.Content = pattern.ReplaceAllStringFunc(content, mask)
+	}
+	return verdict
+}
+
+func maskRunes(match string) string {
+	return strings.Repeat("*", len([]rune(match)))
+}
+
+type keywordFilter struct {
+	pattern *regexp.Regexp
+	action  string
+}
+
+// NewKeywordFilter matches whole words regardless of case; the action defaults to mask
+func NewKeywordFilter(words []string, action string) MessageFilter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	return &keywordFilter{
+		pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+		action:  validFilterAction(action, models.ModerationMask),
+	}
+}
+
+func (f *keywordFilter) Name() string {
+	return "keywords"
+}
+
+func (f *keywordFilter) Check(msg *models.Message) (models.ModerationVerdict, error) {
+	return applyPattern(f.pattern, msg.Content, f.action, "blocked word", maskRunes), nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+type linkFilter struct {
+	allowedDomains []string
+	action         string
+}
+
+// NewLinkFilter blocks links outside the allowed domains; the action defaults to reject
+func NewLinkFilter(allowedDomains []string, action string) MessageFilter {
+	domains := make([]string, 0, len(allowedDomains))
+	for _, domain := range allowedDomains {
+		domains = append(domains, strings.ToLower(strings.TrimPrefix(domain, ".")))
+	}
+	return &linkFilter{
+		allowedDomains: domains,
+		action:         validFilterAction(action, models.ModerationReject),
+	}
+}
+
+func (f *linkFilter) Name() string {
+	return "links"
+}
+
+func (f *linkFilter) Check(msg *models.Message) (models.ModerationVerdict, error) {
+	blocked := false
+	content := linkPattern.ReplaceAllStringFunc(msg.Content, func(link string) string {
+		if f.isAllowed(link) {
+			return link
+		}
+		blocked = true
+		return "[link removed]"
+	})
+	if !blocked {
+		return models.ModerationVerdict{Action: models.ModerationAllow}, nil
+	}
+	verdict := models.ModerationVerdict{Action: f.action, Reason: "links are not allowed"}
+	if f.action == models.ModerationMask {
+		verdict.Content = content
+	}
+	return verdict, nil
+}
+
+func (f *linkFilter) isAllowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range f.allowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+type groupRuleFilter struct {
+	moderationRepository database.ModerationRepository
+	// compiled caches regular expressions by pattern across groups
+	compiled sync.Map
+}
+
+// NewGroupRuleFilter applies the regular expression rules group admins set on their group
+func NewGroupRuleFilter(moderationRepo database.ModerationRepository) MessageFilter {
+	return &groupRuleFilter{moderationRepository: moderationRepo}
+}
+
+func (f *groupRuleFilter) Name() string {
+	return "group_rules"
+}
+
+func (f *groupRuleFilter) Check(msg *models.Message) (models.ModerationVerdict, error) {
+	verdict := models.ModerationVerdict{Action: models.ModerationAllow}
+	if msg.GroupID == "" {
+		return verdict, nil
+	}
+	rules, err := f.moderationRepository.GetModerationRules(msg.GroupID)
+	if err != nil {
+		return verdict, err
+	}
+	content := msg.Content
+	for _, rule := range rules {
+		pattern, err := f.compile(rule.Pattern)
+		if err != nil {
+			log.Printf("Skipping invalid moderation rule %s of group %s: %v", rule.ID, rule.GroupID, err)
+			continue
+		}
+		reason := rule.Reason
+		if reason == "" {
+			reason = "matched a group rule"
+		}
+		result := applyPattern(pattern, content, rule.Action, reason, maskRunes)
+		if result.Action == models.ModerationMask {
+			content = result.Content
+			verdict.Content = content
+		}
+		if models.ModerationSeverity(result.Action) > models.ModerationSeverity(verdict.Action) {
+			verdict.Action = result.Action
+			verdict.Reason = result.Reason
+		}
+	}
+	return verdict, nil
+}
+
+func (f *groupRuleFilter) compile(pattern string) (*regexp.Regexp, error) {
+	if compiled, ok := f.compiled.Load(pattern); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.compiled.Store(pattern, compiled)
+	return compiled, nil
+}
+
+type classifierFilter struct {
+	url    string
+	client *http.Client
+}
+
+// NewClassifierFilter asks an external HTTP service for a verdict. The service receives
+// the message as JSON and answers with {"action", "reason", "content"}; content is only
+// used when the action is mask.
+func NewClassifierFilter(url string) MessageFilter {
+	return &classifierFilter{
+		url:    url,
+		client: &http.Client{Timeout: classifierTimeout},
+	}
+}
+
+func (f *classifierFilter) Name() string {
+	return "classifier"
+}
+
+func (f *classifierFilter) Check(msg *models.Message) (models.ModerationVerdict, error) {
+	body, err := json.Marshal(map[string]string{
+		"id":       msg.ID,
+		"sender":   msg.Sender,
+		"receiver": msg.Receiver,
+		"group_id": msg.GroupID,
+		"content":  msg.Content,
+	})
+	if err != nil {
+		return models.ModerationVerdict{}, err
+	}
+	resp, err := f.client.Post(f.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return models.ModerationVerdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return models.ModerationVerdict{}, fmt.Errorf("classifier answered %s", resp.Status)
+	}
+
+	var verdict models.ModerationVerdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return models.ModerationVerdict{}, err
+	}
+	verdict.Action = validFilterAction(verdict.Action, models.ModerationAllow)
+	if verdict.Action != models.ModerationMask {
+		verdict.Content = ""
+	}
+	return verdict, nil
+}
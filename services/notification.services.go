@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// NotificationPolicy decides whether a user wants to be notified about something that
+// happened in a conversation. Every push path asks it before notifying anyone.
+type NotificationPolicy interface {
+	ShouldNotify(username, conversation string, mentioned bool) bool
+}
+
+type notificationService struct {
+	settingsRepository database.NotificationSettingsRepository
+	userRepository     database.UserRepository
+	groupRepository    database.GroupRepository
+}
+
+type NotificationService interface {
+	NotificationPolicy
+	GetSettings(username string) (*models.NotificationSettings, error)
+	SetConversationLevel(username, conversationType, id, level string, until *time.Time) (*models.NotificationSettings, error)
+	ClearConversationLevel(username, conversationType, id string) (*models.NotificationSettings, error)
+	SetDoNotDisturb(username string, dnd *models.DoNotDisturb) (*models.NotificationSettings, error)
+	ClearDoNotDisturb(username string) (*models.NotificationSettings, error)
+}
+
+func NewNotificationService(settingsRepo database.NotificationSettingsRepository, userRepo database.UserRepository, groupRepo database.GroupRepository) NotificationService {
+	return &notificationService{
+		settingsRepository: settingsRepo,
+		userRepository:     userRepo,
+		groupRepository:    groupRepo,
+	}
+}
+
+// ShouldNotify applies the user's settings; when they cannot be loaded the user is notified
+func (s *notificationService) ShouldNotify(username, conversation string, mentioned bool) bool {
+	settings, err := s.settingsRepository.GetNotificationSettings(username)
+	if err != nil {
+		log.Printf("Failed to load notification settings of %s: %v", username, err)
+		return true
+	}
+	return settings == nil || settings.Allows(conversation, mentioned, time.Now())
+}
+
+// GetSettings returns the user's settings, or the defaults when they never changed them
+func (s *notificationService) GetSettings(username string) (*models.NotificationSettings, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	settings, err := s.settingsRepository.GetNotificationSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &models.NotificationSettings{Username: username, Conversations: []models.ConversationNotification{}}
+	}
+	return settings, nil
+}
+
+func (s *notificationService) SetConversationLevel(username, conversationType, id, level string, until *time.Time) (*models.NotificationSettings, error) {
+	if level != models.NotifyAll && level != models.NotifyMentions && level != models.NotifyNone {
+		return nil, errors.New("invalid notification settings: level must be all, mentions or none")
+	}
+	if until != nil && !until.After(time.Now()) {
+		return nil, errors.New("invalid notification settings: until must be in the future")
+	}
+	if err := s.checkConversation(username, conversationType, id); err != nil {
+		return nil, err
+	}
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	key := models.ConversationKey(conversationType, id)
+	settings.Conversations = withoutConversation(settings.Conversations, key)
+	if level != models.NotifyAll {
+		settings.Conversations = append(settings.Conversations, models.ConversationNotification{
+			Conversation: key,
+			Level:        level,
+			Until:        until,
+		})
+	}
+	return settings, s.save(settings)
+}
+
+func (s *notificationService) ClearConversationLevel(username, conversationType, id string) (*models.NotificationSettings, error) {
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	settings.Conversations = withoutConversation(settings.Conversations, models.ConversationKey(conversationType, id))
+	return settings, s.save(settings)
+}
+
+// SetDoNotDisturb sets the quiet hours. Without a time zone the one from the user's
+// profile is used, or UTC.
+func (s *notificationService) SetDoNotDisturb(username string, dnd *models.DoNotDisturb) (*models.NotificationSettings, error) {
+	start, err := models.ParseClock(dnd.Start)
+	if err != nil {
+		return nil, errors.New("invalid notification settings: " + err.Error())
+	}
+	end, err := models.ParseClock(dnd.End)
+	if err != nil {
+		return nil, errors.New("invalid notification settings: " + err.Error())
+	}
+	if start == end {
+		return nil, errors.New("invalid notification settings: do not disturb must start and end at different times")
+	}
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if dnd.TimeZone == "" {
+		dnd.TimeZone = user.TimeZone
+	}
+	if dnd.TimeZone == "" {
+		dnd.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(dnd.TimeZone); err != nil {
+		return nil, errors.New("invalid notification settings: unknown time zone")
+	}
+
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	settings.DoNotDisturb = dnd
+	return settings, s.save(settings)
+}
+
+func (s *notificationService) ClearDoNotDisturb(username string) (*models.NotificationSettings, error) {
+	settings, err := s.GetSettings(username)
+	if err != nil {
+		return nil, err
+	}
+	settings.DoNotDisturb = nil
+	return settings, s.save(settings)
+}
+
+// save stores the settings, dropping overrides that already lapsed
+func (s *notificationService) save(settings *models.NotificationSettings) error {
+	now := time.Now()
+	current := []models.ConversationNotification{}
+	for _, setting := range settings.Conversations {
+		if setting.Until == nil || setting.Until.After(now) {
+			current = append(current, setting)
+		}
+	}
+	settings.Conversations = current
+	settings.UpdatedAt = now
+	return s.settingsRepository.SaveNotificationSettings(settings)
+}
+
+// checkConversation makes sure a user takes part in the conversation they configure
+func (s *notificationService) checkConversation(username, conversationType, id string) error {
+	if username == "" || id == "" {
+		return errors.New("username and conversation are required")
+	}
+	if conversationType == models.ConversationGroup {
+		group, err := s.groupRepository.GetGroup(id)
+		if err != nil || group == nil {
+			return errors.New("group not found")
+		}
+		if !group.IsMember(username) {
+			return errors.New("invalid notification settings: not a member of this group")
+		}
+		return nil
+	}
+	partner, err := s.userRepository.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if partner == nil {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func withoutConversation(settings []models.ConversationNotification, conversation string) []models.ConversationNotification {
+	remaining := []models.ConversationNotification{}
+	for _, setting := range settings {
+		if setting.Conversation != conversation {
+			remaining = append(remaining, setting)
+		}
+	}
+	return remaining
+}
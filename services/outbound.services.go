@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+)
+
+// outboundResolveTimeout bounds the DNS lookup made when a user registers an outbound url
+const outboundResolveTimeout = 3 * time.Second
+
+// errPrivateAddress is returned when an outbound url points into our own network
+var errPrivateAddress = errors.New("address is loopback, link-local or private")
+
+// isPublicIP reports whether ip may be reached by requests to user supplied urls. Loopback,
+// link-local (cloud metadata lives at 169.254.169.254) and private ranges are refused.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// outboundAllowed reports whether a request to a user supplied url may reach ip: it must be
+// public or in one of the networks configs.OutboundAllowedNetworks lets through
+func outboundAllowed(ip net.IP) bool {
+	if isPublicIP(ip) {
+		return true
+	}
+	for _, network := range configs.OutboundAllowedNetworks() {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOutboundURL refuses a user supplied url whose host is, or resolves to, an address
+// outboundAllowed rejects. The dialer of newOutboundClient checks again, since DNS may change.
+func checkOutboundURL(target *url.URL) error {
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !outboundAllowed(ip) {
+			return errPrivateAddress
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), outboundResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !outboundAllowed(addr.IP) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// newOutboundClient returns a client for user supplied urls. It never uses a proxy and
+// refuses to connect to any address outboundAllowed rejects, whatever the name resolved to.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !outboundAllowed(ip) {
+				return fmt.Errorf("refusing to connect to %s: %w", host, errPrivateAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const maxPushBodyLength = 140
+
+// PushNotifier notifies users who are not connected about a message
+type PushNotifier interface {
+	Notify(username string, msg *models.Message, mentioned bool)
+}
+
+type pushService struct {
+	deviceRepository database.PushDeviceRepository
+	userRepository   database.UserRepository
+	groupRepository  database.GroupRepository
+	notifications    NotificationPolicy
+	providers        map[string]PushProvider
+	vapidPublicKey   string
+	window           time.Duration
+
+	// pending holds the notifications waiting out the coalescing window, by user and conversation
+	pending map[string]*models.PushNotification
+	mutex   sync.Mutex
+}
+
+type PushService interface {
+	PushNotifier
+	RegisterDevice(username string, device *models.PushDevice) (*models.PushDevice, error)
+	ListDevices(username string) ([]*models.PushDevice, error)
+	DeleteDevice(username, id string) error
+	VAPIDPublicKey() (string, error)
+	Flush(ctx context.Context)
+}
+
+func NewPushService(deviceRepo database.PushDeviceRepository, userRepo database.UserRepository, groupRepo database.GroupRepository, notifications NotificationPolicy, cfg configs.PushConfig, providers ...PushProvider) PushService {
+	s := &pushService{
+		deviceRepository: deviceRepo,
+		userRepository:   userRepo,
+		groupRepository:  groupRepo,
+		notifications:    notifications,
+		providers:        make(map[string]PushProvider),
+		window:           cfg.CoalesceWindow,
+		pending:          make(map[string]*models.PushNotification),
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+		if webPush, ok := provider.(*webPushProvider); ok {
+			s.vapidPublicKey = webPush.publicKey
+		}
+	}
+	return s
+}
+
+// Notify queues a notification unless the user's settings silence the conversation.
+// Messages arriving within the coalescing window of the first are merged into it.
+func (s *pushService) Notify(username string, msg *models.Message, mentioned bool) {
+	if len(s.providers) == 0 {
+		return
+	}
+	conversation := models.ConversationKey(models.ConversationDirect, msg.Sender)
+	if msg.GroupID != "" {
+		conversation = models.ConversationKey(models.ConversationGroup, msg.GroupID)
+	}
+	if s.notifications != nil && !s.notifications.ShouldNotify(username, conversation, mentioned) {
+		return
+	}
+
+	key := username + "|" + conversation
+	s.mutex.Lock()
+	notification, exists := s.pending[key]
+	if !exists {
+		notification = &models.PushNotification{
+			Username:     username,
+			Conversation: conversation,
+			GroupID:      msg.GroupID,
+			MessageIDs:   []string{},
+			CreatedAt:    time.Now(),
+		}
+		s.pending[key] = notification
+	}
+	notification.Sender = msg.Sender
+	notification.Body = truncate(msg.Content, maxPushBodyLength)
+	notification.Mentioned = notification.Mentioned || mentioned
+	notification.Count++
+	notification.MessageIDs = append(notification.MessageIDs, msg.ID)
+	s.mutex.Unlock()
+
+	if !exists {
+		time.AfterFunc(s.window, func() { s.flush(context.Background(), key) })
+	}
+}
+
+// Flush sends every notification still waiting out its coalescing window right away.
+// Whatever is left when ctx is done is dropped.
+func (s *pushService) Flush(ctx context.Context) {
+	s.mutex.Lock()
+	keys := make([]string, 0, len(s.pending))
+	for key := range s.pending {
+		keys = append(keys, key)
+	}
+	s.mutex.Unlock()
+	for i, key := range keys {
+		if ctx.Err() != nil {
+			log.Printf("Dropped %d pending push notifications: %v", len(keys)-i, ctx.Err())
+			return
+		}
+		s.flush(ctx, key)
+	}
+}
+
+// flush sends a pending notification to every device of its user, each send bounded by
+// pushSendTimeout and ctx
+func (s *pushService) flush(ctx context.Context, key string) {
+	s.mutex.Lock()
+	notification := s.pending[key]
+	delete(s.pending, key)
+	s.mutex.Unlock()
+	if notification == nil {
+		return
+	}
+
+	notification.Title = s.title(notification)
+	devices, err := s.deviceRepository.GetPushDevices(notification.Username)
+	if err != nil {
+		log.Printf("Failed to load push devices of %s: %v", notification.Username, err)
+		return
+	}
+	for _, device := range devices {
+		provider := s.providers[device.Provider]
+		if provider == nil {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, pushSendTimeout)
+		err := provider.Send(sendCtx, device, notification)
+		cancel()
+		if errors.Is(err, errPushDeviceGone) {
+			log.Printf("Push device %s of %s is gone, removing it", device.ID, device.Username)
+			if _, err := s.deviceRepository.DeletePushDevice(device.Username, device.ID); err != nil {
+				log.Printf("Failed to remove push device %s: %v", device.ID, err)
+			}
+		} else if err != nil {
+			log.Printf("Failed to push to device %s of %s: %v", device.ID, device.Username, err)
+		}
+	}
+}
+
+func (s *pushService) title(notification *models.PushNotification) string {
+	title := notification.Sender
+	if notification.GroupID != "" {
+		name := notification.GroupID
+		if group, err := s.groupRepository.GetGroup(notification.GroupID); err == nil && group != nil {
+			name = group.Name
+		}
+		title = fmt.Sprintf("%s in %s", notification.Sender, name)
+	}
+	if notification.Count > 1 {
+		title = fmt.Sprintf("%s (%d new messages)", title, notification.Count)
+	}
+	return title
+}
+
+func (s *pushService) RegisterDevice(username string, device *models.PushDevice) (*models.PushDevice, error) {
+	if _, enabled := s.providers[device.Provider]; !enabled {
+		return nil, fmt.Errorf("invalid device: provider %q is not enabled", device.Provider)
+	}
+	switch device.Provider {
+	case models.PushProviderWebPush:
+		subscription := device.Subscription
+		if subscription == nil {
+			return nil, errors.New("invalid device: a web push subscription is required")
+		}
+		endpoint, err := url.ParseRequestURI(subscription.Endpoint)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return nil, errors.New("invalid device: the subscription endpoint must be an https url")
+		}
+		if err := checkOutboundURL(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid device: %v", err)
+		}
+		clientKey, err := decodeBase64URL(subscription.Keys.P256dh)
+		if err != nil || len(clientKey) != 65 {
+			return nil, errors.New("invalid device: bad p256dh key")
+		}
+		authSecret, err := decodeBase64URL(subscription.Keys.Auth)
+		if err != nil || len(authSecret) != 16 {
+			return nil, errors.New("invalid device: bad auth secret")
+		}
+		device.Token = ""
+		device.Address = subscription.Endpoint
+	case models.PushProviderHTTP:
+		if device.Token == "" {
+			return nil, errors.New("invalid device: a token is required")
+		}
+		device.Subscription = nil
+		device.Address = device.Token
+	}
+
+	user, err := s.userRepository.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	device.ID = uuid.New().String()
+	device.Username = username
+	device.CreatedAt = time.Now()
+	if err := s.deviceRepository.SavePushDevice(device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (s *pushService) ListDevices(username string) ([]*models.PushDevice, error) {
+	return s.deviceRepository.GetPushDevices(username)
+}
+
+func (s *pushService) DeleteDevice(username, id string) error {
+	deleted, err := s.deviceRepository.DeletePushDevice(username, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("device not found")
+	}
+	return nil
+}
+
+// VAPIDPublicKey is the application server key browsers subscribe with
+func (s *pushService) VAPIDPublicKey() (string, error) {
+	if s.vapidPublicKey == "" {
+		return "", errors.New("web push is not enabled")
+	}
+	return s.vapidPublicKey, nil
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
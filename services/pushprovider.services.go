@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+const (
+	pushSendTimeout = 10 * time.Second
+	// webPushTTL is how long a push service keeps a notification for an unreachable browser
+	webPushTTL = 24 * time.Hour
+	// webPushRecordSize is the aes128gcm record size; a notification always fits in one record
+	webPushRecordSize = 4096
+)
+
+// errPushDeviceGone tells the dispatcher a device no longer exists and should be forgotten
+var errPushDeviceGone = errors.New("push device is gone")
+
+// PushProvider delivers notifications to the devices registered with it
+type PushProvider interface {
+	Name() string
+	Send(ctx context.Context, device *models.PushDevice, notification *models.PushNotification) error
+}
+
+// NewConfiguredPushProviders builds the providers enabled in cfg
+func NewConfiguredPushProviders(cfg configs.PushConfig) ([]PushProvider, error) {
+	providers := []PushProvider{}
+	if cfg.VAPIDPrivateKey != "" {
+		provider, err := newWebPushProvider(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	if cfg.HTTPURL != "" {
+		providers = append(providers, newHTTPPushProvider(cfg.HTTPURL, cfg.HTTPToken))
+	}
+	return providers, nil
+}
+
+// httpPushProvider posts notifications as JSON to a gateway of our own, which relays them
+// to APNs, FCM or anything else. A local stand-in makes it easy to test.
+type httpPushProvider struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newHTTPPushProvider(url, token string) *httpPushProvider {
+	return &httpPushProvider{url: url, token: token, client: &http.Client{Timeout: pushSendTimeout}}
+}
+
+func (p *httpPushProvider) Name() string {
+	return models.PushProviderHTTP
+}
+
+func (p *httpPushProvider) Send(ctx context.Context, device *models.PushDevice, notification *models.PushNotification) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"token":        device.Token,
+		"notification": notification,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushDeviceGone
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("push gateway answered %s", resp.Status)
+	}
+	return nil
+}
+
+// webPushProvider sends encrypted Web Push messages (RFC 8291) authenticated with
+// VAPID (RFC 8292) to the push service behind each browser subscription
+type webPushProvider struct {
+	privateKey *ecdsa.PrivateKey
+	// publicKey is the uncompressed application server key, base64url encoded
+	publicKey string
+	subject   string
+	// client refuses private addresses, since subscription endpoints come from browsers
+	client *http.Client
+}
+
+func newWebPushProvider(publicKey, privateKey, subject string) (*webPushProvider, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return nil, errors.New("invalid VAPID private key: not base64url")
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %v", err)
+	}
+	point := key.PublicKey().Bytes()
+	derived := base64.RawURLEncoding.EncodeToString(point)
+	if publicKey != "" && strings.TrimRight(publicKey, "=") != derived {
+		return nil, errors.New("invalid VAPID keys: the public key does not match the private key")
+	}
+	if subject == "" {
+		return nil, errors.New("invalid VAPID configuration: a mailto: or https: subject is required")
+	}
+	return &webPushProvider{
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(point[1:33]),
+				Y:     new(big.Int).SetBytes(point[33:]),
+			},
+			D: new(big.Int).SetBytes(raw),
+		},
+		publicKey: derived,
+		subject:   subject,
+		client:    newOutboundClient(pushSendTimeout),
+	}, nil
+}
+
+func (p *webPushProvider) Name() string {
+	return models.PushProviderWebPush
+}
+
+func (p *webPushProvider) Send(ctx context.Context, device *models.PushDevice, notification *models.PushNotification) error {
+	if device.Subscription == nil {
+		return errPushDeviceGone
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	body, err := encryptWebPush(device.Subscription, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := p.vapidAuthorization(device.Subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, device.Subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(int(webPushTTL.Seconds())))
+	// Coalesced notifications for a conversation replace each other on the device
+	req.Header.Set("Topic", webPushTopic(notification.Conversation))
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushDeviceGone
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("push service answered %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidAuthorization signs a short lived ES256 JWT for the push service's origin
+func (p *webPushProvider) vapidAuthorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, p.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return "vapid t=" + token + ", k=" + p.publicKey, nil
+}
+
+// encryptWebPush encrypts a payload for a subscription with the aes128gcm content coding
+func encryptWebPush(subscription *models.WebPushSubscription, payload []byte) ([]byte, error) {
+	clientKey, err := decodeBase64URL(subscription.Keys.P256dh)
+	if err != nil {
+		return nil, errors.New("invalid subscription: bad p256dh key")
+	}
+	authSecret, err := decodeBase64URL(subscription.Keys.Auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("invalid subscription: bad auth secret")
+	}
+	clientPublic, err := ecdh.P256().NewPublicKey(clientKey)
+	if err != nil {
+		return nil, errors.New("invalid subscription: bad p256dh key")
+	}
+	serverPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverPrivate.ECDH(clientPublic)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverPrivate.PublicKey().Bytes()
+
+	// RFC 8291 section 3.4: mix the auth secret into the ECDH secret
+	keyInfo := append([]byte("WebPush: info\x00"), clientKey...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdfExpand(hkdfExtract(authSecret, sharedSecret), keyInfo, 32)
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk := hkdfExtract(salt, ikm)
+	contentKey := hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// A single, final record: the payload followed by the 0x02 delimiter
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, errors.New("push payload is too large")
+	}
+
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+func hkdfExtract(salt, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand derives up to one SHA-256 block, which is all web push needs
+func hkdfExpand(prk, info []byte, length int) []byte {
+	mac := hmac.New(sha256.New, prk)
+	mac.Write(info)
+	mac.Write([]byte{0x01})
+	return mac.Sum(nil)[:length]
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	value = strings.TrimRight(value, "=")
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// webPushTopic turns a conversation key into a Topic header: at most 32 URL-safe characters
+func webPushTopic(conversation string) string {
+	sum := sha256.Sum256([]byte(conversation))
+	return base64.RawURLEncoding.EncodeToString(sum[:])[:32]
+}
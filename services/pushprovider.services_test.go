@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+// testHKDF is RFC 5869 HKDF-SHA256 for a single output block, written out separately
+// from the provider's helpers so the round trip does not check them against themselves
+func testHKDF(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(append(append([]byte{}, info...), 1))
+	return expand.Sum(nil)[:length]
+}
+
+// testSubscriber is a browser subscription whose keys the test holds
+type testSubscriber struct {
+	key          *ecdh.PrivateKey
+	auth         []byte
+	subscription *models.WebPushSubscription
+}
+
+func newTestSubscriber(t *testing.T, endpoint string) *testSubscriber {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	subscription := &models.WebPushSubscription{Endpoint: endpoint}
+	subscription.Keys.P256dh = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	subscription.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+	return &testSubscriber{key: key, auth: auth, subscription: subscription}
+}
+
+// decrypt undoes the aes128gcm content coding the way a browser does (RFC 8291)
+func (s *testSubscriber) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("body of %d bytes has no header", len(body))
+	}
+	salt, recordSize, idLength := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if recordSize != webPushRecordSize || idLength != 65 || len(body) < 21+idLength {
+		t.Fatalf("header has record size %d and key id of %d bytes", recordSize, idLength)
+	}
+	serverKey, ciphertext := body[21:21+idLength], body[21+idLength:]
+	serverPublic, err := ecdh.P256().NewPublicKey(serverKey)
+	if err != nil {
+		t.Fatalf("key id is not a P-256 point: %v", err)
+	}
+	shared, err := s.key.ECDH(serverPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := append([]byte("WebPush: info\x00"), s.key.PublicKey().Bytes()...)
+	ikm := testHKDF(s.auth, shared, append(info, serverKey...), 32)
+	contentKey := testHKDF(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := testHKDF(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, _ := aes.NewCipher(contentKey)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypting: %v", err)
+	}
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatal("record does not end with the final record delimiter")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func newTestWebPushProvider(t *testing.T) *webPushProvider {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newWebPushProvider("", base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestWebPushEncryptionRoundTrip(t *testing.T) {
+	subscriber := newTestSubscriber(t, "https://push.example.com/send/1")
+	payload := []byte(`{"title":"alice","body":"hello"}`)
+
+	body, err := encryptWebPush(subscriber.subscription, payload)
+	if err != nil {
+		t.Fatalf("encryptWebPush() = %v", err)
+	}
+	if got := subscriber.decrypt(t, body); string(got) != string(payload) {
+		t.Errorf("decrypted %s, want %s", got, payload)
+	}
+
+	again, _ := encryptWebPush(subscriber.subscription, payload)
+	if string(again[:16]) == string(body[:16]) || string(again[21:86]) == string(body[21:86]) {
+		t.Error("salt or server key was reused between messages")
+	}
+}
+
+func TestWebPushEncryptionRejectsBadSubscriptions(t *testing.T) {
+	subscriber := newTestSubscriber(t, "https://push.example.com/send/1")
+	badKey := *subscriber.subscription
+	badKey.Keys.P256dh = base64.RawURLEncoding.EncodeToString([]byte("not a point"))
+	noAuth := *subscriber.subscription
+	noAuth.Keys.Auth = ""
+
+	for name, subscription := range map[string]*models.WebPushSubscription{"p256dh": &badKey, "auth": &noAuth} {
+		if _, err := encryptWebPush(subscription, []byte("{}")); err == nil {
+			t.Errorf("encryptWebPush() with a bad %s key succeeded", name)
+		}
+	}
+	if _, err := encryptWebPush(subscriber.subscription, make([]byte, webPushRecordSize)); err == nil {
+		t.Error("encryptWebPush() accepted a payload larger than a record")
+	}
+}
+
+func TestWebPushSendIsEncryptedAndSigned(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	p := newTestWebPushProvider(t)
+	p.client = server.Client()
+	subscriber := newTestSubscriber(t, server.URL+"/send/1")
+	notification := &models.PushNotification{Username: "bob", Conversation: "dm:alice:bob", Sender: "alice", Body: "hello", Count: 1}
+
+	err := p.Send(context.Background(), &models.PushDevice{Provider: models.PushProviderWebPush, Subscription: subscriber.subscription}, notification)
+	if err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+
+	req, body := <-requests, <-bodies
+	if req.Header.Get("Content-Encoding") != "aes128gcm" || req.Header.Get("TTL") == "" {
+		t.Errorf("headers = %v, want aes128gcm with a TTL", req.Header)
+	}
+	if topic := req.Header.Get("Topic"); topic != webPushTopic("dm:alice:bob") || len(topic) > 32 {
+		t.Errorf("Topic = %q", topic)
+	}
+	var received models.PushNotification
+	if err := json.Unmarshal(subscriber.decrypt(t, body), &received); err != nil || received.Body != "hello" || received.Sender != "alice" {
+		t.Errorf("decrypted notification = %+v (%v)", received, err)
+	}
+
+	// Authorization: vapid t=<jwt>, k=<public key>
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "vapid "), ", ") {
+		if value, ok := strings.CutPrefix(part, "t="); ok {
+			token = value
+		} else if value, ok := strings.CutPrefix(part, "k="); ok {
+			key = value
+		}
+	}
+	if key != p.publicKey {
+		t.Errorf("k = %q, want the application server key %q", key, p.publicKey)
+	}
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		t.Fatalf("token %q is not a JWT", token)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(segments[2])
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	// Verify with the key the push service is given, not the one the provider holds
+	point, _ := base64.RawURLEncoding.DecodeString(key)
+	if len(point) != 65 {
+		t.Fatalf("k is %d bytes, want an uncompressed P-256 point", len(point))
+	}
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:])}
+	if len(signature) != 64 || !ecdsa.Verify(public, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Error("VAPID token signature does not verify")
+	}
+	rawClaims, _ := base64.RawURLEncoding.DecodeString(segments[1])
+	var claims map[string]interface{}
+	json.Unmarshal(rawClaims, &claims)
+	if claims["aud"] != server.URL || claims["sub"] != "mailto:ops@example.com" {
+		t.Errorf("claims = %v, want aud %s", claims, server.URL)
+	}
+}
+
+func TestWebPushSendReportsGoneSubscriptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+	p := newTestWebPushProvider(t)
+	p.client = server.Client()
+	device := &models.PushDevice{Subscription: newTestSubscriber(t, server.URL).subscription}
+
+	if err := p.Send(context.Background(), device, &models.PushNotification{}); !errors.Is(err, errPushDeviceGone) {
+		t.Errorf("Send() = %v, want %v", err, errPushDeviceGone)
+	}
+}
+
+func TestNewWebPushProviderChecksKeys(t *testing.T) {
+	p := newTestWebPushProvider(t)
+	other := newTestWebPushProvider(t)
+	private := base64.RawURLEncoding.EncodeToString(p.privateKey.D.FillBytes(make([]byte, 32)))
+
+	if _, err := newWebPushProvider(p.publicKey, private, "mailto:ops@example.com"); err != nil {
+		t.Errorf("matching keys refused: %v", err)
+	}
+	if _, err := newWebPushProvider(other.publicKey, private, "mailto:ops@example.com"); err == nil {
+		t.Error("a public key of another private key was accepted")
+	}
+	if _, err := newWebPushProvider("", private, ""); err == nil {
+		t.Error("a missing subject was accepted")
+	}
+}
+
+func TestHTTPPushProvider(t *testing.T) {
+	type received struct {
+		authorization string
+		contentType   string
+		body          map[string]json.RawMessage
+	}
+	requests := make(chan received, 1)
+	var status atomic.Int32
+	status.Store(http.StatusAccepted)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- received{r.Header.Get("Authorization"), r.Header.Get("Content-Type"), body}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+	p := newHTTPPushProvider(server.URL, "gateway-token")
+	device := &models.PushDevice{Provider: models.PushProviderHTTP, Token: "device-token"}
+	notification := &models.PushNotification{Username: "bob", Sender: "alice", Body: "hello", Count: 2}
+
+	if err := p.Send(context.Background(), device, notification); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	req := <-requests
+	if req.authorization != "Bearer gateway-token" || req.contentType != "application/json" {
+		t.Errorf("headers = %q %q", req.authorization, req.contentType)
+	}
+	var sent models.PushNotification
+	json.Unmarshal(req.body["notification"], &sent)
+	if string(req.body["token"]) != `"device-token"` || sent.Body != "hello" || sent.Count != 2 {
+		t.Errorf("body = token %s notification %+v", req.body["token"], sent)
+	}
+
+	for code, want := range map[int]error{http.StatusNotFound: errPushDeviceGone, http.StatusGone: errPushDeviceGone} {
+		status.Store(int32(code))
+		err := p.Send(context.Background(), device, notification)
+		<-requests
+		if !errors.Is(err, want) {
+			t.Errorf("Send() answered %d = %v, want %v", code, err, want)
+		}
+	}
+	status.Store(http.StatusInternalServerError)
+	err := p.Send(context.Background(), device, notification)
+	<-requests
+	if err == nil || errors.Is(err, errPushDeviceGone) {
+		t.Errorf("Send() answered 500 = %v, want a delivery error", err)
+	}
+}
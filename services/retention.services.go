@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	purgeInterval    = time.Hour
+	maxRetentionDays = 100 * 365
+	purgeRunsLimit   = 100
+)
+
+type retentionService struct {
+	retentionRepository database.RetentionRepository
+	groupRepository     database.GroupRepository
+	userRepository      database.UserRepository
+	messageRepository   database.MessageRepository
+	auditService        AuditService
+}
+
+type RetentionService interface {
+	ListPolicies(requester string) ([]*models.RetentionPolicy, error)
+	SetPolicy(groupID string, keepDays int, requester string) (*models.RetentionPolicy, error)
+	DeletePolicy(groupID string, requester string) error
+	ListLegalHolds(requester string) (*models.LegalHolds, error)
+	SetGroupLegalHold(groupID string, hold bool, requester string) error
+	SetUserLegalHold(username string, hold bool, requester string) error
+	ListPurgeRuns(requester string) ([]*models.PurgeRun, error)
+	Purge(requester string) (*models.PurgeRun, error)
+	Run(ctx context.Context)
+}
+
+func NewRetentionService(retentionRepo database.RetentionRepository, groupRepo database.GroupRepository, userRepo database.UserRepository, messageRepo database.MessageRepository, auditService AuditService) RetentionService {
+	return &retentionService{
+		retentionRepository: retentionRepo,
+		groupRepository:     groupRepo,
+		userRepository:      userRepo,
+		messageRepository:   messageRepo,
+		auditService:        auditService,
+	}
+}
+
+func requireSystemAdmin(requester string) error {
+	if !configs.IsSystemAdmin(requester) {
+		return errors.New("unauthorized: only system admins can manage retention")
+	}
+	return nil
+}
+
+func (s *retentionService) ListPolicies(requester string) ([]*models.RetentionPolicy, error) {
+	if err := requireSystemAdmin(requester); err != nil {
+		return nil, err
+	}
+	return s.retentionRepository.GetPolicies()
+}
+
+// SetPolicy creates or replaces the global policy, or a group's policy when groupID is set
+func (s *retentionService) SetPolicy(groupID string, keepDays int, requester string) (*models.RetentionPolicy, error) {
+	if err := requireSystemAdmin(requester); err != nil {
+		return nil, err
+	}
+	if keepDays < 0 || keepDays > maxRetentionDays {
+		return nil, errors.New("invalid retention: keep_days must be between 0 and 36500")
+	}
+	scope := models.RetentionScopeGlobal
+	if groupID != "" {
+		group, err := s.groupRepository.GetGroup(groupID)
+		if err != nil || group == nil {
+			return nil, errors.New("group not found")
+		}
+		scope = models.RetentionScopeGroup
+	}
+	policy := &models.RetentionPolicy{
+		ID:        models.RetentionPolicyID(groupID),
+		Scope:     scope,
+		GroupID:   groupID,
+		KeepDays:  keepDays,
+		UpdatedBy: requester,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.retentionRepository.SavePolicy(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *retentionService) DeletePolicy(groupID string, requester string) error {
+	if err := requireSystemAdmin(requester); err != nil {
+		return err
+	}
+	policy, err := s.retentionRepository.GetPolicy(models.RetentionPolicyID(groupID))
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return errors.New("retention policy not found")
+	}
+	return s.retentionRepository.DeletePolicy(policy.ID)
+}
+
+func (s *retentionService) ListLegalHolds(requester string) (*models.LegalHolds, error) {
+	if err := requireSystemAdmin(requester); err != nil {
+		return nil, err
+	}
+	groups, err := s.groupRepository.GetGroupsOnLegalHold()
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.userRepository.GetUsersOnLegalHold()
+	if err != nil {
+		return nil, err
+	}
+	return &models.LegalHolds{Groups: groups, Users: users}, nil
+}
+
+func (s *retentionService) SetGroupLegalHold(groupID string, hold bool, requester string) error {
+	if err := requireSystemAdmin(requester); err != nil {
+		return err
+	}
+	before, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || before == nil {
+		return errors.New("group not found")
+	}
+	found, err := s.groupRepository.SetGroupLegalHold(groupID, hold)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("group not found")
+	}
+	if hold {
+		// Disappearing messages already sent must not expire while the hold is active
+		if _, err := s.messageRepository.ClearMessageExpiry(groupID, ""); err != nil {
+			return err
+		}
+	}
+	after, err := s.groupRepository.GetGroup(groupID)
+	if err != nil {
+		log.Printf("Failed to reload group %s after legal hold change: %v", groupID, err)
+	}
+	s.auditService.RecordLegalHold(requester, groupID, before.LegalHold, hold, before, after)
+	log.Printf("Legal hold on group %s set to %t by %s", groupID, hold, requester)
+	return nil
+}
+
+func (s *retentionService) SetUserLegalHold(username string, hold bool, requester string) error {
+	if err := requireSystemAdmin(requester); err != nil {
+		return err
+	}
+	user, err := s.userRepository.GetUser(username)
+	if err != nil || user == nil {
+		return errors.New("user not found")
+	}
+	found, err := s.userRepository.SetUserLegalHold(username, hold)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("user not found")
+	}
+	if hold {
+		if _, err := s.messageRepository.ClearMessageExpiry("", username); err != nil {
+			return err
+		}
+	}
+	s.auditService.RecordLegalHold(requester, username, user.LegalHold, hold, nil, nil)
+	log.Printf("Legal hold on user %s set to %t by %s", username, hold, requester)
+	return nil
+}
+
+func (s *retentionService) ListPurgeRuns(requester string) ([]*models.PurgeRun, error) {
+	if err := requireSystemAdmin(requester); err != nil {
+		return nil, err
+	}
+	return s.retentionRepository.GetPurgeRuns(purgeRunsLimit)
+}
+
+func (s *retentionService) Purge(requester string) (*models.PurgeRun, error) {
+	if err := requireSystemAdmin(requester); err != nil {
+		return nil, err
+	}
+	return s.purge(requester)
+}
+
+func (s *retentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.purge("scheduler"); err != nil {
+			log.Printf("Retention purge failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge applies every retention policy and records the run, including failed ones.
+// Groups and users on legal hold are excluded from every policy.
+func (s *retentionService) purge(triggeredBy string) (*models.PurgeRun, error) {
+	run := &models.PurgeRun{
+		ID:          uuid.New().String(),
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+		Policies:    []models.PurgeResult{},
+	}
+	err := s.applyPolicies(run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.FinishedAt = time.Now()
+	if saveErr := s.retentionRepository.SavePurgeRun(run); saveErr != nil {
+		log.Printf("Failed to record purge run %s: %v", run.ID, saveErr)
+	}
+	if err != nil {
+		return run, err
+	}
+	if run.Deleted > 0 {
+		log.Printf("Retention purge %s deleted %d messages", run.ID, run.Deleted)
+	}
+	return run, nil
+}
+
+func (s *retentionService) applyPolicies(run *models.PurgeRun) error {
+	policies, err := s.retentionRepository.GetPolicies()
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+	run.HeldGroups, err = s.groupRepository.GetGroupsOnLegalHold()
+	if err != nil {
+		return err
+	}
+	run.HeldUsers, err = s.userRepository.GetUsersOnLegalHold()
+	if err != nil {
+		return err
+	}
+	heldGroups := make(map[string]bool, len(run.HeldGroups))
+	for _, groupID := range run.HeldGroups {
+		heldGroups[groupID] = true
+	}
+
+	var global *models.RetentionPolicy
+	// Groups with their own policy, or on hold, are left alone by the global policy
+	excludedFromGlobal := append([]string{}, run.HeldGroups...)
+	for _, policy := range policies {
+		if policy.Scope == models.RetentionScopeGlobal {
+			global = policy
+			continue
+		}
+		excludedFromGlobal = append(excludedFromGlobal, policy.GroupID)
+		if heldGroups[policy.GroupID] {
+			continue
+		}
+		s.applyPolicy(run, policy, models.MessagePurgeFilter{
+			GroupID:      policy.GroupID,
+			ExcludeUsers: run.HeldUsers,
+		})
+	}
+	if global != nil {
+		s.applyPolicy(run, global, models.MessagePurgeFilter{
+			ExcludeGroupIDs: excludedFromGlobal,
+			ExcludeUsers:    run.HeldUsers,
+		})
+	}
+	return nil
+}
+
+func (s *retentionService) applyPolicy(run *models.PurgeRun, policy *models.RetentionPolicy, filter models.MessagePurgeFilter) {
+	if policy.KeepDays == 0 {
+		// Keep forever
+		return
+	}
+	filter.Before = run.StartedAt.AddDate(0, 0, -policy.KeepDays)
+	result := models.PurgeResult{
+		PolicyID: policy.ID,
+		KeepDays: policy.KeepDays,
+		Cutoff:   filter.Before,
+	}
+	deleted, err := s.messageRepository.PurgeMessages(filter)
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("Retention policy %s failed: %v", policy.ID, err)
+	}
+	result.Deleted = deleted
+	run.Deleted += deleted
+	run.Policies = append(run.Policies, result)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// fakeGroupRepository stores groups by value so every GetGroup returns a fresh copy,
+// as the database does
+type fakeGroupRepository struct {
+	database.GroupRepository
+	groups map[string]models.Group
+}
+
+func (r *fakeGroupRepository) GetGroup(groupID string) (*models.Group, error) {
+	group, ok := r.groups[groupID]
+	if !ok {
+		return nil, nil
+	}
+	return &group, nil
+}
+
+func (r *fakeGroupRepository) UpdateGroup(group *models.Group) error {
+	r.groups[group.ID] = *group
+	return nil
+}
+
+func (r *fakeGroupRepository) SetGroupLegalHold(groupID string, hold bool) (bool, error) {
+	group, ok := r.groups[groupID]
+	if !ok {
+		return false, nil
+	}
+	group.LegalHold = hold
+	r.groups[groupID] = group
+	return true, nil
+}
+
+type fakeHoldUserRepository struct {
+	database.UserRepository
+	users map[string]models.User
+}
+
+func (r *fakeHoldUserRepository) GetUser(username string) (*models.User, error) {
+	user, ok := r.users[username]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *fakeHoldUserRepository) SetUserLegalHold(username string, hold bool) (bool, error) {
+	user, ok := r.users[username]
+	if !ok {
+		return false, nil
+	}
+	user.LegalHold = hold
+	r.users[username] = user
+	return true, nil
+}
+
+// fakeHoldMessageRepository records the conversations whose message expiry was cleared
+type fakeHoldMessageRepository struct {
+	database.MessageRepository
+	cleared []string
+}
+
+func (r *fakeHoldMessageRepository) ClearMessageExpiry(groupID, username string) (int64, error) {
+	r.cleared = append(r.cleared, groupID+username)
+	return 0, nil
+}
+
+type recordedAudit struct {
+	actor, action, target string
+	wasHeld, held         bool
+	before, after         *models.Group
+}
+
+// fakeAuditService records every entry it is asked to write
+type fakeAuditService struct {
+	AuditService
+	recorded []recordedAudit
+}
+
+func (s *fakeAuditService) Record(actor, action, target string, before, after *models.Group) {
+	s.recorded = append(s.recorded, recordedAudit{actor: actor, action: action, target: target, before: before, after: after})
+}
+
+func (s *fakeAuditService) RecordLegalHold(actor, target string, wasHeld, held bool, before, after *models.Group) {
+	action := models.AuditLegalHoldReleased
+	if held {
+		action = models.AuditLegalHoldSet
+	}
+	s.recorded = append(s.recorded, recordedAudit{actor, action, target, wasHeld, held, before, after})
+}
+
+func newTestRetentionService(t *testing.T) (*retentionService, *fakeGroupRepository, *fakeHoldUserRepository, *fakeHoldMessageRepository, *fakeAuditService) {
+	t.Helper()
+	t.Setenv("SYSTEM_ADMINS", "root")
+	groups := &fakeGroupRepository{groups: map[string]models.Group{"g1": {ID: "g1", Name: "Team", Owner: "alice"}}}
+	users := &fakeHoldUserRepository{users: map[string]models.User{"bob": {Username: "bob"}}}
+	messages := &fakeHoldMessageRepository{}
+	audit := &fakeAuditService{}
+	s := NewRetentionService(nil, groups, users, messages, audit).(*retentionService)
+	return s, groups, users, messages, audit
+}
+
+func TestGroupLegalHoldIsSetReleasedAndAudited(t *testing.T) {
+	s, groups, _, messages, audit := newTestRetentionService(t)
+
+	if err := s.SetGroupLegalHold("g1", true, "root"); err != nil {
+		t.Fatalf("SetGroupLegalHold(true) = %v", err)
+	}
+	if !groups.groups["g1"].LegalHold || len(messages.cleared) != 1 {
+		t.Errorf("hold %v, expiry cleared for %v; want the hold set and expiry cleared", groups.groups["g1"].LegalHold, messages.cleared)
+	}
+	if err := s.SetGroupLegalHold("g1", false, "root"); err != nil {
+		t.Fatalf("SetGroupLegalHold(false) = %v", err)
+	}
+	if groups.groups["g1"].LegalHold || len(messages.cleared) != 1 {
+		t.Errorf("hold %v after release, expiry cleared for %v", groups.groups["g1"].LegalHold, messages.cleared)
+	}
+
+	if len(audit.recorded) != 2 {
+		t.Fatalf("recorded %d audit entries, want 2", len(audit.recorded))
+	}
+	for i, want := range []string{models.AuditLegalHoldSet, models.AuditLegalHoldReleased} {
+		entry := audit.recorded[i]
+		if entry.actor != "root" || entry.action != want || entry.target != "g1" {
+			t.Errorf("entry %d = %s %s %s, want root %s g1", i, entry.actor, entry.action, entry.target, want)
+		}
+		held := want == models.AuditLegalHoldSet
+		if entry.wasHeld == held || entry.held != held || entry.before.LegalHold != entry.wasHeld || entry.after.LegalHold != held {
+			t.Errorf("entry %d records hold %v -> %v, groups %v -> %v", i, entry.wasHeld, entry.held, entry.before.LegalHold, entry.after.LegalHold)
+		}
+	}
+}
+
+func TestUserLegalHoldIsSetReleasedAndAudited(t *testing.T) {
+	s, _, users, messages, audit := newTestRetentionService(t)
+
+	if err := s.SetUserLegalHold("bob", true, "root"); err != nil {
+		t.Fatalf("SetUserLegalHold(true) = %v", err)
+	}
+	if err := s.SetUserLegalHold("bob", false, "root"); err != nil {
+		t.Fatalf("SetUserLegalHold(false) = %v", err)
+	}
+	if users.users["bob"].LegalHold || len(messages.cleared) != 1 {
+		t.Errorf("hold %v after release, expiry cleared for %v", users.users["bob"].LegalHold, messages.cleared)
+	}
+	if len(audit.recorded) != 2 {
+		t.Fatalf("recorded %d audit entries, want 2", len(audit.recorded))
+	}
+	if set := audit.recorded[0]; set.action != models.AuditLegalHoldSet || set.wasHeld || !set.held {
+		t.Errorf("first entry = %+v, want the hold set from false to true", set)
+	}
+	if released := audit.recorded[1]; released.action != models.AuditLegalHoldReleased || !released.wasHeld || released.held {
+		t.Errorf("second entry = %+v, want the hold released from true to false", released)
+	}
+}
+
+func TestLegalHoldRequiresSystemAdmin(t *testing.T) {
+	s, groups, users, _, audit := newTestRetentionService(t)
+
+	if err := s.SetGroupLegalHold("g1", true, "alice"); err == nil {
+		t.Error("the group owner set a legal hold")
+	}
+	if err := s.SetUserLegalHold("bob", true, "bob"); err == nil {
+		t.Error("a user put themselves on legal hold")
+	}
+	if groups.groups["g1"].LegalHold || users.users["bob"].LegalHold || len(audit.recorded) != 0 {
+		t.Error("a refused legal hold changed state or was audited")
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+const (
+	schedulerInterval = 2 * time.Second
+	// A message left in sending for longer than this was claimed by a server that died
+	schedulerClaimTimeout = time.Minute
+)
+
+type scheduledMessageService struct {
+	scheduledRepository database.ScheduledMessageRepository
+	websocketService    WebsocketService
+}
+
+type ScheduledMessageService interface {
+	ListScheduledMessages(sender string) ([]*models.ScheduledMessage, error)
+	UpdateScheduledMessage(id, sender string, content *string, sendAt *time.Time) (*models.ScheduledMessage, error)
+	CancelScheduledMessage(id, sender string) error
+	Run(ctx context.Context)
+}
+
+func NewScheduledMessageService(scheduledRepo database.ScheduledMessageRepository, wsService WebsocketService) ScheduledMessageService {
+	return &scheduledMessageService{
+		scheduledRepository: scheduledRepo,
+		websocketService:    wsService,
+	}
+}
+
+func (s *scheduledMessageService) ListScheduledMessages(sender string) ([]*models.ScheduledMessage, error) {
+	return s.scheduledRepository.GetPendingScheduledMessages(sender)
+}
+
+func (s *scheduledMessageService) UpdateScheduledMessage(id, sender string, content *string, sendAt *time.Time) (*models.ScheduledMessage, error) {
+	message, err := s.getOwnedPending(id, sender)
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		if strings.TrimSpace(*content) == "" {
+			return nil, errors.New("content is required")
+		}
+		message.Content = *content
+	}
+	if sendAt != nil {
+		if !sendAt.After(time.Now()) {
+			return nil, errors.New("send_at must be in the future")
+		}
+		message.SendAt = *sendAt
+	}
+	updated, err := s.scheduledRepository.UpdatePendingScheduledMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("scheduled message already sent or cancelled")
+	}
+	return message, nil
+}
+
+func (s *scheduledMessageService) CancelScheduledMessage(id, sender string) error {
+	message, err := s.getOwnedPending(id, sender)
+	if err != nil {
+		return err
+	}
+	message.Status = models.ScheduledCancelled
+	updated, err := s.scheduledRepository.UpdatePendingScheduledMessage(message)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("scheduled message already sent or cancelled")
+	}
+	return nil
+}
+
+func (s *scheduledMessageService) getOwnedPending(id, sender string) (*models.ScheduledMessage, error) {
+	message, err := s.scheduledRepository.GetScheduledMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.Sender != sender {
+		return nil, errors.New("scheduled message not found")
+	}
+	if message.Status != models.ScheduledPending {
+		return nil, errors.New("scheduled message already sent or cancelled")
+	}
+	return message, nil
+}
+
+// Run delivers due messages until ctx is cancelled. Pending messages live in the
+// database, so anything scheduled before a restart is picked up on the next tick.
+func (s *scheduledMessageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *scheduledMessageService) deliverDue() {
+	if released, err := s.scheduledRepository.ReleaseStaleClaims(time.Now().Add(-schedulerClaimTimeout)); err != nil {
+		log.Printf("Failed to release stale scheduled messages: %v", err)
+	} else if released > 0 {
+		log.Printf("Released %d stale scheduled messages", released)
+	}
+
+	for {
+		scheduled, err := s.scheduledRepository.ClaimDueScheduledMessage(time.Now())
+		if err != nil {
+			log.Printf("Failed to claim scheduled message: %v", err)
+			return
+		}
+		if scheduled == nil {
+			return
+		}
+
+		msg := &models.Message{
+			ID:       scheduled.ID,
+			Type:     "message",
+			Sender:   scheduled.Sender,
+			Receiver: scheduled.Receiver,
+			GroupID:  scheduled.GroupID,
+			Content:  scheduled.Content,
+		}
+		if err := s.websocketService.DispatchMessage(msg); err != nil {
+			log.Printf("Failed to deliver scheduled message %s: %v", scheduled.ID, err)
+		}
+		if err := s.scheduledRepository.MarkScheduledMessageSent(scheduled.ID, time.Now()); err != nil {
+			log.Printf("Failed to mark scheduled message %s as sent: %v", scheduled.ID, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+)
+
+// GetSession returns the live session of username with sessionID
+func (s *websocketService) GetSession(username, sessionID string) *models.Client {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, exists := s.clients[username]
+	if !exists {
+		return nil
+	}
+	return user.sessions[sessionID]
+}
+
+// HandleStreamFrame processes a frame posted by an SSE or long-poll session
+func (s *websocketService) HandleStreamFrame(client *models.Client, frame []byte) error {
+	if len(frame) > maxMessageSize {
+		return errors.New("invalid frame: too large")
+	}
+	if !s.isRegistered(client) {
+		return errors.New("stream session not found")
+	}
+	if err := s.handleFrame(client, frame); err != nil {
+		return errors.New("invalid frame: " + err.Error())
+	}
+	return nil
+}
+
+// PollStream waits up to wait for frames queued for a long-poll session and returns
+// them, giving up early when ctx is done. It reports false once the session has ended.
+func (s *websocketService) PollStream(ctx context.Context, client *models.Client, wait time.Duration) ([]json.RawMessage, bool) {
+	conn, ok := client.Conn.(PollingConnection)
+	if !ok {
+		return []json.RawMessage{}, false
+	}
+	if wait <= 0 || wait > maxPollWait {
+		wait = maxPollWait
+	}
+	return conn.Poll(ctx.Done(), wait)
+}
+
+// CloseStream ends an SSE or long-poll session at the client's request
+func (s *websocketService) CloseStream(client *models.Client) {
+	client.Conn.Close(models.CloseNormal, "Stream closed")
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"errors"
+	"net/url"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
@@ -11,6 +15,7 @@ import (
 type userService struct {
 	userRepository    database.UserRepository
 	messageRepository database.MessageRepository
+	botRepository     database.BotRepository
 	websocketService  WebsocketService
 	mutex             sync.RWMutex
 }
@@ -19,44 +24,147 @@ type UserService interface {
 	GetUser(username string) (*models.User, error)
 	GetAllUsers() ([]*models.User, error)
 	CreateUser(user *models.User) (*models.User, error)
+	UpdateProfile(username string, update *models.UserProfileUpdate) (*models.User, error)
 	ListOnlineUsers() ([]*models.User, error)
 	ListUserGroups(username string) ([]*models.Group, error)
 	GetDirectMessages(sender, receiver string) ([]*models.MessageDB, error)
 }
 
-func NewUserService(userRepo database.UserRepository, messageRepo database.MessageRepository, wsService WebsocketService) UserService {
+func NewUserService(userRepo database.UserRepository, messageRepo database.MessageRepository, botRepo database.BotRepository, wsService WebsocketService) UserService {
 	return &userService{
 		userRepository:    userRepo,
 		messageRepository: messageRepo,
+		botRepository:     botRepo,
 		websocketService:  wsService,
 	}
 }
 
 func (s *userService) GetUser(username string) (*models.User, error) {
-	return s.userRepository.GetUser(username)
+	user, err := s.userRepository.GetUser(username)
+	if err != nil || user == nil {
+		return user, err
+	}
+	user.Status = s.websocketService.GetStatus(user.Username)
+	return user, nil
 }
 
 func (s *userService) GetAllUsers() ([]*models.User, error) {
-	return s.userRepository.GetAllUsers()
+	users, err := s.userRepository.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		user.Status = s.websocketService.GetStatus(user.Username)
+	}
+	return users, nil
 }
 
 func (s *userService) CreateUser(user *models.User) (*models.User, error) {
 	if user.Username == "" {
 		return nil, errors.New("username is required")
 	}
+	// Bots and people share one namespace in groups and messages
+	if bot, err := s.botRepository.GetBot(user.Username); err != nil {
+		return nil, err
+	} else if bot != nil {
+		return nil, errors.New("username is already taken")
+	}
 	return s.userRepository.CreateUser(user)
 }
 
+const (
+	maxDisplayNameLength   = 64
+	maxBioLength           = 500
+	maxStatusMessageLength = 140
+	maxAvatarURLLength     = 2048
+)
+
+func (s *userService) UpdateProfile(username string, update *models.UserProfileUpdate) (*models.User, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if update == nil {
+		return nil, errors.New("invalid profile: nothing to update")
+	}
+	if err := validateProfileUpdate(update); err != nil {
+		return nil, err
+	}
+	trimmed := &models.UserProfileUpdate{
+		DisplayName:   trimmedField(update.DisplayName),
+		AvatarURL:     trimmedField(update.AvatarURL),
+		Bio:           trimmedField(update.Bio),
+		TimeZone:      trimmedField(update.TimeZone),
+		StatusMessage: trimmedField(update.StatusMessage),
+	}
+	user, err := s.userRepository.UpdateProfile(username, trimmed)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	s.websocketService.BroadcastProfileUpdated(user)
+	return user, nil
+}
+
+func trimmedField(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+func validateProfileUpdate(update *models.UserProfileUpdate) error {
+	if update.DisplayName != nil && utf8.RuneCountInString(strings.TrimSpace(*update.DisplayName)) > maxDisplayNameLength {
+		return errors.New("invalid profile: display name is too long")
+	}
+	if update.Bio != nil && utf8.RuneCountInString(strings.TrimSpace(*update.Bio)) > maxBioLength {
+		return errors.New("invalid profile: bio is too long")
+	}
+	if update.StatusMessage != nil && utf8.RuneCountInString(strings.TrimSpace(*update.StatusMessage)) > maxStatusMessageLength {
+		return errors.New("invalid profile: status message is too long")
+	}
+	if update.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*update.AvatarURL)
+		if len(avatarURL) > maxAvatarURLLength {
+			return errors.New("invalid profile: avatar url is too long")
+		}
+		if avatarURL != "" {
+			parsed, err := url.ParseRequestURI(avatarURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return errors.New("invalid profile: avatar url must be an http(s) url")
+			}
+		}
+	}
+	if update.TimeZone != nil {
+		timeZone := strings.TrimSpace(*update.TimeZone)
+		if timeZone != "" {
+			if _, err := time.LoadLocation(timeZone); err != nil {
+				return errors.New("invalid profile: unknown time zone")
+			}
+		}
+	}
+	return nil
+}
+
 func (s *userService) ListOnlineUsers() ([]*models.User, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	onlineUsers := make([]*models.User, 0)
 	for username := range s.websocketService.GetClients() {
+		status := s.websocketService.GetStatus(username)
+		if status == models.StatusOffline {
+			// Invisible users are reported as offline
+			continue
+		}
 		user, err := s.userRepository.GetUser(username)
 		if err != nil || user == nil {
 			continue
 		}
+		user.Status = status
 		onlineUsers = append(onlineUsers, user)
 	}
 	return onlineUsers, nil
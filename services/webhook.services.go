@@ -0,0 +1,402 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/configs"
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookInterval     = 2 * time.Second
+	webhookTimeout      = 10 * time.Second
+	webhookClaimTimeout = time.Minute
+	// webhookWorkers is how many deliveries are sent at the same time
+	webhookWorkers = 8
+	// Retries back off exponentially from webhookBaseBackoff up to webhookMaxBackoff;
+	// a delivery that fails webhookMaxAttempts times is dead-lettered
+	webhookBaseBackoff  = 10 * time.Second
+	webhookMaxBackoff   = time.Hour
+	webhookMaxAttempts  = 8
+	defaultWebhookLimit = 50
+	maxWebhookLimit     = 500
+)
+
+// EventPublisher hands domain events to outbound integrations
+type EventPublisher interface {
+	Publish(event, groupID string, data interface{})
+}
+
+type webhookService struct {
+	webhookRepository database.WebhookRepository
+	groupRepository   database.GroupRepository
+	client            *http.Client
+}
+
+type WebhookService interface {
+	EventPublisher
+	CreateWebhook(groupID, url string, events []string, requester string) (*models.Webhook, error)
+	ListWebhooks(groupID, requester string) ([]*models.Webhook, error)
+	DeleteWebhook(id, requester string) error
+	Ping(id, requester string) (*models.WebhookDelivery, error)
+	ListDeliveries(id, status string, limit int64, requester string) ([]*models.WebhookDelivery, error)
+	Redeliver(id, deliveryID, requester string) error
+	Run(ctx context.Context)
+}
+
+func NewWebhookService(webhookRepo database.WebhookRepository, groupRepo database.GroupRepository) WebhookService {
+	return &webhookService{
+		webhookRepository: webhookRepo,
+		groupRepository:   groupRepo,
+		client:            newOutboundClient(webhookTimeout),
+	}
+}
+
+// authorize lets group moderators manage a group's webhooks and system admins manage every webhook
+func (s *webhookService) authorize(groupID, requester string) error {
+	if groupID == "" {
+		if !configs.IsSystemAdmin(requester) {
+			return errors.New("unauthorized: only system admins can manage global webhooks")
+		}
+		return nil
+	}
+	group, err := s.groupRepository.GetGroup(groupID)
+	if err != nil || group == nil {
+		return errors.New("group not found")
+	}
+	if !isGroupModerator(group, requester) {
+		return errors.New("unauthorized: only owner or admins can manage webhooks")
+	}
+	return nil
+}
+
+func (s *webhookService) CreateWebhook(groupID, target string, events []string, requester string) (*models.Webhook, error) {
+	parsed, err := url.ParseRequestURI(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.New("invalid webhook: url must be an http(s) url")
+	}
+	if err := checkOutboundURL(parsed); err != nil {
+		return nil, fmt.Errorf("invalid webhook: %v", err)
+	}
+	if len(events) == 0 {
+		return nil, errors.New("invalid webhook: at least one event is required")
+	}
+	subscribed := []string{}
+	seen := make(map[string]bool)
+	for _, event := range events {
+		known := false
+		for _, candidate := range models.WebhookEvents {
+			known = known || candidate == event
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid webhook: unknown event %q", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			subscribed = append(subscribed, event)
+		}
+	}
+	if err := s.authorize(groupID, requester); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook := &models.Webhook{
+		ID:        uuid.New().String(),
+		GroupID:   groupID,
+		URL:       target,
+		Events:    subscribed,
+		Secret:    secret,
+		CreatedBy: requester,
+		CreatedAt: time.Now(),
+	}
+	if err := s.webhookRepository.CreateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) ListWebhooks(groupID, requester string) ([]*models.Webhook, error) {
+	if err := s.authorize(groupID, requester); err != nil {
+		return nil, err
+	}
+	webhooks, err := s.webhookRepository.GetWebhooks(groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// getAuthorized loads a webhook the requester may manage
+func (s *webhookService) getAuthorized(id, requester string) (*models.Webhook, error) {
+	webhook, err := s.webhookRepository.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, errors.New("webhook not found")
+	}
+	if err := s.authorize(webhook.GroupID, requester); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) DeleteWebhook(id, requester string) error {
+	if _, err := s.getAuthorized(id, requester); err != nil {
+		return err
+	}
+	return s.webhookRepository.DeleteWebhook(id)
+}
+
+// Ping queues a ping event so an endpoint and its signature check can be tested
+func (s *webhookService) Ping(id, requester string) (*models.WebhookDelivery, error) {
+	webhook, err := s.getAuthorized(id, requester)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(webhook, models.WebhookPing, webhook.GroupID, map[string]string{"webhook_id": webhook.ID})
+}
+
+func (s *webhookService) ListDeliveries(id, status string, limit int64, requester string) ([]*models.WebhookDelivery, error) {
+	if _, err := s.getAuthorized(id, requester); err != nil {
+		return nil, err
+	}
+	if limit < 0 || limit > maxWebhookLimit {
+		return nil, errors.New("invalid webhook query: limit must be between 1 and 500")
+	}
+	if limit == 0 {
+		limit = defaultWebhookLimit
+	}
+	return s.webhookRepository.GetDeliveries(id, status, limit)
+}
+
+// Redeliver moves a dead-lettered delivery back to the queue
+func (s *webhookService) Redeliver(id, deliveryID, requester string) error {
+	if _, err := s.getAuthorized(id, requester); err != nil {
+		return err
+	}
+	delivery, err := s.webhookRepository.GetDelivery(deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery == nil || delivery.WebhookID != id {
+		return errors.New("delivery not found")
+	}
+	requeued, err := s.webhookRepository.RequeueDelivery(deliveryID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !requeued {
+		return errors.New("only dead deliveries can be redelivered")
+	}
+	return nil
+}
+
+// Publish queues an event for every subscribed webhook. Failures are logged, publishing
+// never holds up the action that produced the event.
+func (s *webhookService) Publish(event, groupID string, data interface{}) {
+	webhooks, err := s.webhookRepository.GetSubscribedWebhooks(groupID, event)
+	if err != nil {
+		log.Printf("Failed to look up webhooks for %s: %v", event, err)
+		return
+	}
+	for _, webhook := range webhooks {
+		if _, err := s.enqueue(webhook, event, groupID, data); err != nil {
+			log.Printf("Failed to queue %s for webhook %s: %v", event, webhook.ID, err)
+		}
+	}
+}
+
+func (s *webhookService) enqueue(webhook *models.Webhook, event, groupID string, data interface{}) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:         uuid.New().String(),
+		Type:       event,
+		GroupID:    groupID,
+		OccurredAt: now,
+		Data:       data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhook.ID,
+		Event:         event,
+		Payload:       string(payload),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: now,
+		Attempts:      []models.WebhookAttempt{},
+		CreatedAt:     now,
+	}
+	if err := s.webhookRepository.EnqueueDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *webhookService) deliverDue(ctx context.Context) {
+	if released, err := s.webhookRepository.ReleaseStaleDeliveries(time.Now().Add(-webhookClaimTimeout)); err != nil {
+		log.Printf("Failed to release stale webhook deliveries: %v", err)
+	} else if released > 0 {
+		log.Printf("Released %d stale webhook deliveries", released)
+	}
+
+	// A slow endpoint only holds up one worker
+	claimed := make(chan *models.WebhookDelivery)
+	var workers sync.WaitGroup
+	for i := 0; i < webhookWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for delivery := range claimed {
+				s.attempt(ctx, delivery)
+			}
+		}()
+	}
+	defer func() {
+		close(claimed)
+		workers.Wait()
+	}()
+
+	for ctx.Err() == nil {
+		delivery, err := s.webhookRepository.ClaimDueDelivery(time.Now())
+		if err != nil {
+			log.Printf("Failed to claim webhook delivery: %v", err)
+			return
+		}
+		if delivery == nil {
+			return
+		}
+		claimed <- delivery
+	}
+}
+
+// attempt sends a delivery once and schedules the next try when it fails
+func (s *webhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	webhook, err := s.webhookRepository.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		log.Printf("Failed to load webhook %s: %v", delivery.WebhookID, err)
+		return
+	}
+
+	started := time.Now()
+	attempt := models.WebhookAttempt{AttemptedAt: started}
+	if webhook == nil {
+		attempt.Error = "webhook was deleted"
+	} else {
+		attempt.StatusCode, err = s.send(ctx, webhook, delivery)
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	}
+	attempt.DurationMs = time.Since(started).Milliseconds()
+
+	status := models.WebhookDeliveryDelivered
+	nextAttemptAt := started
+	if attempt.Error != "" {
+		attempts := delivery.AttemptCount + 1
+		if webhook == nil || attempts >= webhookMaxAttempts {
+			status = models.WebhookDeliveryDead
+			log.Printf("Webhook delivery %s dead-lettered after %d attempts: %s", delivery.ID, attempts, attempt.Error)
+		} else {
+			status = models.WebhookDeliveryPending
+			nextAttemptAt = started.Add(webhookBackoff(attempts))
+		}
+	}
+	if err := s.webhookRepository.RecordDeliveryAttempt(delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		log.Printf("Failed to record attempt of webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// send posts the payload with its signature. The signature is the hex HMAC-SHA256, keyed
+// with the webhook secret, of the timestamp header, a dot and the raw body.
+func (s *webhookService) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", webhook.ID)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// signWebhookPayload returns the X-Webhook-Signature header value for a payload sent at timestamp
+func signWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
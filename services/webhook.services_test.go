@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+// fakeWebhookRepository keeps webhooks and deliveries in memory. Only the methods the
+// delivery loop uses are implemented.
+type fakeWebhookRepository struct {
+	database.WebhookRepository
+	mutex      sync.Mutex
+	webhooks   map[string]*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (r *fakeWebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetSubscribedWebhooks(groupID, event string) ([]*models.Webhook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	subscribed := []*models.Webhook{}
+	for _, webhook := range r.webhooks {
+		if webhook.GroupID != "" && webhook.GroupID != groupID {
+			continue
+		}
+		for _, candidate := range webhook.Events {
+			if candidate == event {
+				subscribed = append(subscribed, webhook)
+			}
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *fakeWebhookRepository) EnqueueDelivery(delivery *models.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.deliveries = append(r.deliveries, delivery)
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetWebhook(id string) (*models.Webhook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.webhooks[id], nil
+}
+
+func (r *fakeWebhookRepository) ReleaseStaleDeliveries(claimedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeWebhookRepository) ClaimDueDelivery(now time.Time) (*models.WebhookDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.Status = models.WebhookDeliverySending
+			claimed := *delivery
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeWebhookRepository) RecordDeliveryAttempt(id string, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, delivery := range r.deliveries {
+		if delivery.ID == id {
+			delivery.Attempts = append(delivery.Attempts, attempt)
+			delivery.AttemptCount++
+			delivery.Status = status
+			delivery.NextAttemptAt = nextAttemptAt
+			return nil
+		}
+	}
+	return errors.New("delivery not found")
+}
+
+func (r *fakeWebhookRepository) delivery(id string) models.WebhookDelivery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, delivery := range r.deliveries {
+		if delivery.ID == id {
+			return *delivery
+		}
+	}
+	return models.WebhookDelivery{}
+}
+
+// allowTestServers lets outbound clients reach the loopback address test servers listen on
+func allowTestServers(t *testing.T) {
+	t.Setenv("OUTBOUND_ALLOWED_NETWORKS", "127.0.0.0/8, ::1/128")
+}
+
+// newTestWebhookService queues one delivery for a webhook posting to target
+func newTestWebhookService(t *testing.T, target string, attemptCount int) (*webhookService, *fakeWebhookRepository) {
+	allowTestServers(t)
+	webhook := &models.Webhook{ID: "hook-1", URL: target, Events: []string{models.WebhookMessagePosted}, Secret: "s3cret"}
+	repo := &fakeWebhookRepository{
+		webhooks: map[string]*models.Webhook{webhook.ID: webhook},
+		deliveries: []*models.WebhookDelivery{{
+			ID:            "delivery-1",
+			WebhookID:     webhook.ID,
+			Event:         models.WebhookMessagePosted,
+			Payload:       `{"type":"message.posted"}`,
+			Status:        models.WebhookDeliveryPending,
+			AttemptCount:  attemptCount,
+			NextAttemptAt: time.Now().Add(-time.Second),
+		}},
+	}
+	return NewWebhookService(repo, nil).(*webhookService), repo
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	s, repo := newTestWebhookService(t, server.URL, 0)
+
+	s.deliverDue(context.Background())
+
+	received, body := (<-requests).Header, <-bodies
+	if body != `{"type":"message.posted"}` {
+		t.Errorf("body = %s, want the queued payload", body)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(received.Get("X-Webhook-Timestamp") + "." + body))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); received.Get("X-Webhook-Signature") != want {
+		t.Errorf("signature = %s, want %s", received.Get("X-Webhook-Signature"), want)
+	}
+	if received.Get("X-Webhook-Event") != models.WebhookMessagePosted || received.Get("X-Webhook-Delivery") != "delivery-1" {
+		t.Errorf("event headers = %s %s", received.Get("X-Webhook-Event"), received.Get("X-Webhook-Delivery"))
+	}
+	delivery := repo.delivery("delivery-1")
+	if delivery.Status != models.WebhookDeliveryDelivered {
+		t.Errorf("status = %s, want %s", delivery.Status, models.WebhookDeliveryDelivered)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusNoContent {
+		t.Errorf("attempts = %+v, want one answered with 204", delivery.Attempts)
+	}
+}
+
+func TestWebhookFailureIsRetriedWithBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	s, repo := newTestWebhookService(t, server.URL, 2)
+
+	started := time.Now()
+	s.deliverDue(context.Background())
+
+	delivery := repo.delivery("delivery-1")
+	if delivery.Status != models.WebhookDeliveryPending {
+		t.Fatalf("status = %s, want %s", delivery.Status, models.WebhookDeliveryPending)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusServiceUnavailable || delivery.Attempts[0].Error == "" {
+		t.Errorf("attempts = %+v, want one failed with 503", delivery.Attempts)
+	}
+	// The third attempt failed, so the fourth waits four base backoffs
+	wait := delivery.NextAttemptAt.Sub(started)
+	if want := 4 * webhookBaseBackoff; wait < want || wait > want+time.Second {
+		t.Errorf("next attempt in %s, want %s", wait, want)
+	}
+}
+
+func TestWebhookIsDeadLetteredAfterMaxAttempts(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	s, repo := newTestWebhookService(t, server.URL, webhookMaxAttempts-1)
+
+	s.deliverDue(context.Background())
+	s.deliverDue(context.Background())
+
+	if delivery := repo.delivery("delivery-1"); delivery.Status != models.WebhookDeliveryDead {
+		t.Errorf("status = %s, want %s", delivery.Status, models.WebhookDeliveryDead)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("endpoint got %d requests, want 1", got)
+	}
+}
+
+func TestWebhookDeliveryOfDeletedWebhookIsDeadLettered(t *testing.T) {
+	s, repo := newTestWebhookService(t, "http://example.invalid", 0)
+	delete(repo.webhooks, "hook-1")
+
+	s.deliverDue(context.Background())
+
+	delivery := repo.delivery("delivery-1")
+	if delivery.Status != models.WebhookDeliveryDead || len(delivery.Attempts) != 1 || delivery.Attempts[0].Error == "" {
+		t.Errorf("delivery = %+v, want it dead-lettered with an error", delivery)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  webhookBaseBackoff,
+		2:  2 * webhookBaseBackoff,
+		3:  4 * webhookBaseBackoff,
+		20: webhookMaxBackoff,
+	} {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestOutboundURLsMustBePublic(t *testing.T) {
+	for target, public := range map[string]bool{
+		"http://127.0.0.1:8080/hook":            false,
+		"http://[::1]/hook":                     false,
+		"http://169.254.169.254/latest/meta":    false,
+		"http://10.1.2.3/hook":                  false,
+		"http://172.16.0.1/hook":                false,
+		"http://192.168.1.10/hook":              false,
+		"http://0.0.0.0/hook":                   false,
+		"https://93.184.216.34/hook":            true,
+		"https://[2606:2800:220:1::248]/status": true,
+	} {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkOutboundURL(parsed); (err == nil) != public {
+			t.Errorf("checkOutboundURL(%s) = %v, want public %v", target, err, public)
+		}
+	}
+}
+
+func TestPublishedEventReachesAWebhookOnAnAllowedNetwork(t *testing.T) {
+	t.Setenv("SYSTEM_ADMINS", "root")
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	repo := &fakeWebhookRepository{webhooks: map[string]*models.Webhook{}}
+	s := NewWebhookService(repo, nil).(*webhookService)
+
+	if _, err := s.CreateWebhook("", server.URL, []string{models.WebhookMemberKicked}, "root"); err == nil {
+		t.Fatalf("CreateWebhook() on loopback = %v, want it refused", err)
+	}
+	allowTestServers(t)
+	webhook, err := s.CreateWebhook("", server.URL, []string{models.WebhookMemberKicked}, "root")
+	if err != nil {
+		t.Fatalf("CreateWebhook() on an allowed network = %v", err)
+	}
+
+	s.Publish(models.WebhookMemberKicked, "g1", map[string]string{"username": "bob"})
+	s.deliverDue(context.Background())
+
+	select {
+	case req := <-requests:
+		if req.Header.Get("X-Webhook-Event") != models.WebhookMemberKicked {
+			t.Errorf("event = %s, want %s", req.Header.Get("X-Webhook-Event"), models.WebhookMemberKicked)
+		}
+	default:
+		t.Fatal("the webhook endpoint was not called")
+	}
+	if len(repo.deliveries) != 1 || repo.deliveries[0].WebhookID != webhook.ID || repo.deliveries[0].Status != models.WebhookDeliveryDelivered {
+		t.Errorf("deliveries = %+v, want one delivered", repo.deliveries)
+	}
+}
+
+func TestOutboundClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the outbound client reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := newOutboundClient(time.Second).Get(server.URL)
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("Get(%s) = %v, want %v", server.URL, err, errPrivateAddress)
+	}
+}
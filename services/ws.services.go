@@ -1,15 +1,19 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/JomnoiZ/network-backend-group-13.git/models"
 	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 )
 
 const (
@@ -18,122 +22,414 @@ const (
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 10000
 	sendTimeout    = 3 * time.Second
+
+	idleTimeout       = 5 * time.Minute
+	idleCheckInterval = 30 * time.Second
+
+	// Clients are told to reconnect after shutdownReconnectDelay plus a random part of
+	// shutdownReconnectJitter, so they do not all come back at the same moment
+	shutdownReconnectDelay  = 2 * time.Second
+	shutdownReconnectJitter = 8 * time.Second
 )
 
 type WebsocketService interface {
-	HandleConnection(username string, conn *websocket.Conn)
-	GetClients() map[string]*models.Client
+	HandleConnection(username, deviceID string, singleSession bool, conn models.Connection) *models.Client
+	HandleBotConnection(bot *models.Bot, deviceID string, conn models.Connection) *models.Client
+	GetSession(username, sessionID string) *models.Client
+	HandleStreamFrame(client *models.Client, frame []byte) error
+	PollStream(ctx context.Context, client *models.Client, wait time.Duration) ([]json.RawMessage, bool)
+	CloseStream(client *models.Client)
+	GetClients() map[string][]*models.Client
+	GetStatus(username string) string
+	IsConnected(username string) bool
 	AddToGroup(client *models.Client, groupID string)
 	KickFromGroup(username string, groupID string)
 	NotifyGroupUpdate(groupID string, updateType string, data interface{})
 	BroadcastStatus(username string, status string)
 	BroadcastGroupCreated(username string, groupID string)
+	BroadcastProfileUpdated(user *models.User)
+	InvalidateAudience(usernames ...string)
+	SendToUser(username string, message models.Message)
+	DispatchMessage(msg *models.Message) error
+	BroadcastMessageDeleted(message *models.MessageDB, reason string)
+	DisconnectUser(username string, reason string)
+	BlockSessions(username string)
+	UnblockSessions(username string)
+	SetCommandExecutor(commands CommandExecutor)
+	IsShuttingDown() bool
+	Shutdown(ctx context.Context) error
+}
+
+// connectedUser holds every live session of a username.
+// Group subscriptions and the chosen presence are shared by all of them.
+type connectedUser struct {
+	sessions map[string]*models.Client
+	groups   map[string]bool
+	status   string
+	idle     bool
+	bot      bool
 }
 
 type websocketService struct {
-	clients     map[string]*models.Client
-	groups      map[string]map[string]*models.Client
-	mutex       sync.RWMutex
-	messageRepo database.MessageRepository
+	clients       map[string]*connectedUser
+	groups        map[string]map[string]bool
+	mutex         sync.RWMutex
+	messageRepo   database.MessageRepository
+	userRepo      database.UserRepository
+	contactRepo   database.ContactRepository
+	scheduledRepo database.ScheduledMessageRepository
+	groupRepo     database.GroupRepository
+	settingsRepo  database.ConversationSettingsRepository
+	moderator     MessageModerator
+	publisher     EventPublisher
+	commands      CommandExecutor
+	notifications NotificationPolicy
+	push          PushNotifier
+
+	// shuttingDown refuses new sessions and commands, guarded by mutex. pumps counts the
+	// running read pumps, which finish handling their current frame before exiting, and
+	// the running slash commands.
+	shuttingDown bool
+	pumps        sync.WaitGroup
+	// blocked holds the users whose new sessions are refused, guarded by mutex
+	blocked map[string]bool
+	// stop ends the background loops started by NewWebsocketService
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// audiences caches, for each connected user, who may observe their presence
+	audiences     map[string]map[string]bool
+	audienceMutex sync.RWMutex
 }
 
-func NewWebsocketService(messageRepo database.MessageRepository) WebsocketService {
-	return &websocketService{
-		clients:     make(map[string]*models.Client),
-		groups:      make(map[string]map[string]*models.Client),
-		messageRepo: messageRepo,
+func NewWebsocketService(messageRepo database.MessageRepository, userRepo database.UserRepository, contactRepo database.ContactRepository, scheduledRepo database.ScheduledMessageRepository, groupRepo database.GroupRepository, settingsRepo database.ConversationSettingsRepository, moderator MessageModerator, publisher EventPublisher, notifications NotificationPolicy, push PushNotifier) WebsocketService {
+	s := &websocketService{
+		clients:       make(map[string]*connectedUser),
+		groups:        make(map[string]map[string]bool),
+		messageRepo:   messageRepo,
+		userRepo:      userRepo,
+		contactRepo:   contactRepo,
+		scheduledRepo: scheduledRepo,
+		groupRepo:     groupRepo,
+		settingsRepo:  settingsRepo,
+		moderator:     moderator,
+		publisher:     publisher,
+		notifications: notifications,
+		push:          push,
+		audiences:     make(map[string]map[string]bool),
+		blocked:       make(map[string]bool),
+		stop:          make(chan struct{}),
 	}
+	go s.monitorIdleClients()
+	return s
 }
 
-func (s *websocketService) HandleConnection(username string, conn *websocket.Conn) {
+// HandleConnection registers a new session for username. A user may be connected from
+// several devices at once; a session from the same device, or every other session when
+// singleSession is requested, is replaced by the new one.
+func (s *websocketService) HandleConnection(username, deviceID string, singleSession bool, conn models.Connection) *models.Client {
 	// Validate inputs
 	if username == "" || conn == nil {
 		log.Printf("Invalid HandleConnection parameters: username=%s, conn=%v", username, conn)
 		if conn != nil {
-			conn.Close()
+			conn.Close(models.CloseNormal, "")
 		}
-		return
+		return nil
+	}
+
+	client := newClient(username, deviceID, false, conn)
+	if !s.connect(client, singleSession) {
+		return nil
+	}
+	return client
+}
+
+// HandleBotConnection connects an authenticated bot. Bots are subscribed to every group
+// they belong to straight away instead of joining groups one by one.
+func (s *websocketService) HandleBotConnection(bot *models.Bot, deviceID string, conn models.Connection) *models.Client {
+	if bot == nil || conn == nil {
+		log.Printf("Invalid HandleBotConnection parameters: bot=%v, conn=%v", bot, conn)
+		if conn != nil {
+			conn.Close(models.CloseNormal, "")
+		}
+		return nil
 	}
 
-	client := &models.Client{
-		Username: username,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		Groups:   make(map[string]bool),
+	client := newClient(bot.Username, deviceID, true, conn)
+	if !s.connect(client, false) {
+		return nil
 	}
 
+	groups, err := s.userRepo.GetUserGroups(bot.Username)
+	if err != nil {
+		log.Printf("Failed to load groups of bot %s: %v", bot.Username, err)
+		return client
+	}
+	for _, group := range groups {
+		s.AddToGroup(client, group.ID)
+	}
+	return client
+}
+
+func newClient(username, deviceID string, bot bool, conn models.Connection) *models.Client {
+	sessionID := uuid.New().String()
+	if deviceID == "" {
+		deviceID = sessionID
+	}
+	return &models.Client{
+		Username:     username,
+		SessionID:    sessionID,
+		DeviceID:     deviceID,
+		Bot:          bot,
+		Transport:    conn.Transport(),
+		Conn:         conn,
+		Send:         make(chan []byte, 256),
+		LastActivity: time.Now(),
+		Closing:      make(chan struct{}),
+	}
+}
+
+// connect registers a new session and starts its pumps. It refuses the session once the
+// server is shutting down or while the user is blocked.
+func (s *websocketService) connect(client *models.Client, singleSession bool) bool {
+	username, sessionID, deviceID := client.Username, client.SessionID, client.DeviceID
+
 	s.mutex.Lock()
-	if oldClient, exists := s.clients[username]; exists && oldClient.Conn != nil {
-		log.Printf("Replacing existing session for user %s", username)
-		// Prepare session_replaced message
+	if s.shuttingDown {
+		s.mutex.Unlock()
+		client.Conn.Close(models.CloseGoingAway, "Server shutting down")
+		return false
+	}
+	if s.blocked[username] {
+		s.mutex.Unlock()
+		client.Conn.Close(models.CloseNormal, "Sessions are not accepted for this user")
+		return false
+	}
+	user, wasOnline := s.clients[username]
+	if !wasOnline {
+		user = &connectedUser{
+			sessions: make(map[string]*models.Client),
+			groups:   make(map[string]bool),
+			status:   models.StatusOnline,
+			bot:      client.Bot,
+		}
+		s.clients[username] = user
+	}
+	replaced := []*models.Client{}
+	for id, existing := range user.sessions {
+		if singleSession || existing.DeviceID == deviceID {
+			replaced = append(replaced, existing)
+			// Mark old client as replaced to prevent further operations
+			delete(user.sessions, id)
+		}
+	}
+	// Register new client
+	user.sessions[sessionID] = client
+	user.idle = false
+	s.pumps.Add(1)
+	s.mutex.Unlock()
+
+	for _, oldClient := range replaced {
+		s.replaceSession(oldClient)
+	}
+
+	// Start read and write pumps
+	go s.writePump(client)
+	go s.readPump(client)
+
+	session := models.Message{
+		Type:   "session",
+		Sender: username,
+		Data: map[string]string{
+			"session_id": sessionID,
+			"device_id":  deviceID,
+		},
+	}
+	if messageJSON, err := json.Marshal(session); err == nil {
+		s.sendMessage(client, messageJSON)
+	}
+
+	if !wasOnline {
+		s.recordLastSeen(username)
+		s.BroadcastStatus(username, models.StatusOnline)
+	}
+	log.Printf("User %s connected via %s (session %s, device %s)", username, client.Transport, sessionID, deviceID)
+	return true
+}
+
+// replaceSession tells a session it was superseded and closes its connection.
+// The session must already be removed from the clients map.
+func (s *websocketService) replaceSession(oldClient *models.Client) {
+	username := oldClient.Username
+	log.Printf("Replacing session %s for user %s", oldClient.SessionID, username)
+	// Prepare session_replaced message
+	message := models.Message{
+		Type:    "session_replaced",
+		Sender:  username,
+		Content: "Your session was replaced by a new login",
+	}
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal session_replaced message for %s: %v", username, err)
+	}
+	// writePump sends the message, then closes the old connection
+	oldClient.RequestClose(models.CloseNormal, "Session replaced", messageJSON)
+}
+
+// DisconnectUser tells every session of username why it is ending and closes it once
+// writePump has delivered that frame. readPump then unregisters each session and
+// announces the user as offline.
+func (s *websocketService) DisconnectUser(username string, reason string) {
+	message := models.Message{
+		Type:    "disconnected",
+		Sender:  username,
+		Content: reason,
+	}
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal disconnected message for %s: %v", username, err)
+		return
+	}
+	for _, client := range s.sessionsOf(username) {
+		client.RequestClose(models.CloseNormal, reason, messageJSON)
+	}
+	log.Printf("Disconnected user %s: %s", username, reason)
+}
+
+// BlockSessions refuses new sessions of username until UnblockSessions is called.
+// Existing sessions are left alone; pair it with DisconnectUser to end them.
+func (s *websocketService) BlockSessions(username string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.blocked[username] = true
+}
+
+func (s *websocketService) UnblockSessions(username string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.blocked, username)
+}
+
+func (s *websocketService) IsShuttingDown() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.shuttingDown
+}
+
+// Shutdown stops accepting sessions and background work, then asks every session to
+// send what is queued, tell the client to reconnect later and close. It returns once every
+// read pump has finished the frame it was handling and every running command has answered,
+// so no message is lost half saved. When ctx is done first, the remaining connections are
+// closed at once and ctx.Err() is returned.
+func (s *websocketService) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.shuttingDown = true
+	sessions := []*models.Client{}
+	for _, user := range s.clients {
+		for _, client := range user.sessions {
+			sessions = append(sessions, client)
+		}
+	}
+	s.mutex.Unlock()
+	s.stopBackground()
+	log.Printf("Shutting down %d sessions", len(sessions))
+
+	for _, client := range sessions {
+		delay := shutdownReconnectDelay + time.Duration(rand.Int63n(int64(shutdownReconnectJitter)))
 		message := models.Message{
-			Type:    "session_replaced",
-			Sender:  username,
-			Content: "Your session was replaced by a new login",
+			Type:    "server_shutdown",
+			Content: "The server is restarting, please reconnect",
+			Data:    map[string]int64{"reconnect_after_ms": delay.Milliseconds()},
 		}
 		messageJSON, err := json.Marshal(message)
 		if err != nil {
-			log.Printf("Failed to marshal session_replaced message for %s: %v", username, err)
-		} else {
-			// Send message to old client with a timeout
-			select {
-			case oldClient.Send <- messageJSON:
-				log.Printf("Sent session_replaced message to old client %s", username)
-			case <-time.After(sendTimeout):
-				log.Printf("Timeout sending session_replaced message to old client %s", username)
-			}
+			log.Printf("Failed to marshal server_shutdown message for %s: %v", client.Username, err)
 		}
+		client.RequestClose(models.CloseGoingAway, "Server shutting down", messageJSON)
+	}
 
-		// Send close message to old client
-		if err := oldClient.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-			log.Printf("Failed to set write deadline for old client %s: %v", username, err)
-		}
-		if err := oldClient.Conn.WriteMessage(
-			websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Session replaced"),
-		); err != nil {
-			log.Printf("Failed to send close message to old client %s: %v", username, err)
+	done := make(chan struct{})
+	go func() {
+		s.pumps.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, client := range sessions {
+			client.Conn.Close(models.CloseGoingAway, "Server shutting down")
 		}
+		return ctx.Err()
+	}
+}
 
-		// Close old connection (but not the Send channel yet)
-		if err := oldClient.Conn.Close(); err != nil {
-			log.Printf("Error closing old connection for %s: %v", username, err)
+func (s *websocketService) GetClients() map[string][]*models.Client {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	clients := make(map[string][]*models.Client, len(s.clients))
+	for username, user := range s.clients {
+		for _, client := range user.sessions {
+			clients[username] = append(clients[username], client)
 		}
+	}
+	return clients
+}
 
-		// Remove old client from groups
-		for groupID := range oldClient.Groups {
-			if groupClients, exists := s.groups[groupID]; exists {
-				delete(groupClients, username)
-				if len(groupClients) == 0 {
-					delete(s.groups, groupID)
-					log.Printf("Removed empty group %s", groupID)
-				}
-			}
-		}
+// GetStatus returns the presence of a user as other users should see it
+func (s *websocketService) GetStatus(username string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, exists := s.clients[username]
+	if !exists {
+		return models.StatusOffline
+	}
+	return publicStatus(effectiveStatus(user))
+}
 
-		// Mark old client as replaced to prevent further operations
-		delete(s.clients, username)
+// effectiveStatus folds idle detection into the status chosen by the user
+func effectiveStatus(user *connectedUser) string {
+	if user.status == models.StatusOnline && user.idle {
+		return models.StatusAway
 	}
-	// Register new client
-	s.clients[username] = client
-	s.mutex.Unlock()
+	return user.status
+}
 
-	// Start read and write pumps
-	go s.writePump(client)
-	go s.readPump(client)
+func publicStatus(status string) string {
+	if status == models.StatusInvisible {
+		return models.StatusOffline
+	}
+	return status
+}
 
-	s.BroadcastStatus(username, "online")
-	log.Printf("User %s connected via WebSocket", username)
+// sessionsOf returns the live sessions of every given user
+func (s *websocketService) sessionsOf(usernames ...string) []*models.Client {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sessions := []*models.Client{}
+	for _, username := range usernames {
+		if user, exists := s.clients[username]; exists {
+			for _, client := range user.sessions {
+				sessions = append(sessions, client)
+			}
+		}
+	}
+	return sessions
 }
 
-func (s *websocketService) GetClients() map[string]*models.Client {
+// groupSessions returns the live sessions of every user subscribed to a group
+func (s *websocketService) groupSessions(groupID string) []*models.Client {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
-	clients := make(map[string]*models.Client, len(s.clients))
-	for k, v := range s.clients {
-		clients[k] = v
+	sessions := []*models.Client{}
+	for username := range s.groups[groupID] {
+		if user, exists := s.clients[username]; exists {
+			for _, client := range user.sessions {
+				sessions = append(sessions, client)
+			}
+		}
 	}
-	return clients
+	return sessions
 }
 
 func (s *websocketService) AddToGroup(client *models.Client, groupID string) {
@@ -143,12 +439,12 @@ func (s *websocketService) AddToGroup(client *models.Client, groupID string) {
 	}
 
 	s.mutex.Lock()
-	if actualClient, exists := s.clients[client.Username]; exists {
+	if user, exists := s.clients[client.Username]; exists {
 		if s.groups[groupID] == nil {
-			s.groups[groupID] = make(map[string]*models.Client)
+			s.groups[groupID] = make(map[string]bool)
 		}
-		s.groups[groupID][client.Username] = actualClient
-		actualClient.Groups[groupID] = true
+		s.groups[groupID][client.Username] = true
+		user.groups[groupID] = true
 		log.Printf("Added user %s to group %s", client.Username, groupID)
 	} else {
 		log.Printf("Client %s not found in clients map for group %s", client.Username, groupID)
@@ -168,29 +464,27 @@ func (s *websocketService) KickFromGroup(username string, groupID string) {
 		}
 	}
 
-	var kickedClient *models.Client
-	if client, exists := s.clients[username]; exists {
-		delete(client.Groups, groupID)
-		kickedClient = client
+	if user, exists := s.clients[username]; exists {
+		delete(user.groups, groupID)
 	}
 	s.mutex.Unlock()
 
 	s.NotifyGroupUpdate(groupID, "kick", map[string]string{"username": username})
 
-	if kickedClient != nil {
-		message := models.Message{
-			Type:    "group_update",
-			GroupID: groupID,
-			Data: map[string]interface{}{
-				"type": "kick",
-				"data": map[string]string{"username": username},
-			},
-		}
-		if messageJSON, err := json.Marshal(message); err == nil {
-			s.sendMessage(kickedClient, messageJSON)
-		} else {
-			log.Printf("Failed to marshal kick message for %s: %v", username, err)
+	message := models.Message{
+		Type:    "group_update",
+		GroupID: groupID,
+		Data: map[string]interface{}{
+			"type": "kick",
+			"data": map[string]string{"username": username},
+		},
+	}
+	if messageJSON, err := json.Marshal(message); err == nil {
+		for _, client := range s.sessionsOf(username) {
+			s.sendMessage(client, messageJSON)
 		}
+	} else {
+		log.Printf("Failed to marshal kick message for %s: %v", username, err)
 	}
 }
 
@@ -210,12 +504,9 @@ func (s *websocketService) NotifyGroupUpdate(groupID string, updateType string,
 		return
 	}
 
-	s.mutex.RLock()
-	groupClients, exists := s.groups[groupID]
-	s.mutex.RUnlock()
-
-	if exists {
-		for _, client := range groupClients {
+	sessions := s.groupSessions(groupID)
+	if len(sessions) > 0 {
+		for _, client := range sessions {
 			s.sendMessage(client, messageJSON)
 		}
 		log.Printf("Notified group %s of update type %s", groupID, updateType)
@@ -224,6 +515,18 @@ func (s *websocketService) NotifyGroupUpdate(groupID string, updateType string,
 	}
 }
 
+// SendToUser delivers a message to every connected device of username
+func (s *websocketService) SendToUser(username string, message models.Message) {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s message for %s: %v", message.Type, username, err)
+		return
+	}
+	for _, client := range s.sessionsOf(username) {
+		s.sendMessage(client, messageJSON)
+	}
+}
+
 func (s *websocketService) BroadcastGroupCreated(username string, groupID string) {
 	message := models.Message{
 		Type:    "group_created",
@@ -237,22 +540,27 @@ func (s *websocketService) BroadcastGroupCreated(username string, groupID string
 		return
 	}
 
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	for _, client := range s.clients {
-		if client.Username != username {
-			s.sendMessage(client, messageJSON)
-		}
-	}
+	s.broadcastToAudience(username, messageJSON, false)
 	log.Printf("Broadcasted group created for user %s for group %s", username, groupID)
 }
 
 func (s *websocketService) BroadcastStatus(username string, status string) {
+	s.mutex.RLock()
+	user, exists := s.clients[username]
+	bot := exists && user.bot
+	s.mutex.RUnlock()
+	s.broadcastStatus(username, status, bot)
+}
+
+func (s *websocketService) broadcastStatus(username string, status string, bot bool) {
 	message := models.Message{
 		Type:   "status",
 		Sender: username,
-		Status: status,
+		Status: publicStatus(status),
+		Bot:    bot,
+	}
+	if message.Status == models.StatusOffline {
+		message.Data = map[string]interface{}{"last_seen": time.Now()}
 	}
 
 	messageJSON, err := json.Marshal(message)
@@ -261,91 +569,368 @@ func (s *websocketService) BroadcastStatus(username string, status string) {
 		return
 	}
 
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	s.broadcastToAudience(username, messageJSON, false)
+	log.Printf("Broadcasted status %s for user %s", message.Status, username)
+}
 
-	for _, client := range s.clients {
-		if client.Username != username {
-			s.sendMessage(client, messageJSON)
+func (s *websocketService) handlePresence(client *models.Client, msg *models.Message) {
+	if !models.IsSelectableStatus(msg.Status) {
+		log.Printf("Invalid presence status %q from %s", msg.Status, client.Username)
+		return
+	}
+
+	s.mutex.Lock()
+	user, exists := s.clients[client.Username]
+	if !exists {
+		s.mutex.Unlock()
+		return
+	}
+	previous := publicStatus(effectiveStatus(user))
+	user.status = msg.Status
+	user.idle = false
+	current := effectiveStatus(user)
+	s.mutex.Unlock()
+
+	// Echo the chosen status to every device so they can confirm it, even when invisible
+	message := models.Message{
+		Type:   "status",
+		Sender: client.Username,
+		Status: current,
+	}
+	if messageJSON, err := json.Marshal(message); err == nil {
+		for _, session := range s.sessionsOf(client.Username) {
+			s.sendMessage(session, messageJSON)
 		}
 	}
-	log.Printf("Broadcasted status %s for user %s", status, username)
+
+	if publicStatus(current) != previous {
+		s.BroadcastStatus(client.Username, current)
+	}
 }
 
-func (s *websocketService) readPump(client *models.Client) {
-	if client == nil || client.Conn == nil || client.Username == "" || client.Send == nil || client.Groups == nil {
-		log.Printf("Invalid client state in readPump: %+v", client)
+// touchClient records activity and brings an idle user back from automatic away
+func (s *websocketService) touchClient(client *models.Client) {
+	s.mutex.Lock()
+	client.LastActivity = time.Now()
+	user, exists := s.clients[client.Username]
+	if !exists {
+		s.mutex.Unlock()
 		return
 	}
+	wasIdle := user.idle
+	user.idle = false
+	status := effectiveStatus(user)
+	s.mutex.Unlock()
 
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Recovered panic in readPump for user %s: %v", client.Username, r)
-		}
+	if wasIdle && status == models.StatusOnline {
+		s.BroadcastStatus(client.Username, status)
+	}
+}
+
+// stopBackground ends monitorIdleClients; it is safe to call more than once
+func (s *websocketService) stopBackground() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// monitorIdleClients marks users away once none of their devices has been active for idleTimeout
+func (s *websocketService) monitorIdleClients() {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
 
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		idleUsers := []string{}
 		s.mutex.Lock()
-		if actualClient, exists := s.clients[client.Username]; exists && actualClient == client {
-			delete(s.clients, client.Username)
-			for groupID := range client.Groups {
-				if groupClients, exists := s.groups[groupID]; exists {
-					delete(groupClients, client.Username)
-					if len(groupClients) == 0 {
-						delete(s.groups, groupID)
-						log.Printf("Removed empty group %s", groupID)
-					}
+		for username, user := range s.clients {
+			if user.idle {
+				continue
+			}
+			var lastActivity time.Time
+			for _, client := range user.sessions {
+				if client.LastActivity.After(lastActivity) {
+					lastActivity = client.LastActivity
 				}
 			}
+			if time.Since(lastActivity) < idleTimeout {
+				continue
+			}
+			user.idle = true
+			if user.status == models.StatusOnline {
+				idleUsers = append(idleUsers, username)
+			}
 		}
 		s.mutex.Unlock()
 
-		if client.Conn != nil {
-			client.Conn.Close()
+		for _, username := range idleUsers {
+			s.BroadcastStatus(username, models.StatusAway)
 		}
-		if client.Send != nil {
-			close(client.Send) // Close Send channel here
+	}
+}
+
+func (s *websocketService) recordLastSeen(username string) {
+	if s.userRepo == nil {
+		return
+	}
+	if err := s.userRepo.UpdateLastSeen(username, time.Now()); err != nil {
+		log.Printf("Failed to record last seen for %s: %v", username, err)
+	}
+}
+
+func (s *websocketService) BroadcastProfileUpdated(user *models.User) {
+	if user == nil || user.Username == "" {
+		return
+	}
+	message := models.Message{
+		Type:   "profile_updated",
+		Sender: user.Username,
+		Data:   user.PublicProfile(),
+	}
+
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal profile update for %s: %v", user.Username, err)
+		return
+	}
+
+	s.broadcastToAudience(user.Username, messageJSON, true)
+	log.Printf("Broadcasted profile update for user %s", user.Username)
+}
+
+// broadcastToAudience sends a message to the connected users related to username.
+// Only users sharing a group, a direct conversation or a contact are reached, so a
+// single event costs O(audience) instead of O(connected clients).
+func (s *websocketService) broadcastToAudience(username string, messageJSON []byte, includeSelf bool) {
+	audience := s.presenceAudience(username)
+
+	s.mutex.RLock()
+	recipients := []*models.Client{}
+	for member := range audience {
+		if user, exists := s.clients[member]; exists {
+			for _, client := range user.sessions {
+				recipients = append(recipients, client)
+			}
 		}
-		if client.Username != "" {
-			s.BroadcastStatus(client.Username, "offline")
+	}
+	if self, exists := s.clients[username]; exists && includeSelf {
+		for _, client := range self.sessions {
+			recipients = append(recipients, client)
 		}
-		log.Printf("readPump terminated for user %s", client.Username)
-	}()
+	}
+	s.mutex.RUnlock()
 
-	client.Conn.SetReadLimit(maxMessageSize)
-	client.Conn.SetReadDeadline(time.Now().Add(pongWait))
-	client.Conn.SetPongHandler(func(string) error {
-		client.Conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
+	for _, client := range recipients {
+		s.sendMessage(client, messageJSON)
+	}
+}
+
+// presenceAudience returns the users who share a group or a direct conversation with username,
+// or have them as a contact, minus anyone on either side of a block.
+// The result is cached while the user is connected and must not be modified by callers.
+func (s *websocketService) presenceAudience(username string) map[string]bool {
+	s.audienceMutex.RLock()
+	audience, cached := s.audiences[username]
+	s.audienceMutex.RUnlock()
+	if cached {
+		return audience
+	}
+
+	audience, err := s.loadAudience(username)
+	if err != nil {
+		log.Printf("Failed to load presence audience for %s: %v", username, err)
+		return audience
+	}
+
+	s.mutex.RLock()
+	_, connected := s.clients[username]
+	s.mutex.RUnlock()
+	if connected {
+		s.audienceMutex.Lock()
+		s.audiences[username] = audience
+		s.audienceMutex.Unlock()
+	}
+	return audience
+}
+
+func (s *websocketService) loadAudience(username string) (map[string]bool, error) {
+	audience := make(map[string]bool)
+	if s.userRepo != nil {
+		groups, err := s.userRepo.GetUserGroups(username)
+		if err != nil {
+			return audience, err
+		}
+		for _, group := range groups {
+			for _, member := range group.Members {
+				audience[member] = true
+			}
+		}
+	}
+	partners, err := s.messageRepo.GetDirectMessagePartners(username)
+	if err != nil {
+		return audience, err
+	}
+	for _, partner := range partners {
+		audience[partner] = true
+	}
+	if s.contactRepo != nil {
+		contacts, err := s.contactRepo.GetContacts(username)
+		if err != nil {
+			return audience, err
+		}
+		for _, contact := range contacts {
+			audience[contact.Contact] = true
+		}
+		// Blocking hides presence in both directions
+		blocked, err := s.contactRepo.GetBlockedUsers(username)
+		if err != nil {
+			return audience, err
+		}
+		for _, block := range blocked {
+			delete(audience, block.Blocked)
+		}
+		blockers, err := s.contactRepo.GetBlockers(username)
+		if err != nil {
+			return audience, err
+		}
+		for _, blocker := range blockers {
+			delete(audience, blocker)
+		}
+	}
+	delete(audience, username)
+	return audience, nil
+}
+
+// InvalidateAudience drops cached audiences after group membership changes
+func (s *websocketService) InvalidateAudience(usernames ...string) {
+	s.audienceMutex.Lock()
+	defer s.audienceMutex.Unlock()
+	for _, username := range usernames {
+		delete(s.audiences, username)
+	}
+}
+
+// linkAudience records a new direct conversation in both cached audiences
+func (s *websocketService) linkAudience(a, b string) {
+	if a == b {
+		return
+	}
+	s.audienceMutex.Lock()
+	defer s.audienceMutex.Unlock()
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		audience, cached := s.audiences[pair[0]]
+		if !cached || audience[pair[1]] {
+			continue
+		}
+		// Copy on write, readers may still hold the previous map
+		updated := make(map[string]bool, len(audience)+1)
+		for member := range audience {
+			updated[member] = true
+		}
+		updated[pair[1]] = true
+		s.audiences[pair[0]] = updated
+	}
+}
+
+func (s *websocketService) readPump(client *models.Client) {
+	defer s.pumps.Done()
+	if client == nil || client.Conn == nil || client.Username == "" || client.Send == nil {
+		log.Printf("Invalid client state in readPump: %+v", client)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered panic in readPump for user %s: %v", client.Username, r)
+		}
+		client.Conn.Close(models.CloseNormal, "")
+		s.unregister(client)
+		log.Printf("readPump terminated for user %s (session %s)", client.Username, client.SessionID)
+	}()
 
 	for {
-		_, message, err := client.Conn.ReadMessage()
+		message, err := client.Conn.ReadFrame()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if err != io.EOF {
 				log.Printf("Read error for user %s: %v", client.Username, err)
 			}
 			return
 		}
 
-		var msg models.Message
-		if err := json.Unmarshal(message, &msg); err != nil {
+		if err := s.handleFrame(client, message); err != nil {
 			log.Printf("Failed to unmarshal message for user %s: %v", client.Username, err)
-			continue
 		}
+	}
+}
 
-		msg.Sender = client.Username
+// handleFrame processes one frame sent by a client, whatever its transport
+func (s *websocketService) handleFrame(client *models.Client, frame []byte) error {
+	var msg models.Message
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return err
+	}
 
-		switch msg.Type {
-		case "message":
-			s.handleChatMessage(client, &msg)
-		case "typing":
-			s.handleTypingStatus(client, &msg)
-		case "join_group":
-			if msg.GroupID != "" {
-				s.AddToGroup(client, msg.GroupID)
-				log.Printf("User %s joined group %s", client.Username, msg.GroupID)
+	msg.Sender = client.Username
+	msg.Bot = client.Bot
+	s.touchClient(client)
+
+	switch msg.Type {
+	case "message":
+		s.handleChatMessage(client, &msg)
+	case "typing":
+		s.handleTypingStatus(client, &msg)
+	case "presence":
+		s.handlePresence(client, &msg)
+	case "join_group":
+		if msg.GroupID != "" && s.isBannedFromGroup(msg.GroupID, client.Username) {
+			s.sendJoinRejected(client, msg.GroupID, "you are banned from this group")
+		} else if msg.GroupID != "" && client.Bot && !s.isGroupMember(msg.GroupID, client.Username) {
+			s.sendJoinRejected(client, msg.GroupID, "bots can only join groups they were added to")
+		} else if msg.GroupID != "" {
+			s.AddToGroup(client, msg.GroupID)
+			log.Printf("User %s joined group %s", client.Username, msg.GroupID)
+		}
+	}
+	return nil
+}
+
+// unregister removes a session, closes its Send channel and announces the user as
+// offline when it was their last session
+func (s *websocketService) unregister(client *models.Client) {
+	lastSession := false
+	s.mutex.Lock()
+	if user, exists := s.clients[client.Username]; exists {
+		if actualClient, exists := user.sessions[client.SessionID]; exists && actualClient == client {
+			delete(user.sessions, client.SessionID)
+		}
+		if len(user.sessions) == 0 {
+			lastSession = true
+			delete(s.clients, client.Username)
+			for groupID := range user.groups {
+				if groupClients, exists := s.groups[groupID]; exists {
+					delete(groupClients, client.Username)
+					if len(groupClients) == 0 {
+						delete(s.groups, groupID)
+						log.Printf("Removed empty group %s", groupID)
+					}
+				}
 			}
 		}
 	}
+	s.mutex.Unlock()
+
+	client.CloseSend()
+	// The user stays online while another device is still connected. The cached audience
+	// is dropped only after the offline status went out, so it is not loaded again.
+	if lastSession {
+		s.recordLastSeen(client.Username)
+		s.broadcastStatus(client.Username, models.StatusOffline, client.Bot)
+		s.InvalidateAudience(client.Username)
+	}
 }
 
 func (s *websocketService) writePump(client *models.Client) {
@@ -357,24 +942,15 @@ func (s *websocketService) writePump(client *models.Client) {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
-		if client.Conn != nil {
-			client.Conn.Close()
-		}
+		client.Conn.Close(models.CloseNormal, "")
 		// Do not close Send channel here; let readPump or HandleConnection handle it
-		log.Printf("writePump terminated for user %s", client.Username)
+		log.Printf("writePump terminated for user %s (session %s)", client.Username, client.SessionID)
 	}()
 
 	for {
 		select {
 		case message, ok := <-client.Send:
 			if !ok {
-				client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := client.Conn.WriteMessage(
-					websocket.CloseMessage,
-					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
-				); err != nil {
-					log.Printf("Error sending close message for %s: %v", client.Username, err)
-				}
 				return
 			}
 
@@ -382,23 +958,25 @@ func (s *websocketService) writePump(client *models.Client) {
 				continue
 			}
 
-			// Check if client is still valid
-			s.mutex.RLock()
-			actualClient, exists := s.clients[client.Username]
-			s.mutex.RUnlock()
-			if !exists || actualClient != client {
-				log.Printf("Client %s is no longer valid, stopping writePump", client.Username)
+			// Check if client is still valid; a replaced session still gets its farewell
+			if !s.isRegistered(client) {
+				if client.CloseRequested() {
+					s.flushAndClose(client)
+					return
+				}
+				log.Printf("Client %s (session %s) is no longer valid, stopping writePump", client.Username, client.SessionID)
 				return
 			}
 
-			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := client.Conn.WriteFrame(message); err != nil {
 				log.Printf("Write error for user %s: %v", client.Username, err)
 				return
 			}
+		case <-client.Closing:
+			s.flushAndClose(client)
+			return
 		case <-ticker.C:
-			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := client.Conn.Ping(); err != nil {
 				log.Printf("Ping error for user %s: %v", client.Username, err)
 				return
 			}
@@ -406,26 +984,66 @@ func (s *websocketService) writePump(client *models.Client) {
 	}
 }
 
+// flushAndClose writes the frames already queued for a session and its farewell, then
+// closes its connection as asked by RequestClose
+func (s *websocketService) flushAndClose(client *models.Client) {
+	frames := [][]byte{}
+drain:
+	for {
+		select {
+		case message, ok := <-client.Send:
+			if !ok {
+				break drain
+			}
+			frames = append(frames, message)
+		default:
+			break drain
+		}
+	}
+	frames = append(frames, client.Farewell)
+
+	for _, frame := range frames {
+		if len(frame) == 0 {
+			continue
+		}
+		if err := client.Conn.WriteFrame(frame); err != nil {
+			log.Printf("Write error for user %s: %v", client.Username, err)
+			break
+		}
+	}
+	if err := client.Conn.Close(client.CloseCode, client.CloseReason); err != nil {
+		log.Printf("Error closing connection for %s (session %s): %v", client.Username, client.SessionID, err)
+	}
+}
+
+// isRegistered reports whether the session is still live and has not been replaced
+func (s *websocketService) isRegistered(client *models.Client) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, exists := s.clients[client.Username]
+	if !exists {
+		return false
+	}
+	actualClient, exists := user.sessions[client.SessionID]
+	return exists && actualClient == client
+}
+
 func (s *websocketService) sendMessage(client *models.Client, message []byte) {
-	if client == nil || client.Send == nil || client.Conn == nil {
+	if client == nil || client.Send == nil {
 		log.Printf("Cannot send message: nil client or invalid state")
 		return
 	}
 
-	s.mutex.RLock()
-	actualClient, exists := s.clients[client.Username]
-	s.mutex.RUnlock()
-
-	if !exists || actualClient != client {
-		log.Printf("Cannot send message: client %s not found or replaced", client.Username)
+	if !s.isRegistered(client) {
+		log.Printf("Cannot send message: client %s (session %s) not found or replaced", client.Username, client.SessionID)
 		return
 	}
 
 	select {
 	case client.Send <- message:
-		log.Printf("Message sent to client %s", client.Username)
+		log.Printf("Message sent to client %s (session %s)", client.Username, client.SessionID)
 	case <-time.After(sendTimeout):
-		log.Printf("Timeout sending to client %s", client.Username)
+		log.Printf("Timeout sending to client %s (session %s)", client.Username, client.SessionID)
 	}
 }
 
@@ -439,46 +1057,413 @@ func (s *websocketService) handleChatMessage(client *models.Client, msg *models.
 		return
 	}
 
-	messageJSON, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal message from %s: %v", client.Username, err)
+	if invocation := parseCommand(msg); invocation != nil && s.commands != nil {
+		// Commands may call out to hooks, so they must not hold up this connection's reads
+		s.startCommand(client, msg, invocation)
 		return
 	}
+	// "//" escapes a message that should start with a slash
+	if strings.HasPrefix(msg.Content, "//") {
+		msg.Content = msg.Content[1:]
+	}
+
+	// Bots post right away, scheduling is for people
+	if msg.SendAt != nil && msg.SendAt.After(time.Now()) && !client.Bot {
+		s.scheduleMessage(msg)
+		return
+	}
+	msg.SendAt = nil
 
-	if msg.GroupID != "" || msg.Receiver != "" {
-		dbMsg := &models.MessageDB{
-			ID:        msg.ID,
-			Sender:    msg.Sender,
-			Receiver:  msg.Receiver,
-			GroupID:   msg.GroupID,
-			Content:   msg.Content,
-			Timestamp: time.Now(),
+	if err := s.DispatchMessage(msg); err != nil {
+		log.Printf("Failed to dispatch message from %s: %v", client.Username, err)
+	}
+}
+
+// groupMentions resolves the mentions of a group message against its members
+func (s *websocketService) groupMentions(msg *models.Message) *models.Mentions {
+	if s.groupRepo == nil {
+		return nil
+	}
+	group, err := s.groupRepo.GetGroup(msg.GroupID)
+	if err != nil || group == nil {
+		return nil
+	}
+	return parseMentions(msg.Content, group, msg.Sender)
+}
+
+// notifyMentions sends a mention frame to every device of the mentioned users, whether
+// or not they are subscribed to the group. Users who blocked the sender or whose
+// notification settings silence the group are skipped.
+func (s *websocketService) notifyMentions(msg *models.Message) {
+	if msg.Mentions == nil || len(msg.Mentions.Notified) == 0 {
+		return
+	}
+	mention := models.Message{
+		Type:     "mention",
+		ID:       msg.ID,
+		Sender:   msg.Sender,
+		GroupID:  msg.GroupID,
+		Content:  msg.Content,
+		Bot:      msg.Bot,
+		Mentions: msg.Mentions,
+	}
+	messageJSON, err := json.Marshal(mention)
+	if err != nil {
+		log.Printf("Failed to marshal mention of message %s: %v", msg.ID, err)
+		return
+	}
+	blockers := s.blockersOf(msg.Sender)
+	conversation := models.ConversationKey(models.ConversationGroup, msg.GroupID)
+	for _, username := range msg.Mentions.Notified {
+		if blockers[username] {
+			continue
+		}
+		if s.notifications != nil && !s.notifications.ShouldNotify(username, conversation, true) {
+			continue
 		}
-		if err := s.messageRepo.SaveMessage(dbMsg); err != nil {
-			log.Printf("Failed to save message to database: %v", err)
+		for _, c := range s.sessionsOf(username) {
+			s.sendMessage(c, messageJSON)
 		}
 	}
+}
 
+// IsConnected reports whether username has at least one live session
+func (s *websocketService) IsConnected(username string) bool {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
+	_, exists := s.clients[username]
+	return exists
+}
 
-	if msg.GroupID != "" {
-		if groupClients, exists := s.groups[msg.GroupID]; exists {
-			for _, c := range groupClients {
+// pushToOfflineMembers hands a group message to the push dispatcher for every member
+// without a live session, except the sender and members who blocked them
+func (s *websocketService) pushToOfflineMembers(msg *models.Message) {
+	if s.push == nil || s.groupRepo == nil {
+		return
+	}
+	group, err := s.groupRepo.GetGroup(msg.GroupID)
+	if err != nil || group == nil {
+		return
+	}
+	mentioned := make(map[string]bool)
+	if msg.Mentions != nil {
+		for _, username := range msg.Mentions.Notified {
+			mentioned[username] = true
+		}
+	}
+	blockers := s.blockersOf(msg.Sender)
+	for _, member := range group.Members {
+		if member == msg.Sender || blockers[member] || s.IsConnected(member) {
+			continue
+		}
+		s.push.Notify(member, msg, mentioned[member])
+	}
+}
+
+// SetCommandExecutor installs the slash command handler. It is set after construction
+// because the commands are built on services that need the websocket service.
+func (s *websocketService) SetCommandExecutor(commands CommandExecutor) {
+	s.commands = commands
+}
+
+// startCommand runs a slash command in the background, counted in pumps so shutdown
+// waits for its answer. Commands arriving during shutdown are dropped.
+func (s *websocketService) startCommand(client *models.Client, msg *models.Message, invocation *models.CommandInvocation) {
+	s.mutex.Lock()
+	if s.shuttingDown {
+		s.mutex.Unlock()
+		log.Printf("Dropped command /%s from %s: server is shutting down", invocation.Command, client.Username)
+		return
+	}
+	s.pumps.Add(1)
+	s.mutex.Unlock()
+
+	go func() {
+		defer s.pumps.Done()
+		s.runCommand(client, msg, invocation)
+	}()
+}
+
+// runCommand executes a slash command and answers only the session that typed it
+func (s *websocketService) runCommand(client *models.Client, msg *models.Message, invocation *models.CommandInvocation) {
+	reply := s.commands.Execute(invocation)
+	response := models.Message{
+		Type:     "command_response",
+		ID:       msg.ID,
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+		GroupID:  msg.GroupID,
+		Content:  reply,
+		Data:     map[string]string{"command": invocation.Command},
+	}
+	if messageJSON, err := json.Marshal(response); err == nil {
+		s.sendMessage(client, messageJSON)
+	}
+}
+
+// DispatchMessage persists a chat message and fans it out to every recipient device.
+// Live messages and deliveries from the scheduler both go through here.
+func (s *websocketService) DispatchMessage(msg *models.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.Sender == "" || msg.Content == "" || (msg.GroupID == "" && msg.Receiver == "") {
+		return errors.New("invalid message: empty content or no recipient")
+	}
+	msg.Type = "message"
+
+	// Direct messages to someone who blocked the sender are silently dropped:
+	// the sender gets the usual echo but nothing is stored or delivered.
+	if msg.GroupID == "" && s.isBlocked(msg.Receiver, msg.Sender) {
+		if messageJSON, err := json.Marshal(msg); err == nil {
+			for _, c := range s.sessionsOf(msg.Sender) {
 				s.sendMessage(c, messageJSON)
 			}
 		}
-		return
+		log.Printf("Dropped direct message from %s to %s: sender is blocked", msg.Sender, msg.Receiver)
+		return nil
+	}
+
+	if msg.Bot && msg.GroupID == "" {
+		s.rejectMessage(msg, "bots can only post in groups")
+		return nil
+	}
+	if msg.GroupID != "" {
+		if reason := s.groupRestriction(msg.GroupID, msg.Sender, msg.Bot); reason != "" {
+			s.rejectMessage(msg, reason)
+			return nil
+		}
+	}
+
+	if s.moderator != nil {
+		verdict := s.moderator.Moderate(msg)
+		if verdict.Action == models.ModerationReject {
+			s.rejectMessage(msg, verdict.Reason)
+			return nil
+		}
+		msg.Content = verdict.Content
+	}
+
+	if msg.GroupID != "" {
+		msg.Mentions = s.groupMentions(msg)
+	}
+
+	now := time.Now()
+	if ttl := s.messageTTL(msg); ttl > 0 {
+		expiresAt := now.Add(time.Duration(ttl) * time.Second)
+		msg.ExpiresAt = &expiresAt
+	}
+
+	messageJSON, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	dbMsg := &models.MessageDB{
+		ID:        msg.ID,
+		Sender:    msg.Sender,
+		Receiver:  msg.Receiver,
+		GroupID:   msg.GroupID,
+		Content:   msg.Content,
+		Bot:       msg.Bot,
+		Mentions:  msg.Mentions,
+		Timestamp: now,
+		ExpiresAt: msg.ExpiresAt,
+	}
+	if err := s.messageRepo.SaveMessage(dbMsg); err != nil {
+		log.Printf("Failed to save message to database: %v", err)
+	}
+
+	if msg.GroupID != "" {
+		for _, c := range s.groupSessions(msg.GroupID) {
+			s.sendMessage(c, messageJSON)
+		}
+		s.notifyMentions(msg)
+		s.pushToOfflineMembers(msg)
+		// Only group messages reach webhooks, direct messages stay private
+		if s.publisher != nil {
+			s.publisher.Publish(models.WebhookMessagePosted, msg.GroupID, dbMsg)
+		}
+		return nil
+	}
+
+	s.linkAudience(msg.Sender, msg.Receiver)
+	// Every device of both participants receives the message
+	recipients := []string{msg.Sender}
+	if msg.Receiver != msg.Sender {
+		recipients = append(recipients, msg.Receiver)
+	}
+	for _, c := range s.sessionsOf(recipients...) {
+		s.sendMessage(c, messageJSON)
+	}
+	if s.push != nil && msg.Receiver != msg.Sender && !s.IsConnected(msg.Receiver) {
+		s.push.Notify(msg.Receiver, msg, false)
+	}
+	return nil
+}
+
+func (s *websocketService) sendJoinRejected(client *models.Client, groupID, reason string) {
+	message := models.Message{
+		Type:    "join_rejected",
+		GroupID: groupID,
+		Status:  reason,
 	}
+	if messageJSON, err := json.Marshal(message); err == nil {
+		s.sendMessage(client, messageJSON)
+	}
+	log.Printf("Refused %s joining group %s: %s", client.Username, groupID, reason)
+}
+
+// rejectMessage tells only the sender that a message was not delivered
+func (s *websocketService) rejectMessage(msg *models.Message, reason string) {
+	s.SendToUser(msg.Sender, models.Message{
+		Type:     "message_rejected",
+		ID:       msg.ID,
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+		GroupID:  msg.GroupID,
+		Content:  msg.Content,
+		Status:   reason,
+	})
+	log.Printf("Rejected message %s from %s: %s", msg.ID, msg.Sender, reason)
+}
 
-	if msg.Receiver != "" {
-		if receiver, exists := s.clients[msg.Receiver]; exists && receiver.Username != msg.Sender {
-			s.sendMessage(receiver, messageJSON)
+// groupRestriction explains why username may not post in a group, or returns an empty
+// string when they may. Lookup failures fail open.
+func (s *websocketService) groupRestriction(groupID, username string, bot bool) string {
+	if s.groupRepo == nil {
+		return ""
+	}
+	group, err := s.groupRepo.GetGroup(groupID)
+	if err != nil || group == nil {
+		return ""
+	}
+	now := time.Now()
+	if bot && !group.IsMember(username) {
+		return "bots can only post in groups they were added to"
+	}
+	if group.IsBanned(username, now) {
+		return "you are banned from this group"
+	}
+	if group.IsMuted(username, now) {
+		return "you are muted in this group"
+	}
+	return ""
+}
+
+// isBannedFromGroup reports whether username is banned from a group; lookup failures fail open
+func (s *websocketService) isBannedFromGroup(groupID, username string) bool {
+	if s.groupRepo == nil {
+		return false
+	}
+	group, err := s.groupRepo.GetGroup(groupID)
+	if err != nil || group == nil {
+		return false
+	}
+	return group.IsBanned(username, time.Now())
+}
+
+// isGroupMember reports whether username belongs to a group
+func (s *websocketService) isGroupMember(groupID, username string) bool {
+	if s.groupRepo == nil {
+		return false
+	}
+	group, err := s.groupRepo.GetGroup(groupID)
+	if err != nil || group == nil {
+		return false
+	}
+	return group.IsMember(username)
+}
+
+// messageTTL returns the retention in seconds configured on the message's conversation,
+// or zero when the conversation or one of its participants is on legal hold
+func (s *websocketService) messageTTL(msg *models.Message) int64 {
+	if msg.GroupID != "" {
+		if s.groupRepo == nil {
+			return 0
+		}
+		group, err := s.groupRepo.GetGroup(msg.GroupID)
+		if err != nil || group == nil || group.LegalHold || group.MessageTTL == 0 {
+			return 0
+		}
+		// A sender on legal hold keeps their group messages too
+		if sender, err := s.userRepo.GetUser(msg.Sender); err != nil || (sender != nil && sender.LegalHold) {
+			return 0
 		}
+		return group.MessageTTL
+	}
+	if s.settingsRepo == nil {
+		return 0
+	}
+	settings, err := s.settingsRepo.GetDirectSettings(msg.Sender, msg.Receiver)
+	if err != nil || settings == nil || settings.MessageTTL == 0 {
+		return 0
+	}
+	// Messages of a user on legal hold never disappear
+	for _, username := range []string{msg.Sender, msg.Receiver} {
+		user, err := s.userRepo.GetUser(username)
+		if err != nil || (user != nil && user.LegalHold) {
+			return 0
+		}
+	}
+	return settings.MessageTTL
+}
+
+// BroadcastMessageDeleted tells the participants of a conversation that a stored message is gone
+func (s *websocketService) BroadcastMessageDeleted(message *models.MessageDB, reason string) {
+	deleted := models.Message{
+		ID:       message.ID,
+		Type:     "message_deleted",
+		Sender:   message.Sender,
+		Receiver: message.Receiver,
+		GroupID:  message.GroupID,
+		Status:   reason,
+	}
+	messageJSON, err := json.Marshal(deleted)
+	if err != nil {
+		log.Printf("Failed to marshal message deletion for %s: %v", message.ID, err)
+		return
+	}
+
+	var sessions []*models.Client
+	if message.GroupID != "" {
+		sessions = s.groupSessions(message.GroupID)
+	} else if message.Sender == message.Receiver {
+		sessions = s.sessionsOf(message.Sender)
+	} else {
+		sessions = s.sessionsOf(message.Sender, message.Receiver)
+	}
+	for _, client := range sessions {
 		s.sendMessage(client, messageJSON)
 	}
 }
 
+// scheduleMessage stores a message with a future send_at for the scheduler to deliver
+func (s *websocketService) scheduleMessage(msg *models.Message) {
+	scheduled := &models.ScheduledMessage{
+		ID:       msg.ID,
+		Sender:   msg.Sender,
+		Receiver: msg.Receiver,
+		GroupID:  msg.GroupID,
+		Content:  msg.Content,
+		SendAt:   *msg.SendAt,
+		Status:   models.ScheduledPending,
+	}
+	if err := s.scheduledRepo.CreateScheduledMessage(scheduled); err != nil {
+		log.Printf("Failed to schedule message from %s: %v", msg.Sender, err)
+		return
+	}
+	s.SendToUser(msg.Sender, models.Message{
+		Type:     "message_scheduled",
+		ID:       scheduled.ID,
+		Sender:   scheduled.Sender,
+		Receiver: scheduled.Receiver,
+		GroupID:  scheduled.GroupID,
+		Data:     scheduled,
+	})
+	log.Printf("Scheduled message %s from %s for %s", scheduled.ID, scheduled.Sender, scheduled.SendAt)
+}
+
 func (s *websocketService) handleTypingStatus(client *models.Client, msg *models.Message) {
 	if msg.GroupID == "" && (msg.Receiver == "" || msg.Receiver == msg.Sender) {
 		return
@@ -490,22 +1475,48 @@ func (s *websocketService) handleTypingStatus(client *models.Client, msg *models
 		return
 	}
 
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
 	if msg.GroupID != "" {
-		if groupClients, exists := s.groups[msg.GroupID]; exists {
-			for _, c := range groupClients {
+		blockers := s.blockersOf(msg.Sender)
+		for _, c := range s.groupSessions(msg.GroupID) {
+			if !blockers[c.Username] {
 				s.sendMessage(c, messageJSON)
 			}
 		}
 		return
 	}
 
-	if msg.Receiver != "" {
-		if receiver, exists := s.clients[msg.Receiver]; exists {
-			s.sendMessage(receiver, messageJSON)
+	if msg.Receiver != "" && !s.isBlocked(msg.Receiver, msg.Sender) {
+		for _, c := range s.sessionsOf(msg.Receiver) {
+			s.sendMessage(c, messageJSON)
 		}
+	}
+}
 
+// isBlocked reports whether blocker has blocked blocked; lookup failures fail open
+func (s *websocketService) isBlocked(blocker, blocked string) bool {
+	if s.contactRepo == nil || blocker == "" || blocker == blocked {
+		return false
+	}
+	isBlocked, err := s.contactRepo.IsBlocked(blocker, blocked)
+	if err != nil {
+		log.Printf("Failed to check block between %s and %s: %v", blocker, blocked, err)
+		return false
+	}
+	return isBlocked
+}
+
+func (s *websocketService) blockersOf(username string) map[string]bool {
+	blockers := make(map[string]bool)
+	if s.contactRepo == nil {
+		return blockers
+	}
+	usernames, err := s.contactRepo.GetBlockers(username)
+	if err != nil {
+		log.Printf("Failed to load blockers of %s: %v", username, err)
+		return blockers
+	}
+	for _, blocker := range usernames {
+		blockers[blocker] = true
 	}
+	return blockers
 }
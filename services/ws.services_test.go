@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JomnoiZ/network-backend-group-13.git/models"
+	"github.com/JomnoiZ/network-backend-group-13.git/repository/database"
+)
+
+const testWait = 2 * time.Second
+
+// fakeConnection is an in-memory models.Connection. Frames pushed to inbound are read by
+// the hub; frames the hub writes are recorded until the connection is closed.
+type fakeConnection struct {
+	inbound chan []byte
+	closed  chan struct{}
+	once    sync.Once
+	// stalled makes writes hang until the connection is closed, like a client that
+	// stopped reading
+	stalled bool
+
+	mutex       sync.Mutex
+	written     []models.Message
+	closeCode   int
+	closeReason string
+}
+
+func newFakeConnection() *fakeConnection {
+	return &fakeConnection{
+		inbound: make(chan []byte),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *fakeConnection) Transport() string {
+	return models.TransportWebSocket
+}
+
+func (c *fakeConnection) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-c.inbound:
+		return frame, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *fakeConnection) WriteFrame(frame []byte) error {
+	if c.stalled {
+		<-c.closed
+		return errConnectionClosed
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	select {
+	case <-c.closed:
+		return errConnectionClosed
+	default:
+	}
+	var message models.Message
+	if err := json.Unmarshal(frame, &message); err != nil {
+		return err
+	}
+	c.written = append(c.written, message)
+	return nil
+}
+
+func (c *fakeConnection) Ping() error {
+	return nil
+}
+
+func (c *fakeConnection) Close(code int, reason string) error {
+	c.once.Do(func() {
+		c.mutex.Lock()
+		c.closeCode, c.closeReason = code, reason
+		c.mutex.Unlock()
+		close(c.closed)
+	})
+	return nil
+}
+
+// waitForFrame returns the first written frame of type frameType matching match
+func (c *fakeConnection) waitForFrame(t *testing.T, frameType string, match func(models.Message) bool) models.Message {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for time.Now().Before(deadline) {
+		c.mutex.Lock()
+		for _, message := range c.written {
+			if message.Type == frameType && (match == nil || match(message)) {
+				c.mutex.Unlock()
+				return message
+			}
+		}
+		c.mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %s frame was written", frameType)
+	return models.Message{}
+}
+
+func (c *fakeConnection) waitClosed(t *testing.T) (int, string) {
+	t.Helper()
+	select {
+	case <-c.closed:
+	case <-time.After(testWait):
+		t.Fatal("connection was not closed")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closeCode, c.closeReason
+}
+
+// fakeMessageRepository only answers the direct message partner lookups presence needs
+type fakeMessageRepository struct {
+	database.MessageRepository
+	partners map[string][]string
+}
+
+func (r *fakeMessageRepository) GetDirectMessagePartners(username string) ([]string, error) {
+	return r.partners[username], nil
+}
+
+func newTestHub(t *testing.T, partners map[string][]string) *websocketService {
+	t.Helper()
+	s := NewWebsocketService(&fakeMessageRepository{partners: partners}, nil, nil, nil, nil, nil, nil, nil, nil, nil).(*websocketService)
+	t.Cleanup(s.stopBackground)
+	return s
+}
+
+func fromSender(sender string) func(models.Message) bool {
+	return func(message models.Message) bool {
+		return message.Sender == sender
+	}
+}
+
+func TestConnectSendsSessionFrame(t *testing.T) {
+	s := newTestHub(t, nil)
+	conn := newFakeConnection()
+
+	client := s.HandleConnection("alice", "laptop", false, conn)
+	if client == nil {
+		t.Fatal("connection was refused")
+	}
+	frame := conn.waitForFrame(t, "session", nil)
+	data, _ := frame.Data.(map[string]interface{})
+	if data["session_id"] != client.SessionID || data["device_id"] != "laptop" {
+		t.Errorf("session frame data = %v, want session %s on laptop", frame.Data, client.SessionID)
+	}
+	if got := s.GetStatus("alice"); got != models.StatusOnline {
+		t.Errorf("status = %q, want %q", got, models.StatusOnline)
+	}
+}
+
+func TestPresenceReachesDirectMessagePartners(t *testing.T) {
+	s := newTestHub(t, map[string][]string{"alice": {"bob"}, "bob": {"alice"}})
+	aliceConn, bobConn := newFakeConnection(), newFakeConnection()
+
+	s.HandleConnection("alice", "", false, aliceConn)
+	s.HandleConnection("bob", "", false, bobConn)
+	aliceConn.waitForFrame(t, "status", func(message models.Message) bool {
+		return message.Sender == "bob" && message.Status == models.StatusOnline
+	})
+
+	bobConn.Close(models.CloseNormal, "")
+	aliceConn.waitForFrame(t, "status", func(message models.Message) bool {
+		return message.Sender == "bob" && message.Status == models.StatusOffline
+	})
+	if got := s.GetStatus("bob"); got != models.StatusOffline {
+		t.Errorf("status = %q, want %q", got, models.StatusOffline)
+	}
+}
+
+func TestSameDeviceReplacesSession(t *testing.T) {
+	s := newTestHub(t, nil)
+	first, second, other := newFakeConnection(), newFakeConnection(), newFakeConnection()
+
+	s.HandleConnection("alice", "phone", false, first)
+	s.HandleConnection("alice", "laptop", false, other)
+	s.HandleConnection("alice", "phone", false, second)
+
+	first.waitForFrame(t, "session_replaced", nil)
+	first.waitClosed(t)
+	second.waitForFrame(t, "session", nil)
+	if sessions := s.GetClients()["alice"]; len(sessions) != 2 {
+		t.Errorf("alice has %d sessions, want 2", len(sessions))
+	}
+}
+
+func TestDisconnectUserDeliversFrameBeforeClosing(t *testing.T) {
+	s := newTestHub(t, nil)
+	conn := newFakeConnection()
+	s.HandleConnection("alice", "", false, conn)
+	conn.waitForFrame(t, "session", nil)
+
+	s.DisconnectUser("alice", "Account deleted")
+
+	frame := conn.waitForFrame(t, "disconnected", fromSender("alice"))
+	if frame.Content != "Account deleted" {
+		t.Errorf("disconnected content = %q, want %q", frame.Content, "Account deleted")
+	}
+	if code, reason := conn.waitClosed(t); code != models.CloseNormal || reason != "Account deleted" {
+		t.Errorf("closed with %d %q, want %d %q", code, reason, models.CloseNormal, "Account deleted")
+	}
+}
+
+func TestBlockedUserIsRefused(t *testing.T) {
+	s := newTestHub(t, nil)
+	s.BlockSessions("alice")
+
+	conn := newFakeConnection()
+	if client := s.HandleConnection("alice", "", false, conn); client != nil {
+		t.Fatal("session of a blocked user was accepted")
+	}
+	conn.waitClosed(t)
+
+	s.UnblockSessions("alice")
+	if client := s.HandleConnection("alice", "", false, newFakeConnection()); client == nil {
+		t.Fatal("session was refused after unblocking")
+	}
+}
+
+func TestShutdownAnnouncesRestartAndRefusesSessions(t *testing.T) {
+	s := newTestHub(t, nil)
+	conn := newFakeConnection()
+	s.HandleConnection("alice", "", false, conn)
+	conn.waitForFrame(t, "session", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testWait)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	frame := conn.waitForFrame(t, "server_shutdown", nil)
+	data, _ := frame.Data.(map[string]interface{})
+	if delay, _ := data["reconnect_after_ms"].(float64); delay < float64(shutdownReconnectDelay.Milliseconds()) {
+		t.Errorf("reconnect_after_ms = %v, want at least %d", data["reconnect_after_ms"], shutdownReconnectDelay.Milliseconds())
+	}
+	if code, _ := conn.waitClosed(t); code != models.CloseGoingAway {
+		t.Errorf("closed with %d, want %d", code, models.CloseGoingAway)
+	}
+	if !s.IsShuttingDown() {
+		t.Error("IsShuttingDown() = false after Shutdown")
+	}
+	if client := s.HandleConnection("bob", "", false, newFakeConnection()); client != nil {
+		t.Error("session was accepted during shutdown")
+	}
+}
+
+func TestShutdownGivesUpWhenContextEnds(t *testing.T) {
+	s := newTestHub(t, nil)
+	conn := newFakeConnection()
+	conn.stalled = true
+	s.HandleConnection("alice", "", false, conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if code, _ := conn.waitClosed(t); code != models.CloseGoingAway {
+		t.Errorf("closed with %d, want %d", code, models.CloseGoingAway)
+	}
+}
+
+func TestPollStreamCollectsQueuedFrames(t *testing.T) {
+	s := newTestHub(t, nil)
+
+	client := s.HandleConnection("alice", "", false, NewLongPollConnection())
+	frames, open := s.PollStream(context.Background(), client, time.Second)
+	if !open || len(frames) == 0 {
+		t.Fatalf("PollStream() = %d frames, open %v; want the session frame", len(frames), open)
+	}
+	var session models.Message
+	if err := json.Unmarshal(frames[0], &session); err != nil || session.Type != "session" {
+		t.Errorf("first frame = %s, want a session frame", frames[0])
+	}
+
+	wsClient := s.HandleConnection("bob", "", false, newFakeConnection())
+	if _, open := s.PollStream(context.Background(), wsClient, time.Second); open {
+		t.Error("PollStream() on a websocket session reported it open")
+	}
+}